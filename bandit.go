@@ -0,0 +1,122 @@
+package qlearning
+
+import (
+	"fmt"
+	"math"
+)
+
+// BanditAgent is an Agent for multi-armed bandit problems, where the
+// state is irrelevant. It keeps one value and update count per action,
+// keyed by the string representation of the action alone, and ignores
+// the State passed to it apart from enumerating its actions.
+//
+// The value of an action is the sample average of its rewards; there is
+// no learning rate or discount, as nothing follows an action in a
+// bandit. Select chooses epsilon-greedily, or by upper confidence bound
+// once SetUCB is called.
+type BanditAgent struct {
+	randSource
+
+	q       map[string]float32
+	n       map[string]int
+	steps   int
+	epsilon float32
+	ucb     float32
+}
+
+// NewBanditAgent creates a BanditAgent that explores uniformly at random
+// with probability epsilon.
+func NewBanditAgent(epsilon float32) *BanditAgent {
+	return &BanditAgent{
+		q:       make(map[string]float32),
+		n:       make(map[string]int),
+		epsilon: epsilon,
+	}
+}
+
+// SetUCB makes Select choose by upper confidence bound, UCB1, instead of
+// epsilon-greedily: an action never tried is chosen first, and otherwise
+// the one maximizing
+//
+//	value + c*sqrt(ln(steps)/visits)
+//
+// which explores actions in proportion to how uncertain their value
+// still is. A c of 0 or less returns to epsilon-greedy selection.
+func (agent *BanditAgent) SetUCB(c float32) {
+	agent.ucb = c
+}
+
+// Learn applies the action to its state, like every Agent, and moves
+// the value of the action to the average of its rewards so far.
+func (agent *BanditAgent) Learn(action *StateAction, reward Rewarder) {
+	next, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	r := rewardOf(reward, action, next, nil)
+	key := action.Action.String()
+
+	agent.steps++
+	agent.n[key]++
+	agent.q[key] += (r - agent.q[key]) / float32(agent.n[key])
+}
+
+// Value returns the average reward of action, or 0 if it has not been
+// tried, whatever the state.
+func (agent *BanditAgent) Value(state State, action Action) float32 {
+	return agent.q[action.String()]
+}
+
+// Visits returns the number of times action has been learned from.
+func (agent *BanditAgent) Visits(action Action) int {
+	return agent.n[action.String()]
+}
+
+// Select implements Selector, choosing an action of state
+// epsilon-greedily or by upper confidence bound, as set by SetUCB. Ties
+// are broken at random.
+func (agent *BanditAgent) Select(state State) *StateAction {
+	var actions []Action
+	eachAction(state, func(action Action) bool {
+		actions = append(actions, action)
+		return true
+	})
+	if len(actions) == 0 {
+		return nil
+	}
+	sortActions(actions)
+
+	score := func(action Action) float32 {
+		return agent.q[action.String()]
+	}
+
+	if agent.ucb > 0 {
+		score = func(action Action) float32 {
+			n := agent.n[action.String()]
+			if n == 0 {
+				return math.MaxFloat32
+			}
+
+			bonus := math.Sqrt(math.Log(float64(agent.steps)) / float64(n))
+			return agent.q[action.String()] + agent.ucb*float32(bonus)
+		}
+	} else if randFloat32(agent.rng) < agent.epsilon {
+		action := actions[randIntn(agent.rng, len(actions))]
+		return NewStateAction(state, action, agent.Value(state, action))
+	}
+
+	best := scoreBest(eachOf(actions), score, 0)
+	action := best[randIntn(agent.rng, len(best))].action
+
+	return NewStateAction(state, action, agent.Value(state, action))
+}
+
+// String returns the name of the agent and how it selects.
+func (agent *BanditAgent) String() string {
+	if agent.ucb > 0 {
+		return fmt.Sprintf("BanditAgent(ucb %g)", agent.ucb)
+	}
+
+	return fmt.Sprintf("BanditAgent(epsilon %g)", agent.epsilon)
+}
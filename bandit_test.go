@@ -0,0 +1,60 @@
+package qlearning
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// pullArms plays a stationary bandit with arms a, b and c, paying their
+// means of 0.2, 0.5 and 0.8 plus uniform noise in [-0.5, 0.5), for n
+// pulls of agent, and returns how often each arm was pulled.
+func pullArms(agent *BanditAgent, n int) map[string]int {
+	g := graph{"arms": {"a": "arms", "b": "arms", "c": "arms"}}
+	means := map[string]float32{"a": 0.2, "b": 0.5, "c": 0.8}
+	rng := rand.New(rand.NewSource(1))
+	pay := rewardFunc(func(sa *StateAction) float32 {
+		return means[sa.Action.String()] + rng.Float32() - 0.5
+	})
+
+	pulls := map[string]int{}
+	for i := 0; i < n; i++ {
+		sa := agent.Select(g.at("arms"))
+		pulls[sa.Action.String()]++
+		agent.Learn(sa, pay)
+	}
+
+	return pulls
+}
+
+func TestBanditEpsilonGreedy(t *testing.T) {
+	agent := NewBanditAgent(0.1)
+	pulls := pullArms(agent, 3000)
+
+	if pulls["c"] < 2400 {
+		t.Errorf("best arm pulled %d times in 3000, want most of them: %v", pulls["c"], pulls)
+	}
+	g := graph{"arms": {"a": "arms", "b": "arms", "c": "arms"}}
+	for arm, mean := range map[string]float32{"a": 0.2, "b": 0.5, "c": 0.8} {
+		if v := agent.Value(g.at("arms"), edge{g, arm, "arms"}); !near(v, mean, 0.15) {
+			t.Errorf("value of %s = %g, want about %g", arm, v, mean)
+		}
+		if n := agent.Visits(edge{g, arm, "arms"}); n != pulls[arm] {
+			t.Errorf("%s visited %d times, pulled %d", arm, n, pulls[arm])
+		}
+	}
+}
+
+func TestBanditUCB(t *testing.T) {
+	agent := NewBanditAgent(0)
+	agent.SetUCB(1)
+	pulls := pullArms(agent, 3000)
+
+	if pulls["c"] < 2400 {
+		t.Errorf("best arm pulled %d times in 3000, want most of them: %v", pulls["c"], pulls)
+	}
+	for arm, n := range pulls {
+		if n < 10 {
+			t.Errorf("arm %s pulled only %d times, UCB keeps exploring every arm", arm, n)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package qlearning
+
+// RandomAgent is an Agent that values every action equally, so Next
+// chooses uniformly at random from State.Next(), drawing from the source
+// set with SetSeed if there is one. It never learns and is useful as a
+// baseline to compare a learning Agent against chance.
+type RandomAgent struct {
+	randSource
+}
+
+// NewRandomAgent creates a RandomAgent.
+func NewRandomAgent() *RandomAgent {
+	return &RandomAgent{}
+}
+
+// Learn applies the action to its state, exactly as SimpleAgent does,
+// so that environments which change in place advance the same way.
+// Nothing is learned.
+func (agent *RandomAgent) Learn(action *StateAction, reward Rewarder) {
+	action.Action.Apply(action.State)
+}
+
+// Value always returns 0.
+func (agent *RandomAgent) Value(state State, action Action) float32 {
+	return 0
+}
+
+// String returns the name of the agent.
+func (agent *RandomAgent) String() string {
+	return "RandomAgent"
+}
+
+// FirstActionAgent is an Agent that always prefers the first action
+// returned by State.Next(). Like RandomAgent, it never learns and is
+// intended only as a baseline.
+type FirstActionAgent struct{}
+
+// NewFirstActionAgent creates a FirstActionAgent.
+func NewFirstActionAgent() *FirstActionAgent {
+	return &FirstActionAgent{}
+}
+
+// Learn applies the action to its state, exactly as SimpleAgent does,
+// so that environments which change in place advance the same way.
+// Nothing is learned.
+func (agent *FirstActionAgent) Learn(action *StateAction, reward Rewarder) {
+	action.Action.Apply(action.State)
+}
+
+// Value returns 1 for the first action of state.Next() and 0 for any
+// other action.
+//
+// Value enumerates the actions of state on each call, which is cheap
+// enough for a baseline but should not be mimicked by a real Agent.
+func (agent *FirstActionAgent) Value(state State, action Action) float32 {
+	var first Action
+	eachAction(state, func(a Action) bool {
+		first = a
+		return false
+	})
+
+	if first != nil && first.String() == action.String() {
+		return 1
+	}
+
+	return 0
+}
+
+// String returns the name of the agent.
+func (agent *FirstActionAgent) String() string {
+	return "FirstActionAgent"
+}
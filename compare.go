@@ -0,0 +1,53 @@
+package qlearning
+
+import "math"
+
+// Comparison is the result of CompareAgents.
+type Comparison struct {
+	A Metrics
+	B Metrics
+
+	// Difference is A's win rate minus B's.
+	Difference float32
+
+	// Z is the z-score of Difference under a two-proportion z-test. An
+	// absolute value above about 1.96 suggests the difference is
+	// significant at the 5% level. It is 0 if the test is undefined,
+	// such as when neither or both agents won every episode.
+	Z float32
+}
+
+// CompareAgents evaluates agents a and b on the same episodes, without
+// learning, and compares their win rates. Episode i is played by each
+// agent in its own Environment from newEnv(int64(i)).
+//
+// newEnv must return Environments that are identical for the same seed,
+// and should draw any randomness, such as hangman's choice of word, from
+// a source seeded with it. Randomness in the agents themselves, such as
+// tie-breaking, is not controlled by the seed.
+func CompareAgents(a, b Agent, newEnv func(seed int64) Environment, episodes int) Comparison {
+	var c Comparison
+
+	for i := 0; i < episodes; i++ {
+		envA := newEnv(int64(i))
+		steps := play(a, envA)
+		c.A.Record(outcome(envA), steps)
+
+		envB := newEnv(int64(i))
+		steps = play(b, envB)
+		c.B.Record(outcome(envB), steps)
+	}
+
+	pA, pB := float64(c.A.WinRate()), float64(c.B.WinRate())
+	c.Difference = float32(pA - pB)
+
+	if episodes > 0 {
+		pooled := float64(c.A.Wins+c.B.Wins) / float64(2*episodes)
+		se := math.Sqrt(pooled * (1 - pooled) * 2 / float64(episodes))
+		if se > 0 {
+			c.Z = float32((pA - pB) / se)
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,69 @@
+package qlearning
+
+import "math"
+
+// DefaultConvergenceRate is the rate at which the convergence metric of
+// a SimpleAgent follows its TD errors, unless set otherwise with
+// SetConvergenceRate, averaging roughly its last 100 updates.
+const DefaultConvergenceRate = 0.01
+
+// SetConvergenceRate sets the fraction alpha, in (0, 1], by which each
+// update moves the convergence metric toward the magnitude of its TD
+// error. Smaller rates average over more updates, about 1/alpha, and so
+// are steadier but slower to notice that learning has settled. A rate
+// of 0 or less restores DefaultConvergenceRate.
+func (agent *SimpleAgent) SetConvergenceRate(alpha float32) {
+	agent.tdRate = alpha
+}
+
+// convergenceRate returns the rate set by SetConvergenceRate, or
+// DefaultConvergenceRate if none is.
+func (agent *SimpleAgent) convergenceRate() float32 {
+	if agent.tdRate > 0 && agent.tdRate <= 1 {
+		return agent.tdRate
+	}
+
+	return DefaultConvergenceRate
+}
+
+// ConvergenceMetric returns the exponential moving average of the
+// magnitude of the TD errors of the agent's updates, the difference
+// between the target of each and the Q-value it replaced, as reported
+// by History. As
+// the Q-values settle, their targets stop surprising them and the
+// metric falls toward 0, or toward the noise of the rewards if they are
+// random. It returns 0 if the agent has not been updated.
+//
+// Updated Q-values that the policy never visits again do not move the
+// metric, so a low metric means that what the agent is still learning
+// from has settled, not every state it has seen.
+func (agent *SimpleAgent) ConvergenceMetric() float32 {
+	return agent.tdAverage
+}
+
+// HasConverged reports whether the convergence metric is below
+// threshold, once the agent has made enough updates, about 1/alpha for
+// the convergence rate alpha, for the metric to average over more than
+// its first few. Trainer can stop training once it reports true, as set
+// by its ConvergenceThreshold.
+func (agent *SimpleAgent) HasConverged(threshold float32) bool {
+	return float32(agent.tdCount)*agent.convergenceRate() >= 1 && agent.tdAverage < threshold
+}
+
+// trackConvergence moves the convergence metric toward the magnitude of
+// tdError.
+func (agent *SimpleAgent) trackConvergence(tdError float32) {
+	e := float32(math.Abs(float64(tdError)))
+	if agent.tdCount == 0 {
+		agent.tdAverage = e
+	} else {
+		agent.tdAverage += agent.convergenceRate() * (e - agent.tdAverage)
+	}
+	agent.tdCount++
+}
+
+// resetConvergence forgets the TD errors averaged so far.
+func (agent *SimpleAgent) resetConvergence() {
+	agent.tdAverage = 0
+	agent.tdCount = 0
+}
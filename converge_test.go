@@ -0,0 +1,48 @@
+package qlearning
+
+import "testing"
+
+func TestConvergenceMetric(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0)
+	agent.SetConvergenceRate(0.5)
+
+	// The TD errors of learning a reward of 1 halve at every update, 1,
+	// 0.5, 0.25, and the metric follows them down.
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+	if m := agent.ConvergenceMetric(); m != 1 {
+		t.Errorf("metric %g after the first update, want its TD error 1", m)
+	}
+	if agent.HasConverged(2) {
+		t.Error("converged after one update, before the metric averages over 1/alpha of them")
+	}
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+	if m := agent.ConvergenceMetric(); m != 0.75 {
+		t.Errorf("metric %g after the second update, want 0.75", m)
+	}
+	if !agent.HasConverged(1) || agent.HasConverged(0.5) {
+		t.Errorf("HasConverged at a metric of %g: %t below 1, %t below 0.5; want true, false",
+			agent.ConvergenceMetric(), agent.HasConverged(1), agent.HasConverged(0.5))
+	}
+}
+
+func TestTrainerStopsOnConvergence(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	trainer := &Trainer{ConvergenceThreshold: 0.01}
+	m := trainer.TrainEpisodes(agent, newCorridorWalk, 1000)
+
+	if m.Episodes >= 1000 {
+		t.Fatalf("trained all %d episodes, want training stopped once converged", m.Episodes)
+	}
+	if !agent.HasConverged(0.01) {
+		t.Errorf("stopped at a metric of %g, above the threshold", agent.ConvergenceMetric())
+	}
+
+	// The corridor's values are learned by then: winning is 1, and each
+	// step before it is discounted by 0.9 once more.
+	for state, want := range map[string]float32{"s2": 1, "s1": 0.9, "s0": 0.81} {
+		if v := agent.ValueKeyed(state, agent.Policy()[state]); !near(v, want, 0.05) {
+			t.Errorf("value of %s = %g after %d episodes, want about %g", state, v, m.Episodes, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package qlearning
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// CoverageAgent wraps an Agent, recording every state it is asked to
+// choose an action for or learn from, so that tests can assert that
+// training actually explored the states they care about. It otherwise
+// behaves exactly as the wrapped Agent, which it never changes.
+//
+// Only the Agent, Selector, FallibleSelector, ExplainingSelector and
+// EpisodeResetter methods are passed through; other methods of the
+// wrapped Agent must be called on it directly. CoverageAgent is safe for
+// concurrent use if the wrapped Agent is.
+type CoverageAgent struct {
+	Agent Agent
+
+	mu      sync.Mutex
+	covered map[string]bool
+}
+
+// NewCoverageAgent creates a CoverageAgent wrapping agent.
+func NewCoverageAgent(agent Agent) *CoverageAgent {
+	return &CoverageAgent{
+		Agent:   agent,
+		covered: make(map[string]bool),
+	}
+}
+
+// Learn records the state of action, then calls Learn on the wrapped
+// Agent.
+func (agent *CoverageAgent) Learn(action *StateAction, reward Rewarder) {
+	agent.cover(action.State)
+	agent.Agent.Learn(action, reward)
+}
+
+// Value returns the wrapped Agent's value. Looking up a value does not
+// count as covering a state.
+func (agent *CoverageAgent) Value(state State, action Action) float32 {
+	return agent.Agent.Value(state, action)
+}
+
+// Select records state, then chooses an action exactly as Next would
+// for the wrapped Agent.
+func (agent *CoverageAgent) Select(state State) *StateAction {
+	agent.cover(state)
+	return Next(agent.Agent, state)
+}
+
+// SelectErr records state, then chooses an action exactly as NextErr
+// would for the wrapped Agent.
+func (agent *CoverageAgent) SelectErr(state State) (*StateAction, error) {
+	agent.cover(state)
+	return NextErr(agent.Agent, state)
+}
+
+// SelectExplained records state, then chooses and explains an action
+// exactly as NextExplained would for the wrapped Agent.
+func (agent *CoverageAgent) SelectExplained(state State) *Explanation {
+	agent.cover(state)
+	return NextExplained(agent.Agent, state)
+}
+
+// NewEpisode implements EpisodeResetter, calling NewEpisode on the
+// wrapped Agent if it is an EpisodeResetter.
+func (agent *CoverageAgent) NewEpisode() {
+	newEpisode(agent.Agent)
+}
+
+// String returns the name of the wrapped Agent.
+func (agent *CoverageAgent) String() string {
+	return fmt.Sprintf("CoverageAgent(%s)", agent.Agent)
+}
+
+// Covered returns the string representation of every state passed to
+// Learn or Next so far, sorted.
+func (agent *CoverageAgent) Covered() []string {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	states := make([]string, 0, len(agent.covered))
+	for state := range agent.covered {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	return states
+}
+
+// cover records state as covered.
+func (agent *CoverageAgent) cover(state State) {
+	key := state.String()
+
+	agent.mu.Lock()
+	agent.covered[key] = true
+	agent.mu.Unlock()
+}
+
+// Coverage returns the fraction of states in keys that agent has
+// recorded in its table, along with those it has not, in the order
+// given. keys are string representations of states, such as every
+// state of a small problem whose states can be enumerated, so that tests
+// can check that training explored all of them. Coverage of no keys is
+// 1, as none are unseen.
+func Coverage(agent *SimpleAgent, keys []string) (float32, []string) {
+	if len(keys) == 0 {
+		return 1, nil
+	}
+
+	var unseen []string
+	for _, key := range keys {
+		if !agent.hasState(key) {
+			unseen = append(unseen, key)
+		}
+	}
+
+	return float32(len(keys)-len(unseen)) / float32(len(keys)), unseen
+}
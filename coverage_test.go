@@ -0,0 +1,48 @@
+package qlearning
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"a": "end"}, "u": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	covered, unseen := Coverage(agent, []string{"u", "s", "t"})
+	if !near(covered, 1.0/3, 1e-6) || !reflect.DeepEqual(unseen, []string{"u", "t"}) {
+		t.Errorf("Coverage = %g, %q; want 1/3 and the unseen u, t in order", covered, unseen)
+	}
+
+	if covered, unseen := Coverage(agent, nil); covered != 1 || unseen != nil {
+		t.Errorf("Coverage of no keys = %g, %q; want 1 and none unseen", covered, unseen)
+	}
+}
+
+func TestCoverageAgent(t *testing.T) {
+	agent := NewCoverageAgent(NewSimpleAgent(0.5, 0.9))
+	(&Trainer{}).RunEpisode(agent, newCorridorWalk())
+
+	if got, want := agent.Covered(), []string{"s0", "s1", "s2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("covered %v, want %v", got, want)
+	}
+}
+
+func TestCoverageAgentNewEpisode(t *testing.T) {
+	// An episode cut short leaves the traces of a LambdaAgent in place
+	// until it is told a new episode starts, through the wrapper.
+	lambda := NewLambdaAgent(0.5, 0.9, 0.8, 0)
+	trainer := &Trainer{MaxStepsPerEpisode: 2}
+	trainer.RunEpisode(NewCoverageAgent(lambda), newCorridorWalk())
+
+	if n := lambda.Traces(); n != 0 {
+		t.Errorf("%d traces after an episode trained through a CoverageAgent, want 0", n)
+	}
+
+	counter := &resetCounter{SimpleAgent: NewSimpleAgent(0.5, 0.9)}
+	NewCoverageAgent(counter).NewEpisode()
+	if counter.resets != 1 {
+		t.Errorf("NewEpisode called %d times on the wrapped agent, want 1", counter.resets)
+	}
+}
@@ -0,0 +1,58 @@
+package qlearning
+
+// TrainCurriculum trains agent on each stage in turn, typically from
+// easiest to hardest, for instance short words before long ones in
+// hangman. Every episode of a stage is played in a new Environment from
+// that stage, as TrainEpisodes does, and after each one promoteWhen is
+// called with the stage's Metrics; once it returns true the agent is
+// promoted to the next stage, and after the last stage training ends.
+// The Metrics promoteWhen sees cover the most recent CurriculumWindow
+// episodes of the stage, if it is positive, and otherwise the whole
+// stage.
+//
+// TrainCurriculum returns the Metrics of every episode played in each
+// stage. promoteWhen must eventually return true for every stage,
+// which a check on Metrics.Episodes can guarantee:
+//
+//	func(m Metrics) bool { return m.WinRate() > 0.8 || m.Episodes >= 10000 }
+//
+// With a window, Episodes never exceeds CurriculumWindow, so such a cap
+// must not be larger than the window.
+func (t *Trainer) TrainCurriculum(stages []func() Environment, promoteWhen func(Metrics) bool, agent Agent) []Metrics {
+	all := make([]Metrics, len(stages))
+
+	for i, newEnv := range stages {
+		var recent []EpisodeResult
+
+		for {
+			result := t.RunEpisode(agent, newEnv())
+			all[i].Add(result)
+
+			judged := all[i]
+			if t.CurriculumWindow > 0 {
+				recent = append(recent, result)
+				if len(recent) > t.CurriculumWindow {
+					recent = recent[1:]
+				}
+
+				judged = Metrics{}
+				for _, r := range recent {
+					judged.Add(r)
+				}
+			}
+
+			if promoteWhen(judged) {
+				break
+			}
+		}
+	}
+
+	return all
+}
+
+// TrainCurriculum trains agent on each stage in turn, moving on when
+// promoteWhen returns true for the Metrics of the stage so far. See
+// Trainer.TrainCurriculum.
+func TrainCurriculum(stages []func() Environment, promoteWhen func(Metrics) bool, agent Agent) []Metrics {
+	return (&Trainer{}).TrainCurriculum(stages, promoteWhen, agent)
+}
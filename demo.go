@@ -0,0 +1,99 @@
+package qlearning
+
+// Trajectory is a recorded episode of the Actions taken in each State,
+// such as by a human expert, without rewards.
+type Trajectory struct {
+	Steps []*StateAction
+}
+
+// LearnFromDemonstrations biases the agent toward the actions taken in
+// trajs, for pretraining an agent by imitation before it learns from
+// rewards. For every step, if the Q-value of the demonstrated action is
+// not at least margin above that of every other action of the state,
+// it is moved toward the highest of them plus margin, at the learning
+// rate of a Learn of that action. The other actions are left alone, as
+// are update counts, steps, and reward statistics, as nothing is
+// learned about rewards. A step whose Action the State does not offer is
+// skipped, and the Value of each StateAction is ignored.
+//
+// Demonstrations do not change how Learn works afterward: every update
+// still moves a Q-value toward its reward and the value of the next
+// state, so the margins wear off as the agent learns the values of the
+// demonstrated actions for itself. Until then they make Select prefer
+// what was demonstrated, and bootstrap into the values of the states
+// leading to it. Raising margin, or making several passes, makes the
+// preference last longer.
+func (agent *SimpleAgent) LearnFromDemonstrations(trajs []*Trajectory, margin float32) {
+	for _, traj := range trajs {
+		for _, step := range traj.Steps {
+			agent.demonstrate(step.State, step.Action, margin)
+		}
+	}
+}
+
+// demonstrate makes the update of LearnFromDemonstrations for a single
+// demonstrated action.
+func (agent *SimpleAgent) demonstrate(state State, demonstrated Action, margin float32) {
+	s, d := state.String(), demonstrated.String()
+
+	var actions []Action
+	offered := false
+	eachAction(state, func(action Action) bool {
+		actions = append(actions, action)
+		offered = offered || action.String() == d
+		return true
+	})
+	if !offered {
+		return
+	}
+	agent.seed(state, actions)
+
+	best, found := float32(0.0), false
+	for _, action := range actions {
+		if action.String() == d {
+			continue
+		}
+		if v := agent.Value(state, action); !found || v > best {
+			best, found = v, true
+		}
+	}
+	if !found {
+		return
+	}
+
+	old := agent.Value(state, demonstrated)
+	if want := best + margin; old < want {
+		lr := agent.learningRate(d, agent.n[s][d]+1)
+		agent.setValue(s, d, old+lr*(want-old))
+	}
+}
+
+// LearnFromDemo learns from a recorded episode whose rewards are known,
+// such as logs of good plays, to warm-start the agent before it learns
+// from its own play. Each step is learned from in order, as separate
+// calls to Learn would, each seeing the updates before it, except at a
+// learning rate of 1, so that the Q-value of every demonstrated action
+// is set to its target outright, its reward plus the discounted value
+// of the next state, rather than moved partway toward it. Update counts,
+// steps, and reward statistics are kept as for any update.
+//
+// An agent starting from nothing values every action equally, and spends
+// its early episodes flailing until rewards single out the good actions
+// one update at a time. After LearnFromDemo, the
+// demonstrated actions whose rewards are positive are already preferred
+// in the states they were taken in, and later updates refine their
+// values at the agent's usual learning rate. Unlike
+// LearnFromDemonstrations, which needs no rewards, the values learned
+// are estimates of the demonstrated rewards, not margins that wear off.
+//
+// As with Learn, each action is applied to its State, so a trajectory of
+// States that Apply changes in place must be replayed from a fresh State
+// in order.
+func (agent *SimpleAgent) LearnFromDemo(trajectory []*StateAction, reward Rewarder) {
+	agent.demo = true
+	defer func() { agent.demo = false }()
+
+	for _, action := range trajectory {
+		agent.LearnE(action, reward)
+	}
+}
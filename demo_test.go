@@ -0,0 +1,93 @@
+package qlearning
+
+import "testing"
+
+func TestLearnFromDemonstrations(t *testing.T) {
+	g := graph{
+		"s": {"a": "t", "b": "t", "c": "end"},
+		"t": {"a": "end", "b": "end"},
+	}
+	agent := NewSimpleAgent(0.5, 0.9)
+	agent.Learn(g.step("s", "c"), fixedReward(1))
+
+	// The expert plays b then a, and once an action s does not offer,
+	// which is skipped.
+	trajs := []*Trajectory{{Steps: []*StateAction{
+		g.step("s", "b"),
+		g.step("t", "a"),
+		NewStateAction(g.at("s"), edge{g, "x", "end"}, 0),
+	}}}
+
+	// Each pass moves b halfway up to 1 above c, the best alternative,
+	// at 0.5.
+	agent.LearnFromDemonstrations(trajs, 1)
+	if v := agent.Value(g.at("s"), edge{g, "b", "t"}); v != 0.75 {
+		t.Errorf("Q(s, b) = %g after one pass, want 0.75", v)
+	}
+	for i := 0; i < 10; i++ {
+		agent.LearnFromDemonstrations(trajs, 1)
+	}
+
+	for _, tc := range []struct {
+		state, best, other string
+	}{
+		{"s", "b", "c"},
+		{"t", "a", "b"},
+	} {
+		best := agent.Value(g.at(tc.state), edge{g, tc.best, g[tc.state][tc.best]})
+		other := agent.Value(g.at(tc.state), edge{g, tc.other, g[tc.state][tc.other]})
+		if best < other+0.99 {
+			t.Errorf("in %s, demonstrated %s = %g, want about 1 above %s = %g", tc.state, tc.best, best, tc.other, other)
+		}
+		if got := agent.Select(g.at(tc.state)).Action.String(); got != tc.best {
+			t.Errorf("Select(%s) = %s, want the demonstrated %s", tc.state, got, tc.best)
+		}
+	}
+
+	if v := agent.Value(g.at("s"), edge{g, "c", "end"}); v != 0.5 {
+		t.Errorf("Q(s, c) = %g, want 0.5 learned from its reward alone", v)
+	}
+	if n := agent.Visits(g.at("s"), edge{g, "b", "t"}); n != 0 {
+		t.Errorf("demonstrations counted %d visits, want 0", n)
+	}
+
+	// Rewards learned afterward still move the demonstrated values.
+	agent.Learn(g.step("t", "a"), fixedReward(-10))
+	if v := agent.Value(g.at("t"), edge{g, "a", "end"}); v >= 0 {
+		t.Errorf("Q(t, a) = %g after a reward of -10, want it to fall below 0", v)
+	}
+}
+
+func TestLearnFromDemo(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "t"}, "t": {"good": "end", "bad": "end"}}
+	r := rewards{"good": 2, "bad": -1}
+	agent := NewSimpleAgent(0.1, 0.5)
+
+	// Replayed backward, the reward of good reaches a at once.
+	agent.LearnFromDemo([]*StateAction{g.step("t", "good"), g.step("s", "a")}, r)
+
+	for _, tc := range []struct {
+		state, action string
+		want          float32
+	}{
+		{"t", "good", 2},
+		{"s", "a", 0.5 * 2},
+	} {
+		sa := g.step(tc.state, tc.action)
+		if v := agent.Value(sa.State, sa.Action); v != tc.want {
+			t.Errorf("Q(%s, %s) = %g after the demo, want %g", tc.state, tc.action, v, tc.want)
+		}
+		if n := agent.Visits(sa.State, sa.Action); n != 1 {
+			t.Errorf("Visits(%s, %s) = %d, want 1", tc.state, tc.action, n)
+		}
+		if got := agent.Select(g.at(tc.state)).Action.String(); got != tc.action {
+			t.Errorf("Select(%s) = %s, want the demonstrated %s", tc.state, got, tc.action)
+		}
+	}
+
+	// Later updates are at the usual learning rate.
+	agent.Learn(g.step("t", "good"), fixedReward(0))
+	if v := agent.Value(g.at("t"), edge{g, "good", "end"}); !near(v, 1.8, 1e-6) {
+		t.Errorf("Q(t, good) = %g after a reward of 0, want 2 moved a tenth of the way to 0", v)
+	}
+}
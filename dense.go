@@ -0,0 +1,78 @@
+package qlearning
+
+import "fmt"
+
+// DenseAgent is an Agent for small, bounded state and action spaces. It
+// stores Q-values in a dense table indexed by integers instead of maps
+// keyed by strings, which avoids hashing and allocation on every update.
+type DenseAgent struct {
+	q           [][]float32
+	stateIndex  func(State) int
+	actionIndex func(Action) int
+	lr          float32
+	d           float32
+}
+
+// NewDenseAgent creates a DenseAgent for numStates states and numActions
+// actions with the provided learning rate and discount factor.
+// stateIndex and actionIndex map every State and Action to an index in
+// [0, numStates) and [0, numActions) respectively; the agent panics if
+// they return an index out of range.
+func NewDenseAgent(numStates, numActions int, stateIndex func(State) int, actionIndex func(Action) int, lr, d float32) *DenseAgent {
+	q := make([][]float32, numStates)
+	cells := make([]float32, numStates*numActions)
+	for i := range q {
+		q[i] = cells[i*numActions : (i+1)*numActions]
+	}
+
+	return &DenseAgent{
+		q:           q,
+		stateIndex:  stateIndex,
+		actionIndex: actionIndex,
+		lr:          lr,
+		d:           d,
+	}
+}
+
+// Learn updates the existing Q-value for the given State and Action
+// using the Rewarder, as SimpleAgent does. The next state is valued by
+// the highest Q-value in its row, where every action starts at 0, unless
+// the action ends the episode, as SimpleAgent.Learn describes, in which
+// case the update is toward the reward alone and the next state is not
+// indexed. An Action implementing FallibleAction that fails leaves the
+// agent unchanged.
+func (agent *DenseAgent) Learn(action *StateAction, reward Rewarder) {
+	s := agent.stateIndex(action.State)
+	a := agent.actionIndex(action.Action)
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	target := rewardOf(reward, action, nextState, nil)
+	if !ended(action, nextState) {
+		row := agent.q[agent.stateIndex(nextState)]
+
+		maxNextVal := row[0]
+		for _, v := range row[1:] {
+			if v > maxNextVal {
+				maxNextVal = v
+			}
+		}
+		target += agent.d * maxNextVal
+	}
+
+	currentVal := agent.q[s][a]
+	agent.q[s][a] = currentVal + agent.lr*(target-currentVal)
+}
+
+// Value gets the current Q-value for a State and Action.
+func (agent *DenseAgent) Value(state State, action Action) float32 {
+	return agent.q[agent.stateIndex(state)][agent.actionIndex(action)]
+}
+
+// String returns the Q-value table as a printed string.
+func (agent *DenseAgent) String() string {
+	return fmt.Sprintf("%v", agent.q)
+}
@@ -0,0 +1,188 @@
+package qlearning
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// gridSize is the width and height of the grid of gridCell.
+const gridSize = 8
+
+// gridCell is a state of a square grid, whose actions are moves of one
+// cell that stop at its edges.
+type gridCell struct {
+	x, y int
+}
+
+// gridMoves are the actions of every gridCell, in index order.
+var gridMoves = []Action{
+	gridMove{"up", 0, 0, -1},
+	gridMove{"down", 1, 0, 1},
+	gridMove{"left", 2, -1, 0},
+	gridMove{"right", 3, 1, 0},
+}
+
+func (c gridCell) String() string {
+	return strconv.Itoa(c.x) + "," + strconv.Itoa(c.y)
+}
+
+func (c gridCell) Next() []Action {
+	return gridMoves
+}
+
+func (c gridCell) index() int {
+	return c.y*gridSize + c.x
+}
+
+// gridMove is an action of a gridCell.
+type gridMove struct {
+	name   string
+	index  int
+	dx, dy int
+}
+
+func (m gridMove) String() string {
+	return m.name
+}
+
+func (m gridMove) Apply(state State) State {
+	c := state.(gridCell)
+	c.x = onGrid(c.x + m.dx)
+	c.y = onGrid(c.y + m.dy)
+
+	return c
+}
+
+// onGrid limits a coordinate to the grid.
+func onGrid(i int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= gridSize {
+		return gridSize - 1
+	}
+
+	return i
+}
+
+// gridReward rewards reaching the bottom-right corner.
+var gridReward = rewardFunc(func(sa *StateAction) float32 {
+	if sa.Action.Apply(sa.State) == (gridCell{gridSize - 1, gridSize - 1}) {
+		return 1
+	}
+	return 0
+})
+
+func newGridAgent(lr, d float32) *DenseAgent {
+	return NewDenseAgent(gridSize*gridSize, len(gridMoves),
+		func(s State) int { return s.(gridCell).index() },
+		func(a Action) int { return a.(gridMove).index },
+		lr, d)
+}
+
+// gridSteps returns n random steps through the grid.
+func gridSteps(n int) []*StateAction {
+	rng := rand.New(rand.NewSource(1))
+	steps := make([]*StateAction, n)
+
+	var cell State = gridCell{}
+	for i := range steps {
+		move := gridMoves[rng.Intn(len(gridMoves))]
+		steps[i] = NewStateAction(cell, move, 0)
+		cell = move.Apply(cell)
+	}
+
+	return steps
+}
+
+func TestDenseAgentMatchesSimpleAgent(t *testing.T) {
+	dense := newGridAgent(0.5, 0.9)
+	simple := NewSimpleAgent(0.5, 0.9)
+	for _, step := range gridSteps(5000) {
+		dense.Learn(step, gridReward)
+		simple.Learn(step, gridReward)
+	}
+
+	for x := 0; x < gridSize; x++ {
+		for y := 0; y < gridSize; y++ {
+			for _, move := range gridMoves {
+				cell := gridCell{x, y}
+				if d, s := dense.Value(cell, move), simple.Value(cell, move); !near(d, s, 1e-5) {
+					t.Errorf("%v %v: DenseAgent %g, SimpleAgent %g", cell, move, d, s)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkDenseAgentLearn(b *testing.B) {
+	agent := newGridAgent(0.5, 0.9)
+	steps := gridSteps(1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agent.Learn(steps[i%len(steps)], gridReward)
+	}
+}
+
+func BenchmarkSimpleAgentLearnGrid(b *testing.B) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	steps := gridSteps(1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agent.Learn(steps[i%len(steps)], gridReward)
+	}
+}
+
+// indexOf returns an index function numbering names in order, which
+// panics for any other name, as NewDenseAgent does for an index out of
+// range.
+func indexOf(names ...string) func(fmt.Stringer) int {
+	return func(x fmt.Stringer) int {
+		for i, name := range names {
+			if x.String() == name {
+				return i
+			}
+		}
+		panic("no index for " + x.String())
+	}
+}
+
+func TestDenseAgentEndsEpisodes(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"b": "end", "c": "fail"}}
+	states, actions := indexOf("s", "t"), indexOf("a", "b", "c")
+	agent := NewDenseAgent(2, 3,
+		func(s State) int { return states(s) },
+		func(a Action) int { return actions(a) },
+		1, 0.9)
+
+	// t ends the episode at end, a Done walk with no index, so b is
+	// valued by its reward alone.
+	agent.Learn(NewStateAction(&walk{g: g, at: "t"}, hop{"b", "end"}, 0), fixedReward(5))
+	if v := agent.Value(g.at("t"), edge{g, "b", "end"}); v != 5 {
+		t.Errorf("Q(t, b) = %g, want the reward of 5", v)
+	}
+
+	// A StateAction marked Terminal is not bootstrapped from t.
+	sa := g.step("s", "a")
+	sa.Terminal = true
+	agent.Learn(sa, fixedReward(1))
+	if v := agent.Value(g.at("s"), edge{g, "a", "t"}); v != 1 {
+		t.Errorf("Q(s, a) = %g when terminal, want the reward of 1", v)
+	}
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+	if v := agent.Value(g.at("s"), edge{g, "a", "t"}); v != 1+0.9*5 {
+		t.Errorf("Q(s, a) = %g, want 1 + 0.9*5", v)
+	}
+
+	// A failed action changes nothing.
+	agent.Learn(NewStateAction(&walk{g: g, at: "t"}, hop{"c", "fail"}, 0), fixedReward(100))
+	if v := agent.Value(g.at("t"), edge{g, "c", "fail"}); v != 0 {
+		t.Errorf("Q(t, c) = %g after its action failed, want 0", v)
+	}
+}
@@ -0,0 +1,51 @@
+package qlearning
+
+import "sort"
+
+// CellDiff describes a Q-value that differs between two agents.
+type CellDiff struct {
+	State  string
+	Action string
+	Old    float32
+	New    float32
+}
+
+// DiffAgents returns every Q-value that differs between a and b, where a
+// holds the old values and b the new ones. A Q-value recorded by only
+// one agent is compared against the value the other would seed it with,
+// its initializer's or else its default value, which is what Value
+// reports for it.
+//
+// The result is sorted by State and then by Action.
+func DiffAgents(a, b *SimpleAgent) []CellDiff {
+	diffs := make([]CellDiff, 0)
+
+	a.Range(func(state, action string, old float32) bool {
+		new, ok := b.q.Get(state, action)
+		if !ok {
+			new = b.seedValue(state, action)
+		}
+		if new != old {
+			diffs = append(diffs, CellDiff{state, action, old, new})
+		}
+		return true
+	})
+
+	b.Range(func(state, action string, new float32) bool {
+		if _, ok := a.q.Get(state, action); !ok {
+			if old := a.seedValue(state, action); new != old {
+				diffs = append(diffs, CellDiff{state, action, old, new})
+			}
+		}
+		return true
+	})
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].State != diffs[j].State {
+			return diffs[i].State < diffs[j].State
+		}
+		return diffs[i].Action < diffs[j].Action
+	})
+
+	return diffs
+}
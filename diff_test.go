@@ -0,0 +1,45 @@
+package qlearning
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffAgents(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}, "t": {"c": "end"}}
+	before, after := NewSimpleAgent(1, 0), NewSimpleAgent(1, 0)
+	for _, agent := range []*SimpleAgent{before, after} {
+		agent.Learn(g.step("s", "a"), fixedReward(1))
+		agent.Learn(g.step("s", "b"), fixedReward(2))
+	}
+
+	after.Learn(g.step("t", "c"), fixedReward(3))
+	after.Learn(g.step("s", "a"), fixedReward(4))
+	after.Learn(g.step("s", "b"), fixedReward(2))
+
+	want := []CellDiff{
+		{"s", "a", 1, 4},
+		{"t", "c", 0, 3},
+	}
+	if got := DiffAgents(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffAgents = %v, want %v", got, want)
+	}
+}
+
+func TestDiffAgentsInitializer(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	initial := func(state, action string) float32 { return 3 }
+
+	a := NewSimpleAgent(1, 0)
+	a.SetInitializer(initial)
+	b := NewSimpleAgent(1, 0)
+	b.SetInitializer(initial)
+	b.Learn(g.step("s", "a"), fixedReward(3))
+
+	if got := DiffAgents(a, b); len(got) != 0 {
+		t.Errorf("DiffAgents = %v, want none for a cell learned to its seed value", got)
+	}
+	if got := DiffAgents(b, a); len(got) != 0 {
+		t.Errorf("DiffAgents reversed = %v, want none", got)
+	}
+}
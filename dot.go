@@ -0,0 +1,63 @@
+package qlearning
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the agent's greedy policy to w as a Graphviz DOT
+// graph, with a node for every recorded state and an edge from each
+// state to the one its greedy action, as chosen by BestActionPerState,
+// leads to, labelled with the action and its Q-value. The table only
+// holds the string representations of states and actions, so apply must
+// give the string of the state an action leads to, or "" if it is not
+// known, in which case the edge is left out.
+//
+// The output is sorted, and so the same for the same table. Graphviz
+// can only lay out small graphs legibly, so this is meant for teaching
+// and for small problems such as the gridworld example, not for tables
+// more than a few hundred states large.
+func (agent *SimpleAgent) WriteDOT(w io.Writer, apply func(state, action string) string) error {
+	policy := agent.BestActionPerState()
+
+	states := make([]string, 0, len(policy))
+	for state := range policy {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	b := bufio.NewWriter(w)
+
+	b.WriteString("digraph policy {\n")
+	for _, state := range states {
+		b.WriteString("\t" + dotQuote(state) + ";\n")
+	}
+	for _, state := range states {
+		action := policy[state]
+
+		next := apply(state, action)
+		if next == "" {
+			continue
+		}
+
+		v, _ := agent.q.Get(state, action)
+		label := action + " (" + strconv.FormatFloat(float64(v), 'g', 4, 32) + ")"
+		b.WriteString("\t" + dotQuote(state) + " -> " + dotQuote(next) + " [label=" + dotQuote(label) + "];\n")
+	}
+	b.WriteString("}\n")
+
+	return b.Flush()
+}
+
+// dotEscaper escapes the only characters special in a DOT quoted string.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// dotQuote returns s as a DOT quoted string. Unlike strconv.Quote, it
+// leaves every other character as it is, as DOT would not unescape Go
+// escapes such as \u00e9.
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,50 @@
+package qlearning
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	g := graph{
+		`say "hi"`: {"go": `café\1`},
+		`café\1`:   {"stop": "end", "stay": `café\1`},
+	}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(g.step(`say "hi"`, "go"), fixedReward(0.5))
+	agent.Learn(g.step(`café\1`, "stop"), fixedReward(2))
+	agent.Learn(g.step(`café\1`, "stay"), fixedReward(1))
+
+	var buf bytes.Buffer
+	err := agent.WriteDOT(&buf, func(state, action string) string {
+		return g[state][action]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `digraph policy {
+	"café\\1";
+	"say \"hi\"";
+	"café\\1" -> "end" [label="stop (2)"];
+	"say \"hi\"" -> "café\\1" [label="go (0.5)"];
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteDOT wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteDOTUnknownNext(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	var buf bytes.Buffer
+	if err := agent.WriteDOT(&buf, func(string, string) string { return "" }); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "digraph policy {\n\t\"s\";\n}\n"; got != want {
+		t.Errorf("WriteDOT wrote %q, want %q", got, want)
+	}
+}
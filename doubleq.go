@@ -0,0 +1,105 @@
+package qlearning
+
+import "fmt"
+
+// DoubleQAgent is an Agent learning with double Q-learning, which
+// estimates the values of actions with noisy rewards more accurately
+// than SimpleAgent. SimpleAgent bootstraps from the highest value of the
+// next state, and as the action that looks highest is partly the one
+// whose noise happens to be highest, its values are biased upward.
+// DoubleQAgent keeps two tables learned from separate halves of the
+// updates, and each update chooses the best next action with one table
+// but takes its value from the other, whose noise is independent.
+//
+// Value and action selection use the average of both tables.
+type DoubleQAgent struct {
+	randSource
+
+	a, b    map[string]map[string]float32
+	lr      float32
+	d       float32
+	epsilon float32
+}
+
+// NewDoubleQAgent creates a DoubleQAgent with the provided learning rate
+// and discount factor, exploring uniformly at random with probability
+// epsilon.
+func NewDoubleQAgent(lr, d, epsilon float32) *DoubleQAgent {
+	return &DoubleQAgent{
+		a:       make(map[string]map[string]float32),
+		b:       make(map[string]map[string]float32),
+		lr:      lr,
+		d:       d,
+		epsilon: epsilon,
+	}
+}
+
+// Learn applies the action and updates one of the two tables, chosen at
+// random, toward its reward plus the discounted value, in the other
+// table, of the action of the next state that is best in the table
+// being updated, with ties broken at random. If the action ends the
+// episode, as SimpleAgent.Learn describes, or the next state has no
+// actions, the update is toward the reward alone.
+func (agent *DoubleQAgent) Learn(action *StateAction, reward Rewarder) {
+	s, a := action.State.String(), action.Action.String()
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	r := rewardOf(reward, action, nextState, nil)
+
+	update, other := agent.a, agent.b
+	if randIntn(agent.rng, 2) == 1 {
+		update, other = other, update
+	}
+
+	target := r
+	if !ended(action, nextState) {
+		target += agent.d * agent.crossValue(update, other, nextState)
+	}
+
+	if _, ok := update[s]; !ok {
+		update[s] = make(map[string]float32)
+	}
+
+	old := update[s][a]
+	update[s][a] = old + agent.lr*(target-old)
+}
+
+// crossValue returns the value in other of the action of next that is
+// best in update, with ties broken at random, or 0 if next has no
+// actions.
+func (agent *DoubleQAgent) crossValue(update, other map[string]map[string]float32, next State) float32 {
+	s := next.String()
+	score := func(action Action) float32 {
+		return update[s][action.String()]
+	}
+
+	best := scoreBest(func(fn func(Action) bool) { eachAction(next, fn) }, score, 0)
+	if len(best) == 0 {
+		return 0
+	}
+	choice := best[randIntn(agent.rng, len(best))]
+
+	return other[s][choice.action.String()]
+}
+
+// Value returns the average of the Q-values of state and action in both
+// tables, where one not learned is 0.
+func (agent *DoubleQAgent) Value(state State, action Action) float32 {
+	s, a := state.String(), action.String()
+	return (agent.a[s][a] + agent.b[s][a]) / 2
+}
+
+// Select implements Selector, choosing an action of state
+// epsilon-greedily by Value. Ties are broken at random.
+func (agent *DoubleQAgent) Select(state State) *StateAction {
+	return epsilonGreedy(agent, state, agent.epsilon)
+}
+
+// String returns the name of the agent and its exploration rate.
+func (agent *DoubleQAgent) String() string {
+	return fmt.Sprintf("DoubleQAgent(epsilon %g)", agent.epsilon)
+}
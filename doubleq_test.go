@@ -0,0 +1,48 @@
+package qlearning
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// overestimate trains agent on a problem whose noise fools a maximum:
+// from s, a leads to t, whose 10 actions all pay -0.1 on average plus
+// uniform noise in [-1, 1), so the true value of a is 0.9 * -0.1. It
+// returns the learned value of a.
+func overestimate(agent Agent) float32 {
+	g := graph{"s": {"a": "t"}, "t": {}}
+	for i := 0; i < 10; i++ {
+		g["t"][strconv.Itoa(i)] = "end"
+	}
+	rng := rand.New(rand.NewSource(1))
+	noisy := rewardFunc(func(sa *StateAction) float32 {
+		if sa.State.String() == "s" {
+			return 0
+		}
+		return -0.1 + 2*rng.Float32() - 1
+	})
+
+	for i := 0; i < 3000; i++ {
+		agent.Learn(g.step("s", "a"), noisy)
+		for _, action := range g.at("t").Next() {
+			agent.Learn(NewStateAction(g.at("t"), action, 0), noisy)
+		}
+	}
+
+	return agent.Value(g.at("s"), edge{g, "a", "t"})
+}
+
+func TestDoubleQOverestimatesLess(t *testing.T) {
+	double := NewDoubleQAgent(0.1, 0.9, 0)
+	double.SetSeed(1)
+	simple := NewSimpleAgent(0.1, 0.9)
+
+	d, s := overestimate(double), overestimate(simple)
+	if s < 0.05 {
+		t.Fatalf("SimpleAgent values a at %g, want it to overestimate the true -0.09", s)
+	}
+	if !near(d, -0.09, (s+0.09)/2) {
+		t.Errorf("DoubleQAgent values a at %g, SimpleAgent at %g, want it much nearer the true -0.09", d, s)
+	}
+}
@@ -0,0 +1,114 @@
+package qlearning
+
+import "fmt"
+
+// EnsembleAgent is an Agent combining the value estimates of several
+// member Agents, typically trained independently, which makes its
+// choices more robust than those of any single member.
+type EnsembleAgent struct {
+	randSource
+
+	members []Agent
+	vote    bool
+}
+
+// NewEnsembleAgent creates an EnsembleAgent of members, which choose
+// by their mean value until SetMajorityVote is enabled.
+func NewEnsembleAgent(members ...Agent) *EnsembleAgent {
+	return &EnsembleAgent{members: members}
+}
+
+// SetMajorityVote enables or disables choosing actions by majority vote.
+// When enabled, Select asks every member for its choice with Next and
+// returns the action chosen by the most members, breaking ties at
+// random. Otherwise Select returns the action with the highest mean
+// value. Value always returns the mean.
+func (agent *EnsembleAgent) SetMajorityVote(enabled bool) {
+	agent.vote = enabled
+}
+
+// Members returns the members of the ensemble.
+func (agent *EnsembleAgent) Members() []Agent {
+	return agent.members
+}
+
+// Learn calls Learn on every member with the same StateAction and
+// Rewarder, so every member applies the action to the State in turn.
+// That suits States whose Apply returns a new State; an Environment
+// changed in place by Apply, such as the hangman example's Game, would
+// be advanced once per member. Agents for such Environments should be
+// trained on their own and then combined.
+func (agent *EnsembleAgent) Learn(action *StateAction, reward Rewarder) {
+	for _, member := range agent.members {
+		member.Learn(action, reward)
+	}
+}
+
+// NewEpisode implements EpisodeResetter, calling NewEpisode on every
+// member that is an EpisodeResetter, once for each time it is a member,
+// just as Learn is called.
+func (agent *EnsembleAgent) NewEpisode() {
+	for _, member := range agent.members {
+		newEpisode(member)
+	}
+}
+
+// Value returns the mean of the members' values of a State and Action,
+// or 0 if the ensemble has no members.
+func (agent *EnsembleAgent) Value(state State, action Action) float32 {
+	if len(agent.members) == 0 {
+		return 0
+	}
+
+	var sum float32
+	for _, member := range agent.members {
+		sum += member.Value(state, action)
+	}
+
+	return sum / float32(len(agent.members))
+}
+
+// Select implements Selector, choosing by mean value or by majority
+// vote as set by SetMajorityVote.
+func (agent *EnsembleAgent) Select(state State) *StateAction {
+	if !agent.vote {
+		best := bestActions(agent, state, 0)
+		if len(best) == 0 {
+			return nil
+		}
+
+		return best[randIntn(agent.rng, len(best))]
+	}
+
+	votes := make(map[string]int)
+	for _, member := range agent.members {
+		if choice := Next(member, state); choice != nil {
+			votes[choice.Action.String()]++
+		}
+	}
+
+	var candidates []Action
+	eachAction(state, func(action Action) bool {
+		if votes[action.String()] > 0 {
+			candidates = append(candidates, action)
+		}
+		return true
+	})
+
+	count := func(action Action) float32 {
+		return float32(votes[action.String()])
+	}
+
+	best := scoreBest(eachOf(candidates), count, 0)
+	if len(best) == 0 {
+		return nil
+	}
+
+	action := best[randIntn(agent.rng, len(best))].action
+	return NewStateAction(state, action, agent.Value(state, action))
+}
+
+// String returns the name of the agent and its number of members.
+func (agent *EnsembleAgent) String() string {
+	return fmt.Sprintf("EnsembleAgent(%d members)", len(agent.members))
+}
@@ -0,0 +1,75 @@
+package qlearning
+
+import "testing"
+
+// newEnsembleMembers returns three agents, the first valuing action a of
+// g's state s at 10 and the others valuing b at 1.
+func newEnsembleMembers(g graph) []Agent {
+	members := []Agent{NewSimpleAgent(1, 0), NewSimpleAgent(1, 0), NewSimpleAgent(1, 0)}
+	members[0].Learn(g.step("s", "a"), fixedReward(10))
+	members[1].Learn(g.step("s", "b"), fixedReward(1))
+	members[2].Learn(g.step("s", "b"), fixedReward(1))
+
+	return members
+}
+
+func TestEnsembleValue(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	members := newEnsembleMembers(g)
+	ensemble := NewEnsembleAgent(members...)
+
+	for _, action := range g.at("s").Next() {
+		var sum float32
+		for _, member := range members {
+			sum += member.Value(g.at("s"), action)
+		}
+
+		if got, want := ensemble.Value(g.at("s"), action), sum/3; got != want {
+			t.Errorf("Value(%s) = %g, want the members' mean %g", action, got, want)
+		}
+	}
+
+	if v := NewEnsembleAgent().Value(g.at("s"), edge{g, "a", "end"}); v != 0 {
+		t.Errorf("Value of an empty ensemble = %g, want 0", v)
+	}
+}
+
+func TestEnsembleSelect(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	ensemble := NewEnsembleAgent(newEnsembleMembers(g)...)
+
+	if got := ensemble.Select(g.at("s")).Action.String(); got != "a" {
+		t.Errorf("by mean value, Select chose %q, want %q", got, "a")
+	}
+
+	ensemble.SetMajorityVote(true)
+	if got := ensemble.Select(g.at("s")).Action.String(); got != "b" {
+		t.Errorf("by majority vote, Select chose %q, want %q", got, "b")
+	}
+}
+
+func TestEnsembleLearn(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	members := []Agent{NewSimpleAgent(1, 0), NewSimpleAgent(0.5, 0)}
+	NewEnsembleAgent(members...).Learn(g.step("s", "a"), fixedReward(4))
+
+	if a, b := members[0].Value(g.at("s"), edge{g, "a", "end"}), members[1].Value(g.at("s"), edge{g, "a", "end"}); a != 4 || b != 2 {
+		t.Errorf("members learned %g, %g; want 4, 2", a, b)
+	}
+}
+
+func TestEnsembleNewEpisode(t *testing.T) {
+	counter := &resetCounter{SimpleAgent: NewSimpleAgent(0.5, 0.9)}
+	lambda := NewLambdaAgent(0.5, 0.9, 0.8, 0)
+	ensemble := NewEnsembleAgent(counter, lambda, NewSimpleAgent(0.5, 0.9))
+
+	trainer := &Trainer{MaxStepsPerEpisode: 2}
+	trainer.RunEpisode(ensemble, newCorridorWalk())
+
+	if counter.resets != 1 {
+		t.Errorf("NewEpisode called %d times on a member, want 1", counter.resets)
+	}
+	if n := lambda.Traces(); n != 0 {
+		t.Errorf("%d traces left in a member after the episode, want 0", n)
+	}
+}
@@ -0,0 +1,134 @@
+package qlearning
+
+// Outcomer is an optional interface for States that can classify the
+// outcome of an episode. By convention a positive outcome is a win, a
+// negative outcome is a loss, and zero is a draw or an episode that is
+// still in progress. The hangman example's Won, Lost, and Active follow
+// this convention.
+type Outcomer interface {
+	Outcome() int
+}
+
+// outcome returns the Outcome of state, or 0 if state does not
+// implement Outcomer.
+func outcome(state State) int {
+	if o, ok := state.(Outcomer); ok {
+		return o.Outcome()
+	}
+
+	return 0
+}
+
+// EpisodeResetter is an optional interface for Agents that keep state
+// between the steps of an episode, such as eligibility traces, steps
+// waiting for their n-step return, or a count of episodes for a
+// schedule. NewEpisode tells the agent that the episode it was learning
+// from is over, and that the next action learned from starts another.
+// Trainer and SelfPlay call it after every episode they play, so state
+// from one episode never carries over into the next, and CoverageAgent,
+// SyncAgent and EnsembleAgent pass it on to the agents they wrap.
+type EpisodeResetter interface {
+	NewEpisode()
+}
+
+// newEpisode calls NewEpisode on agent if it implements EpisodeResetter.
+func newEpisode(agent Agent) {
+	if r, ok := agent.(EpisodeResetter); ok {
+		r.NewEpisode()
+	}
+}
+
+// Environment is a State that also rewards the actions applied to it and
+// knows when an episode is over. The episode helpers in this package
+// expect Action.Apply to change an Environment in place, as it does the
+// hangman example's Game.
+type Environment interface {
+	State
+	Rewarder
+
+	// Done reports whether the episode is over.
+	Done() bool
+}
+
+// Metrics summarizes the results of a number of episodes.
+type Metrics struct {
+	Episodes int
+	Wins     int
+	Losses   int
+	Draws    int
+	Timeouts int
+	Steps    int
+
+	// Reward is the sum of the rewards of the episodes added with Add,
+	// as described by EpisodeResult. Record does not count rewards.
+	Reward float32
+}
+
+// Record adds an episode that took the given number of steps and ended
+// with the given outcome, following the Outcomer convention.
+func (m *Metrics) Record(outcome, steps int) {
+	m.Episodes++
+	m.Steps += steps
+
+	switch {
+	case outcome > 0:
+		m.Wins++
+	case outcome < 0:
+		m.Losses++
+	default:
+		m.Draws++
+	}
+}
+
+// WinRate returns the fraction of episodes that were won.
+func (m Metrics) WinRate() float32 {
+	if m.Episodes == 0 {
+		return 0
+	}
+
+	return float32(m.Wins) / float32(m.Episodes)
+}
+
+// AverageReward returns the mean Reward per episode.
+func (m Metrics) AverageReward() float32 {
+	if m.Episodes == 0 {
+		return 0
+	}
+
+	return m.Reward / float32(m.Episodes)
+}
+
+// Evaluate plays the given number of episodes, each in a new Environment
+// from newEnv, choosing every action with Next and without learning. It
+// returns the Metrics of the episodes played. Outcomes are only counted
+// as wins or losses for Environments implementing Outcomer; any other
+// episode is a draw.
+func Evaluate(agent Agent, newEnv func() Environment, episodes int) Metrics {
+	var m Metrics
+
+	for i := 0; i < episodes; i++ {
+		env := newEnv()
+		steps := play(agent, env)
+		m.Record(outcome(env), steps)
+	}
+
+	return m
+}
+
+// play plays env to the end, choosing every action with Next and without
+// learning, and returns the number of steps taken. The episode ends early
+// if env offers no action before it is done, as no step can be taken.
+func play(agent Agent, env Environment) int {
+	steps := 0
+	for !env.Done() {
+		sa, err := NextErr(agent, env)
+		if err != nil {
+			break
+		}
+
+		sa.Action.Apply(env)
+		steps++
+	}
+
+	return steps
+}
@@ -0,0 +1,145 @@
+// An example implementation of the qlearning interfaces for a small grid
+// world. Can be run with go run gridworld.go.
+//
+// The agent starts in the top-left corner and must reach the goal in the
+// bottom-right corner while avoiding the pits. Every move costs 1, the
+// goal is worth 10, and falling into a pit costs 10. After training, the
+// agent plays one more game and prints a trace of every decision.
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ecooper/qlearning"
+)
+
+const (
+	width  = 5
+	height = 4
+
+	// maxMoves ends a game that wanders for too long, which can happen
+	// early in training.
+	maxMoves = 100
+)
+
+var (
+	goal = Position{width - 1, height - 1}
+	pits = map[Position]bool{
+		{1, 1}: true,
+		{3, 1}: true,
+		{3, 2}: true,
+	}
+
+	playFor int = 500
+)
+
+// Position is a cell in the grid.
+type Position struct {
+	X, Y int
+}
+
+// Grid is a game of grid world. It implements qlearning.Environment and
+// qlearning.Outcomer.
+type Grid struct {
+	Position
+	Moves int
+}
+
+// Outcome returns 1 if the goal was reached, -1 if the agent fell into a
+// pit, and 0 otherwise.
+func (grid *Grid) Outcome() int {
+	switch {
+	case grid.Position == goal:
+		return 1
+	case pits[grid.Position]:
+		return -1
+	}
+
+	return 0
+}
+
+// Done reports whether the game is over.
+func (grid *Grid) Done() bool {
+	return grid.Outcome() != 0 || grid.Moves >= maxMoves
+}
+
+// Reward returns the reward for arriving in the current position, as
+// the move has already been applied when Reward is called.
+func (grid *Grid) Reward(action *qlearning.StateAction) float32 {
+	switch grid.Outcome() {
+	case 1:
+		return 10
+	case -1:
+		return -10
+	}
+
+	return -1
+}
+
+// Next returns a Move for every direction that stays on the grid.
+func (grid *Grid) Next() []qlearning.Action {
+	actions := make([]qlearning.Action, 0, 4)
+
+	for _, move := range []*Move{{"up", 0, -1}, {"down", 0, 1}, {"left", -1, 0}, {"right", 1, 0}} {
+		x, y := grid.X+move.DX, grid.Y+move.DY
+		if x >= 0 && x < width && y >= 0 && y < height {
+			actions = append(actions, move)
+		}
+	}
+
+	return actions
+}
+
+// String returns the current position, which is all the agent needs to
+// know about the game.
+func (grid *Grid) String() string {
+	return fmt.Sprintf("(%d,%d)", grid.X, grid.Y)
+}
+
+// Move implements qlearning.Action for a move in one direction.
+type Move struct {
+	Name   string
+	DX, DY int
+}
+
+// String returns the name of the move.
+func (move *Move) String() string {
+	return move.Name
+}
+
+// Apply moves the player in the grid.
+func (move *Move) Apply(state qlearning.State) qlearning.State {
+	grid := state.(*Grid)
+	grid.X += move.DX
+	grid.Y += move.DY
+	grid.Moves++
+
+	return grid
+}
+
+func init() {
+	flag.IntVar(&playFor, "games", playFor, "Play N games")
+
+	flag.Parse()
+}
+
+func main() {
+	agent := qlearning.NewSimpleAgent(0.5, 0.9)
+
+	metrics := qlearning.Train(agent, func() qlearning.Environment { return &Grid{} }, playFor)
+
+	fmt.Printf("%d games played: %d WINS %d LOSSES %.0f%% WIN RATE\n\n",
+		metrics.Episodes, metrics.Wins, metrics.Losses, metrics.WinRate()*100)
+
+	steps, err := qlearning.TraceEpisode(agent, &Grid{}, maxMoves)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Trace after %d games:\n", playFor)
+	for i, step := range steps {
+		fmt.Printf("%2d. %s %-5s (value %6.2f) -> %s, reward %.0f\n", i+1, step.State, step.Action, step.Value, step.Next, step.Reward)
+	}
+}
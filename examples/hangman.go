@@ -6,10 +6,11 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
-	"math/rand"
 	"os"
+	"os/signal"
 
 	"github.com/ecooper/qlearning"
 )
@@ -26,11 +27,19 @@ var (
 	Alphabet string   = "abcdefghijklmnopqrstuvwxyz"
 	WordList []string = make([]string, 0)
 
-	wordListPath string = "./wordlist.txt"
-	debug        bool   = false
-	progressAt   int    = 1000
-	wordCount    int    = 10000
-	playFor      int    = 5000000
+	wordListPath string  = "./wordlist.txt"
+	debug        bool    = false
+	progressAt   int     = 1000
+	wordCount    int     = 10000
+	playFor      int     = 5000000
+	agentName    string  = "simple"
+	seed         int64   = 1
+	tablePath    string  = ""
+	initValue    float64 = 0
+
+	// words draws words from WordList in an order that is reproducible
+	// for a given seed.
+	words *qlearning.Sampler
 )
 
 func loadWords() error {
@@ -55,7 +64,8 @@ func loadWords() error {
 }
 
 // Game represents the state of any given game of Hangman. It implements
-// qlearning.Agent, qlearning.Rewarder, and qlearning.State.
+// qlearning.Environment, qlearning.Outcomer, qlearning.Rewarder, and
+// qlearning.State.
 type Game struct {
 	Word          string
 	Characters    int
@@ -65,6 +75,7 @@ type Game struct {
 	Correct       []string
 
 	debug bool
+	key   qlearning.KeyBuilder
 }
 
 // NewGame creates a new Hangman game for the given word. If debug
@@ -76,9 +87,10 @@ func NewGame(word string, debug bool) *Game {
 	return game
 }
 
-// NewWord returns a random word from Wordlist.
+// NewWord returns a random word from WordList. Every word is returned
+// once, in an order determined by the -seed flag, before any is repeated.
 func NewWord() string {
-	return WordList[rand.Intn(len(WordList))]
+	return WordList[words.Next()]
 }
 
 // New resets the current game to a new game for the given word.
@@ -104,6 +116,17 @@ func (game *Game) IsComplete() int {
 	return Won
 }
 
+// Outcome returns the result of IsComplete, which follows the
+// qlearning.Outcomer convention.
+func (game *Game) Outcome() int {
+	return game.IsComplete()
+}
+
+// Done reports whether the game has been won or lost.
+func (game *Game) Done() bool {
+	return game.IsComplete() != Active
+}
+
 // Choose applies a character attempt in the current game, returning
 // true if char is present in Game.Word.
 //
@@ -123,9 +146,11 @@ func (game *Game) Choose(char string) bool {
 
 	if !hit {
 		game.Lives -= 1
+		game.Log("%s was incorrect", char)
 		return false
 	}
 
+	game.Log("%s was correct", char)
 	return true
 }
 
@@ -172,7 +197,12 @@ func (game *Game) Log(msg string, args ...interface{}) {
 // String returns a consistent hash for the current game state to be
 // used in a qlearning.Agent.
 func (game *Game) String() string {
-	return fmt.Sprintf("%s", game.Correct)
+	game.key.Reset()
+	for _, char := range game.Correct {
+		game.key.AppendString(char)
+	}
+
+	return game.key.String()
 }
 
 // Choice implements qlearning.Action for a character choice in a game
@@ -200,11 +230,85 @@ func init() {
 	flag.IntVar(&progressAt, "progress", progressAt, "Print progress messages every N games")
 	flag.IntVar(&wordCount, "words", wordCount, "Use N words from wordlist")
 	flag.IntVar(&playFor, "games", playFor, "Play N games")
+	flag.StringVar(&agentName, "agent", agentName, "Agent to play with: simple, random, or first")
+	flag.Int64Var(&seed, "seed", seed, "Seed for the order words are played in")
+	flag.Float64Var(&initValue, "init", initValue, "Value of the simple agent for letters it has not learned yet")
+	flag.StringVar(&tablePath, "table", tablePath, "Path to load the simple agent's Q-table from, if it exists, and save it to after playing")
 
 	flag.Parse()
 
-	loadWords()
+	if err := loadWords(); err != nil || len(WordList) == 0 {
+		fmt.Fprintf(os.Stderr, "no words loaded from %s: %v\n", wordListPath, err)
+		os.Exit(1)
+	}
 	fmt.Printf("%d words loaded\n", len(WordList))
+
+	words = qlearning.NewSampler(len(WordList), seed, false)
+}
+
+// newAgent returns the agent selected by the -agent flag. The random
+// and first agents never learn and serve as baselines for the simple
+// agent's win rate.
+func newAgent() qlearning.Agent {
+	switch agentName {
+	case "random":
+		return qlearning.NewRandomAgent()
+	case "first":
+		return qlearning.NewFirstActionAgent()
+	}
+
+	// Our agent has a learning rate of 0.7 and discount of 1.0. A
+	// positive -init makes it optimistic about letters it has not
+	// tried, so it tries more of them early on.
+	return qlearning.NewSimpleAgentWithInit(0.7, 1.0, float32(initValue))
+}
+
+// loadTable loads the Q-table at the -table path into agent, if agent
+// is a SimpleAgent and the file exists, so that it keeps what it learned
+// in earlier runs.
+func loadTable(agent qlearning.Agent) error {
+	simple, ok := agent.(*qlearning.SimpleAgent)
+	if !ok || tablePath == "" {
+		return nil
+	}
+
+	f, err := os.Open(tablePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if err := simple.Load(f); err != nil {
+		return err
+	}
+	fmt.Printf("%d states loaded from %s\n", simple.States(), tablePath)
+
+	return nil
+}
+
+// saveTable saves the Q-table of agent to the -table path, if agent is
+// a SimpleAgent.
+func saveTable(agent qlearning.Agent) error {
+	simple, ok := agent.(*qlearning.SimpleAgent)
+	if !ok || tablePath == "" {
+		return nil
+	}
+
+	f, err := os.Create(tablePath)
+	if err != nil {
+		return err
+	}
+
+	if err := simple.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
 }
 
 func main() {
@@ -213,10 +317,15 @@ func main() {
 		lastWins = 0
 		count    = 0
 
-		// Our agent has a learning rate of 0.7 and discount of 1.0.
-		agent = qlearning.NewSimpleAgent(0.7, 1.0)
+		agent = newAgent()
+		game  *Game
 	)
 
+	if err := loadTable(agent); err != nil {
+		fmt.Fprintf(os.Stderr, "could not load %s: %v\n", tablePath, err)
+		os.Exit(1)
+	}
+
 	progress := func() {
 		// Print our progress every 1000 rows.
 		if count > 0 && count%progressAt == 0 {
@@ -226,48 +335,47 @@ func main() {
 		}
 	}
 
-	// Let's play 5 million games
-	for count = 0; count < playFor; count++ {
-		// Get a new word and game for each iteration...
-		word := NewWord()
-		game := NewGame(word, debug)
-
-		game.Log("Game created")
-
-		// While the game is still active, we'll continue to update
-		// our agent and learn from its choices.
-		for game.IsComplete() == 0 {
-			// Pick the next move, which is going to be a letter choice.
-			action := qlearning.Next(agent, game)
-
-			// Whatever that choice is, let's update our model for its
-			// impact. If the character chosen is in the game's word,
-			// then this action will be positive. Otherwise, it will be
-			// negative.
-			agent.Learn(action, game)
-
-			// Reward doesn't change state so we can check what the
-			// reward would be for this action, and report how the
-			// game changed.
-			if game.Reward(action) > 0.0 {
-				game.Log("%s was correct", action.Action.String())
+	// The trainer plays each game to the end, picking every move with
+	// qlearning.Next and updating our model for its impact. If the
+	// character chosen is in the game's word, then this action will be
+	// positive. Otherwise, it will be negative.
+	trainer := &qlearning.Trainer{
+		OnEpisodeEnd: func(result qlearning.EpisodeResult) {
+			// If we won the game, record it as a victory.
+			if result.Outcome == Won {
+				game.Log("Victory!")
+				wins += 1
 			} else {
-				game.Log("%s was incorrect", action.Action.String())
+				game.Log("Defeat!")
 			}
-		}
+			count++
+			progress()
+		},
+	}
 
-		// If we won the game, record it as a victory.
-		if game.IsComplete() == Won {
-			game.Log("Victory!")
-			wins += 1
-		} else {
-			game.Log("Defeat!")
-		}
+	// Get a new word and game for each iteration...
+	newGame := func() qlearning.Environment {
+		game = NewGame(NewWord(), debug)
+		game.Log("Game created")
+		return game
+	}
 
-		progress()
+	// Let's play 5 million games, or as many as we get through before
+	// an interrupt, which stops after the game being played so that
+	// what was learned can still be saved.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	_, err := trainer.Train(ctx, agent, newGame, playFor)
+	stop()
+	if err != nil {
+		fmt.Printf("\nInterrupted after %d games\n", count)
 	}
 
-	progress()
+	if count > 0 {
+		fmt.Printf("\nAgent performance: %d games played, %d WINS %d LOSSES %.0f%% WIN RATE\n", count, wins, count-wins, float32(wins)/float32(count)*100.0)
+	}
 
-	fmt.Printf("\nAgent performance: %d games played, %d WINS %d LOSSES %.0f%% WIN RATE\n", count, wins, count-wins, float32(wins)/float32(count)*100.0)
+	if err := saveTable(agent); err != nil {
+		fmt.Fprintf(os.Stderr, "could not save %s: %v\n", tablePath, err)
+		os.Exit(1)
+	}
 }
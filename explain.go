@@ -0,0 +1,61 @@
+package qlearning
+
+// Explanation describes how an action was chosen by NextExplained.
+type Explanation struct {
+	// Choice is the chosen StateAction.
+	Choice *StateAction
+
+	// Explored reports whether Choice was drawn at random to explore
+	// rather than chosen for its value.
+	Explored bool
+
+	// Probability is the probability that Choice would be chosen from
+	// its state, counting both the chance of exploring and of it being
+	// the greedy choice, as needed for logging action probabilities and
+	// for off-policy corrections.
+	Probability float32
+}
+
+// ExplainingSelector is implemented by Selectors that can explain their
+// choices. NextExplained defers to SelectExplained when an Agent
+// implements it.
+type ExplainingSelector interface {
+	Selector
+
+	// SelectExplained chooses a StateAction for a State as Select does,
+	// and explains the choice.
+	SelectExplained(State) *Explanation
+}
+
+// NextExplained chooses an action for state as Next does, and explains
+// the choice. For an Agent choosing greedily with random tie-breaking,
+// the probability of the choice is shared evenly among the tied
+// actions. If agent implements ExplainingSelector, its explanation is
+// returned; if it implements only Selector, the probability of its
+// choice is not known and is reported as 0.
+//
+// NextExplained returns nil if state has no actions.
+func NextExplained(agent Agent, state State) *Explanation {
+	if explainer, ok := agent.(ExplainingSelector); ok {
+		return explainer.SelectExplained(state)
+	}
+
+	if selector, ok := agent.(Selector); ok {
+		choice := selector.Select(state)
+		if choice == nil {
+			return nil
+		}
+
+		return &Explanation{Choice: choice}
+	}
+
+	best := bestActions(agent, state, 0)
+	if len(best) == 0 {
+		return nil
+	}
+
+	return &Explanation{
+		Choice:      best[randIntn(agentRand(agent), len(best))],
+		Probability: 1 / float32(len(best)),
+	}
+}
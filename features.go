@@ -0,0 +1,98 @@
+package qlearning
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Featured is implemented by States that build their own feature
+// vectors. FeaturesFromStruct uses Features instead of reflection when a
+// State implements it, so a hand-written extractor can replace the
+// reflective one on hot paths without changing callers.
+type Featured interface {
+	Features() []float32
+}
+
+// FeaturesFromStruct returns a feature vector for state, a struct or a
+// pointer to one, for function approximation. Every field tagged
+//
+//	qlearning:"feature"
+//
+// contributes one feature, in the order the fields are declared. Fields
+// may be of any integer or floating-point type, or bool, which is 1 if
+// true and 0 otherwise. Untagged fields are ignored.
+//
+// FeaturesFromStruct uses reflection. Fields are found once per type and
+// cached, but reading them is still several times slower than a
+// hand-written function; if that matters, implement Featured. It panics
+// if state is not a struct, or if a tagged field has an unsupported
+// type, as both are programming errors.
+func FeaturesFromStruct(state State) []float32 {
+	if featured, ok := state.(Featured); ok {
+		return featured.Features()
+	}
+
+	v := reflect.ValueOf(state)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("qlearning: FeaturesFromStruct of %T, which is not a struct", state))
+	}
+
+	fields := featureFields(v.Type())
+
+	features := make([]float32, len(fields))
+	for i, index := range fields {
+		f := v.Field(index)
+
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			features[i] = float32(f.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			features[i] = float32(f.Uint())
+		case reflect.Float32, reflect.Float64:
+			features[i] = float32(f.Float())
+		case reflect.Bool:
+			if f.Bool() {
+				features[i] = 1
+			}
+		}
+	}
+
+	return features
+}
+
+// featureFieldCache holds the indexes of the feature fields of each
+// struct type seen by FeaturesFromStruct.
+var featureFieldCache sync.Map // map[reflect.Type][]int
+
+// featureFields returns the indexes of the fields of the struct type t
+// tagged as features.
+func featureFields(t reflect.Type) []int {
+	if cached, ok := featureFieldCache.Load(t); ok {
+		return cached.([]int)
+	}
+
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("qlearning") != "feature" {
+			continue
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64, reflect.Bool:
+			fields = append(fields, i)
+		default:
+			panic(fmt.Sprintf("qlearning: feature field %s.%s has unsupported type %s", t, field.Name, field.Type))
+		}
+	}
+
+	featureFieldCache.Store(t, fields)
+
+	return fields
+}
@@ -0,0 +1,65 @@
+package qlearning
+
+import (
+	"reflect"
+	"testing"
+)
+
+// robot is a small struct state with tagged feature fields.
+type robot struct {
+	Name    string
+	X       int     `qlearning:"feature"`
+	Battery float64 `qlearning:"feature"`
+	Holding bool    `qlearning:"feature"`
+	Load    uint8   `qlearning:"feature"`
+	hidden  int
+}
+
+func (r robot) String() string {
+	return r.Name
+}
+
+func (r robot) Next() []Action {
+	return nil
+}
+
+// featuredRobot is a robot extracting its own features.
+type featuredRobot struct {
+	robot
+}
+
+func (r featuredRobot) Features() []float32 {
+	return []float32{42}
+}
+
+// badRobot has a feature field of an unsupported type.
+type badRobot struct {
+	robot
+	Path []int `qlearning:"feature"`
+}
+
+func TestFeaturesFromStruct(t *testing.T) {
+	state := robot{Name: "r", X: 3, Battery: 0.5, Holding: true, Load: 7, hidden: 9}
+	want := []float32{3, 0.5, 1, 7}
+
+	if got := FeaturesFromStruct(state); !reflect.DeepEqual(got, want) {
+		t.Errorf("FeaturesFromStruct = %v, want %v", got, want)
+	}
+	if got := FeaturesFromStruct(&state); !reflect.DeepEqual(got, want) {
+		t.Errorf("FeaturesFromStruct of a pointer = %v, want %v", got, want)
+	}
+
+	if got := FeaturesFromStruct(featuredRobot{state}); !reflect.DeepEqual(got, []float32{42}) {
+		t.Errorf("FeaturesFromStruct of a Featured state = %v, want its own [42]", got)
+	}
+}
+
+func TestFeaturesFromStructUnsupported(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("FeaturesFromStruct of a slice field did not panic")
+		}
+	}()
+
+	FeaturesFromStruct(badRobot{})
+}
@@ -0,0 +1,82 @@
+package qlearning
+
+import "fmt"
+
+// Float64Agent is an Agent learning as SimpleAgent does by default, but
+// keeping its Q-values as float64. A float32 Q-value can only change by
+// steps of about a ten-millionth of itself, so over millions of small
+// updates, such as those of a low learning rate near convergence, the
+// rounding of each adds up to a noticeable drift, and updates smaller
+// than a step are lost entirely. Float64Agent rounds each update about
+// half a billion times more finely.
+//
+// Rewards, and the values returned by Value, are still float32, as the
+// rest of the package expects, so Float64Agent can be used wherever an
+// Agent is; Value64 returns the Q-values at full precision.
+type Float64Agent struct {
+	randSource
+
+	q       map[string]map[string]float64
+	lr      float64
+	d       float64
+	epsilon float32
+}
+
+// NewFloat64Agent creates a Float64Agent with the provided learning rate
+// and discount factor, exploring uniformly at random with probability
+// epsilon.
+func NewFloat64Agent(lr, d float64, epsilon float32) *Float64Agent {
+	return &Float64Agent{
+		q:       make(map[string]map[string]float64),
+		lr:      lr,
+		d:       d,
+		epsilon: epsilon,
+	}
+}
+
+// Learn applies the action and updates its Q-value toward its reward
+// plus the discounted highest value of the next state, as described by
+// SimpleAgent.Learn.
+func (agent *Float64Agent) Learn(action *StateAction, reward Rewarder) {
+	s, a := action.State.String(), action.Action.String()
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	target := float64(rewardOf(reward, action, nextState, nil))
+	if !ended(action, nextState) {
+		target += agent.d * maxRecorded(agent.q[nextState.String()])
+	}
+
+	if _, ok := agent.q[s]; !ok {
+		agent.q[s] = make(map[string]float64)
+	}
+
+	old := agent.q[s][a]
+	agent.q[s][a] = old + agent.lr*(target-old)
+}
+
+// Value returns the Q-value of state and action, rounded to float32, or
+// 0 if it has not been learned.
+func (agent *Float64Agent) Value(state State, action Action) float32 {
+	return float32(agent.Value64(state, action))
+}
+
+// Value64 returns the Q-value of state and action at full precision, or
+// 0 if it has not been learned.
+func (agent *Float64Agent) Value64(state State, action Action) float64 {
+	return agent.q[state.String()][action.String()]
+}
+
+// Select implements Selector, choosing an action of state
+// epsilon-greedily. Ties are broken at random.
+func (agent *Float64Agent) Select(state State) *StateAction {
+	return epsilonGreedy(agent, state, agent.epsilon)
+}
+
+// String returns the name of the agent and its exploration rate.
+func (agent *Float64Agent) String() string {
+	return fmt.Sprintf("Float64Agent(epsilon %g)", agent.epsilon)
+}
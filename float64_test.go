@@ -0,0 +1,31 @@
+package qlearning
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloat64AgentLessDrift(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	const target = float32(1000.1)
+
+	// Near 1000, a float32 moves in steps of about 6e-5, so once an
+	// update of 0.001 of the remaining distance is smaller than half a
+	// step, the float32 value stops some way short of its target.
+	simple := NewSimpleAgent(0.001, 0)
+	f64 := NewFloat64Agent(0.001, 0, 0)
+	for i := 0; i < 30000; i++ {
+		simple.Learn(g.step("s", "a"), fixedReward(target))
+		f64.Learn(g.step("s", "a"), fixedReward(target))
+	}
+
+	a := edge{g, "a", "end"}
+	err32 := math.Abs(float64(target) - float64(simple.Value(g.at("s"), a)))
+	err64 := math.Abs(float64(target) - f64.Value64(g.at("s"), a))
+	if err32 < 0.01 {
+		t.Fatalf("float32 value is %g from its target, want it held back by rounding", err32)
+	}
+	if err64 > 1e-6 {
+		t.Errorf("float64 value is %g from its target, float32 %g; want it within 1e-6", err64, err32)
+	}
+}
@@ -0,0 +1,145 @@
+package qlearning
+
+import (
+	"errors"
+	"math"
+)
+
+// graph is a small deterministic problem for tests: the actions of each
+// state, by name, and the state each leads to. A state missing from the
+// graph has no actions.
+type graph map[string]map[string]string
+
+// at returns the state of g named name.
+func (g graph) at(name string) graphState {
+	return graphState{g, name}
+}
+
+// step returns the StateAction of taking the action named action in the
+// state of g named state.
+func (g graph) step(state, action string) *StateAction {
+	return NewStateAction(g.at(state), edge{g, action, g[state][action]}, 0)
+}
+
+// graphState is a state of a graph. Its actions never change it, so it
+// can be replayed.
+type graphState struct {
+	g    graph
+	name string
+}
+
+func (s graphState) String() string {
+	return s.name
+}
+
+func (s graphState) Next() []Action {
+	var actions []Action
+	for action, to := range s.g[s.name] {
+		actions = append(actions, edge{s.g, action, to})
+	}
+	sortActions(actions)
+
+	return actions
+}
+
+// edge is an action of a graph, leading to the state named to.
+type edge struct {
+	g        graph
+	name, to string
+}
+
+func (e edge) String() string {
+	return e.name
+}
+
+func (e edge) Apply(State) State {
+	return e.g.at(e.to)
+}
+
+// rewards is a Rewarder giving each action, by name, a fixed reward, and
+// 0 to any other.
+type rewards map[string]float32
+
+func (r rewards) Reward(sa *StateAction) float32 {
+	return r[sa.Action.String()]
+}
+
+// rewardFunc is a function used as a Rewarder.
+type rewardFunc func(sa *StateAction) float32
+
+func (f rewardFunc) Reward(sa *StateAction) float32 {
+	return f(sa)
+}
+
+// near reports whether a and b differ by at most tolerance.
+func near(a, b, tolerance float32) bool {
+	return math.Abs(float64(a-b)) <= float64(tolerance)
+}
+
+// walk is an Environment moving along the edges of a graph in place. It
+// is done once it reaches the state named "end", and rewards each action
+// by name as rewards does.
+type walk struct {
+	g       graph
+	rewards rewards
+	at      string
+}
+
+func (w *walk) String() string {
+	return w.at
+}
+
+func (w *walk) Next() []Action {
+	var actions []Action
+	for _, e := range w.g.at(w.at).Next() {
+		actions = append(actions, hop{e.String(), e.(edge).to})
+	}
+
+	return actions
+}
+
+func (w *walk) Reward(sa *StateAction) float32 {
+	return w.rewards[sa.Action.String()]
+}
+
+func (w *walk) Done() bool {
+	return w.at == "end"
+}
+
+// hop is an action of a walk, moving it to the state named to. Moving
+// to a state named "fail" fails, as a FallibleAction.
+type hop struct {
+	name, to string
+}
+
+func (h hop) String() string {
+	return h.name
+}
+
+func (h hop) Apply(state State) State {
+	next, _ := h.ApplyE(state)
+	return next
+}
+
+func (h hop) ApplyE(state State) (State, error) {
+	if h.to == "fail" {
+		return nil, errors.New("move to fail")
+	}
+
+	w := state.(*walk)
+	w.at = h.to
+
+	return w, nil
+}
+
+// resetCounter is a SimpleAgent counting the calls of NewEpisode in
+// resets.
+type resetCounter struct {
+	*SimpleAgent
+	resets int
+}
+
+func (r *resetCounter) NewEpisode() {
+	r.resets++
+	r.SimpleAgent.NewEpisode()
+}
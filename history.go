@@ -0,0 +1,109 @@
+package qlearning
+
+// DefaultUpdateHistory is the number of recent updates a SimpleAgent
+// retains unless changed with SetUpdateHistory.
+const DefaultUpdateHistory = 16
+
+// Update records a single Q-value update made by Learn.
+type Update struct {
+	State  string
+	Action string
+
+	// Reward is the reward as used in the update, after any
+	// normalization.
+	Reward float32
+
+	Old float32
+	New float32
+
+	// TDError is the temporal difference error of the update, the
+	// difference between its target and Old.
+	TDError float32
+
+	// Step is the value of Steps after the update.
+	Step int64
+
+	// PolicyChanged reports whether the update changed the action with
+	// the highest Q-value in State.
+	PolicyChanged bool
+
+	// Meta is the Meta of the StateAction learned from.
+	Meta map[string]interface{}
+}
+
+// SetUpdateHistory sets how many of the most recent updates the agent
+// retains for RecentUpdates. Changing the size discards the updates
+// retained so far, and a size of 0 stops retaining updates at all.
+func (agent *SimpleAgent) SetUpdateHistory(size int) {
+	agent.history = newUpdateRing(size)
+}
+
+// RecentUpdates returns up to n of the most recent updates made by
+// Learn, oldest first. At most the number of updates set by
+// SetUpdateHistory are retained.
+func (agent *SimpleAgent) RecentUpdates(n int) []Update {
+	return agent.history.recent(n)
+}
+
+// updateRing is a fixed-size ring buffer of Updates.
+type updateRing struct {
+	buf  []Update
+	next int
+	full bool
+}
+
+func newUpdateRing(size int) *updateRing {
+	if size < 0 {
+		size = 0
+	}
+
+	return &updateRing{buf: make([]Update, size)}
+}
+
+// add records u, overwriting the oldest update once the ring is full.
+func (r *updateRing) add(u Update) {
+	if len(r.buf) == 0 {
+		return
+	}
+
+	r.buf[r.next] = u
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// reset empties the ring, keeping its size.
+func (r *updateRing) reset() {
+	for i := range r.buf {
+		r.buf[i] = Update{}
+	}
+	r.next = 0
+	r.full = false
+}
+
+// len returns the number of updates in the ring.
+func (r *updateRing) len() int {
+	if r.full {
+		return len(r.buf)
+	}
+
+	return r.next
+}
+
+// recent returns up to n of the most recent updates, oldest first.
+func (r *updateRing) recent(n int) []Update {
+	if n > r.len() {
+		n = r.len()
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	updates := make([]Update, n)
+	for i := range updates {
+		updates[i] = r.buf[(r.next-n+i+len(r.buf))%len(r.buf)]
+	}
+
+	return updates
+}
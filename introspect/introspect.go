@@ -0,0 +1,124 @@
+// Package introspect serves the state of a training qlearning agent over
+// HTTP, for monitoring long training runs.
+//
+// It is a separate package so that the qlearning package itself does
+// not depend on net/http.
+package introspect
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/ecooper/qlearning"
+)
+
+// DefaultTop is the number of highest Q-values reported when a request
+// does not ask for a specific number.
+const DefaultTop = 10
+
+// Report is the JSON document served by a Handler.
+type Report struct {
+	Agent           string
+	Steps           int64
+	States          int
+	Values          int
+	Diverged        bool
+	Hyperparameters Hyperparameters
+	Top             []Cell
+}
+
+// Hyperparameters are the agent's learning parameters.
+type Hyperparameters struct {
+	LearningRate float32
+	Discount     float32
+	DefaultValue float32
+}
+
+// Cell is a single Q-value.
+type Cell struct {
+	State  string
+	Action string
+	Value  float32
+}
+
+// NewHandler returns a read-only http.Handler serving a Report on agent
+// as JSON. The number of highest Q-values included can be set with the
+// "top" query parameter and defaults to DefaultTop.
+//
+// The agent is read through its read lock, so the handler is safe to
+// serve while the agent is trained through the same SyncAgent. Building
+// a report visits every Q-value, blocking training while it does, so
+// the handler is best polled at a human pace.
+func NewHandler(agent *qlearning.SyncAgent) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		top := DefaultTop
+		if s := r.URL.Query().Get("top"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				http.Error(w, "top must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			top = n
+		}
+
+		var report Report
+		agent.Read(func(a *qlearning.SimpleAgent) {
+			report = newReport(a, top)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// newReport builds a Report on agent including its top highest
+// Q-values.
+func newReport(agent *qlearning.SimpleAgent, top int) Report {
+	report := Report{
+		Agent:    "SimpleAgent",
+		Steps:    agent.Steps(),
+		Diverged: agent.Diverged(),
+		Hyperparameters: Hyperparameters{
+			LearningRate: agent.LearningRate(),
+			Discount:     agent.Discount(),
+			DefaultValue: agent.DefaultValue(),
+		},
+		Top: make([]Cell, 0, top),
+	}
+
+	states := make(map[string]bool)
+	agent.Range(func(state, action string, value float32) bool {
+		states[state] = true
+		report.Values++
+		report.Top = insertTop(report.Top, Cell{state, action, value}, top)
+		return true
+	})
+	report.States = len(states)
+
+	return report
+}
+
+// insertTop adds cell to top, which is sorted by descending value, if
+// it is among the n highest.
+func insertTop(top []Cell, cell Cell, n int) []Cell {
+	i := sort.Search(len(top), func(i int) bool { return top[i].Value < cell.Value })
+	if i >= n {
+		return top
+	}
+
+	if len(top) < n {
+		top = append(top, Cell{})
+	}
+	copy(top[i+1:], top[i:])
+	top[i] = cell
+
+	return top
+}
@@ -0,0 +1,60 @@
+package qlearning
+
+import "encoding/json"
+
+// jsonAgent is the JSON form of a SimpleAgent.
+type jsonAgent struct {
+	LearningRate float32                       `json:"learningRate"`
+	Discount     float32                       `json:"discount"`
+	Steps        int64                         `json:"steps"`
+	Q            map[string]map[string]float32 `json:"q"`
+	Visits       map[string]map[string]int     `json:"visits"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the agent's Q-values,
+// update counts, learning rate, discount and step count as a JSON object
+// for tools outside Go, such as visualization scripts:
+//
+//	{"learningRate":0.1,"discount":0.9,"steps":2,
+//	 "q":{"state":{"action":0.5}},"visits":{"state":{"action":2}}}
+//
+// States and actions are written in sorted order, so the same agent is
+// always encoded the same way, and encodings of checkpoints of a run can
+// be compared with diff. Values are written in the shortest form that
+// reads back as the same float32.
+//
+// Unlike Save, MarshalJSON does not encode the running statistics of
+// reward normalization and scaling. It returns an error if a Q-value is
+// NaN or infinite, which JSON cannot represent.
+func (agent *SimpleAgent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonAgent{
+		LearningRate: agent.lr,
+		Discount:     agent.d,
+		Steps:        agent.steps,
+		Q:            agent.table(),
+		Visits:       agent.n,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the agent's
+// learned state with that encoded by MarshalJSON, as Load does for a
+// snapshot. The running statistics of reward normalization and scaling
+// are kept, as they are not encoded. The agent is unchanged if
+// UnmarshalJSON returns an error.
+func (agent *SimpleAgent) UnmarshalJSON(data []byte) error {
+	var a jsonAgent
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+
+	agent.restore(snapshotV2{
+		LearningRate: a.LearningRate,
+		Discount:     a.Discount,
+		Steps:        a.Steps,
+		Q:            a.Q,
+		Visits:       a.Visits,
+		Rewards:      agent.rewards,
+	})
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package qlearning
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// jsonTrained returns an agent that has learned Q-values that do not
+// print exactly in decimal.
+func jsonTrained() *SimpleAgent {
+	g := graph{"s": {"a": "t", "b": "end"}, "t": {"c": "end"}}
+	agent := NewSimpleAgent(1.0/3, 0.9)
+	for _, step := range []*StateAction{g.step("t", "c"), g.step("s", "a"), g.step("s", "b"), g.step("s", "a")} {
+		agent.Learn(step, rewards{"a": 0.1, "b": -1.0 / 7, "c": 2.0 / 3})
+	}
+
+	return agent
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	agent := jsonTrained()
+	data, err := json.Marshal(agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := NewSimpleAgent(0, 0)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.table(), agent.table(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Q = %v after a round trip, want exactly %v", got, want)
+	}
+	if !reflect.DeepEqual(decoded.n, agent.n) || decoded.lr != agent.lr || decoded.d != agent.d || decoded.Steps() != agent.Steps() {
+		t.Errorf("decoded visits %v, lr %g, d %g, steps %d; want %v, %g, %g, %d",
+			decoded.n, decoded.lr, decoded.d, decoded.Steps(), agent.n, agent.lr, agent.d, agent.Steps())
+	}
+
+	again, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("re-encoded as\n%s\nwant\n%s", again, data)
+	}
+}
+
+func TestJSONSorted(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	for _, state := range []string{"c", "a", "b"} {
+		for _, action := range []string{"z", "x", "y"} {
+			agent.q.Set(state, action, 1)
+		}
+	}
+
+	data, err := json.Marshal(agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"q":{"a":{"x":1,"y":1,"z":1},"b":{"x":1,"y":1,"z":1},"c":{"x":1,"y":1,"z":1}}`
+	if !bytes.Contains(data, []byte(want)) {
+		t.Errorf("encoded %s, want it to contain %s", data, want)
+	}
+}
+
+func TestJSONZeroAgent(t *testing.T) {
+	agent := jsonTrained()
+	data, err := json.Marshal(struct{ Agent *SimpleAgent }{agent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct{ Agent *SimpleAgent }
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Agent.table(), agent.table(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Q = %v decoded into a zero agent, want %v", got, want)
+	}
+
+	// The decoded agent goes on learning like any other.
+	g := graph{"s": {"a": "end"}}
+	before := decoded.Agent.ValueKeyed("s", "a")
+	decoded.Agent.Learn(g.step("s", "a"), fixedReward(1))
+	if after := decoded.Agent.ValueKeyed("s", "a"); after == before {
+		t.Errorf("Q = %g unchanged by an update after decoding", after)
+	}
+	if n := len(decoded.Agent.RecentUpdates(10)); n != 1 {
+		t.Errorf("%d updates in the history, want 1", n)
+	}
+}
@@ -0,0 +1,64 @@
+package qlearning
+
+import "strconv"
+
+// keyFieldSeparator ends every field appended to a KeyBuilder.
+const keyFieldSeparator = '\x1f'
+
+// KeyBuilder builds the string representation of a State from its
+// fields, for use in its String method. It is the recommended way to
+// implement stable keys: each field is appended in a fixed format and
+// followed by a separator, so distinct sequences of fields always give
+// distinct keys, and it allocates far less than formatting with
+// fmt.Sprintf. A KeyBuilder can be kept and reused with Reset, keeping
+// its buffer; it must then not be used by several goroutines at once.
+//
+// The zero KeyBuilder is empty and ready to use. Fields are separated by
+// the ASCII unit separator, which should therefore not appear in string
+// fields.
+type KeyBuilder struct {
+	buf []byte
+}
+
+// Reset empties the builder, keeping its buffer for reuse.
+func (b *KeyBuilder) Reset() {
+	b.buf = b.buf[:0]
+}
+
+// AppendString appends a string field.
+func (b *KeyBuilder) AppendString(s string) *KeyBuilder {
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, keyFieldSeparator)
+	return b
+}
+
+// AppendInt appends an integer field in decimal.
+func (b *KeyBuilder) AppendInt(i int64) *KeyBuilder {
+	b.buf = strconv.AppendInt(b.buf, i, 10)
+	b.buf = append(b.buf, keyFieldSeparator)
+	return b
+}
+
+// AppendBool appends a bool field as 1 or 0.
+func (b *KeyBuilder) AppendBool(v bool) *KeyBuilder {
+	if v {
+		b.buf = append(b.buf, '1')
+	} else {
+		b.buf = append(b.buf, '0')
+	}
+	b.buf = append(b.buf, keyFieldSeparator)
+	return b
+}
+
+// AppendFloat appends a floating-point field in the shortest decimal
+// form that reads back as v, so equal values always give equal keys.
+func (b *KeyBuilder) AppendFloat(v float64) *KeyBuilder {
+	b.buf = strconv.AppendFloat(b.buf, v, 'g', -1, 64)
+	b.buf = append(b.buf, keyFieldSeparator)
+	return b
+}
+
+// String returns the key built so far.
+func (b *KeyBuilder) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,53 @@
+package qlearning
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	var b KeyBuilder
+	b.AppendString("ab").AppendInt(-3).AppendBool(true).AppendFloat(0.5)
+	if got, want := b.String(), "ab\x1f-3\x1f1\x1f0.5\x1f"; got != want {
+		t.Errorf("key = %q, want %q", got, want)
+	}
+
+	// Splitting a field differently gives a different key.
+	var c KeyBuilder
+	c.AppendString("a").AppendString("b")
+	var d KeyBuilder
+	d.AppendString("ab")
+	if c.String() == d.String() {
+		t.Errorf("fields a, b and field ab share the key %q", c.String())
+	}
+
+	b.Reset()
+	if got := b.AppendInt(7).String(); got != "7\x1f" {
+		t.Errorf("key after Reset = %q, want %q", got, "7\x1f")
+	}
+}
+
+// board is the kind of slice-based state whose key the benchmarks build.
+var board = []int64{0, 1, 2, 1, 0, 2, 2, 1, 0}
+
+// keySink keeps the benchmarked keys from being optimized away.
+var keySink string
+
+func BenchmarkKeySprintf(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		keySink = fmt.Sprintf("%v", board)
+	}
+}
+
+func BenchmarkKeyBuilder(b *testing.B) {
+	b.ReportAllocs()
+	var k KeyBuilder
+	for i := 0; i < b.N; i++ {
+		k.Reset()
+		for _, cell := range board {
+			k.AppendInt(cell)
+		}
+		keySink = k.String()
+	}
+}
@@ -0,0 +1,169 @@
+package qlearning
+
+import "fmt"
+
+// DefaultTraceCutoff is the trace below which a LambdaAgent forgets a
+// state and action, unless set otherwise with SetTraceCutoff.
+const DefaultTraceCutoff = 1e-3
+
+// LambdaAgent is an Agent learning with Watkins's Q(λ), using
+// eligibility traces. SimpleAgent updates only the Q-value of the action
+// just taken; LambdaAgent keeps a trace of every state and action of the
+// episode so far, which is raised by 1 when it is taken and decays by
+// d*lambda at every step, and applies each temporal difference error to
+// all of them in proportion to their traces:
+//
+//	delta = r + d*max Q(s') - Q(s, a)
+//	Q(x, y) += lr * delta * e(x, y)   for every traced x, y
+//
+// so a reward is credited at once to the actions before it, more to the
+// recent ones. A lambda of 0 learns exactly as SimpleAgent, and a lambda
+// of 1 credits every earlier action of the episode fully.
+//
+// Traces only follow the greedy policy: when Select explores an action
+// with a lower Q-value than the best, the traces are cleared, as the
+// following rewards say nothing about the actions before it. They are
+// also cleared when an episode ends, as SimpleAgent.Learn describes, on
+// NewEpisode, and when an action does not start from the state the
+// previous one led to.
+type LambdaAgent struct {
+	randSource
+
+	q       map[string]map[string]float32
+	lr      float32
+	d       float32
+	lambda  float32
+	epsilon float32
+	cutoff  float32
+
+	// traces are the eligibility traces of the episode, and last the
+	// string representation of the state the latest action led to.
+	traces map[string]map[string]float32
+	last   string
+}
+
+// NewLambdaAgent creates a LambdaAgent with the provided learning rate,
+// discount factor, and trace decay lambda, exploring uniformly at random
+// with probability epsilon.
+func NewLambdaAgent(lr, d, lambda, epsilon float32) *LambdaAgent {
+	return &LambdaAgent{
+		q:       make(map[string]map[string]float32),
+		lr:      lr,
+		d:       d,
+		lambda:  lambda,
+		epsilon: epsilon,
+		cutoff:  DefaultTraceCutoff,
+		traces:  make(map[string]map[string]float32),
+	}
+}
+
+// SetTraceCutoff sets the trace below which the agent forgets a state
+// and action for the rest of the episode, as its updates would be
+// negligible. This bounds the traces kept in long episodes to the
+// states and actions of the last log(eps)/log(d*lambda) steps, at the
+// cost of a little accuracy. An eps of 0 or less never forgets a trace
+// before the episode ends.
+func (agent *LambdaAgent) SetTraceCutoff(eps float32) {
+	agent.cutoff = eps
+}
+
+// NewEpisode implements EpisodeResetter, clearing the traces so that the
+// next action is learned from as the first of a new episode.
+func (agent *LambdaAgent) NewEpisode() {
+	for state := range agent.traces {
+		delete(agent.traces, state)
+	}
+}
+
+// Traces returns the number of states and actions with a trace.
+func (agent *LambdaAgent) Traces() int {
+	n := 0
+	for _, actions := range agent.traces {
+		n += len(actions)
+	}
+
+	return n
+}
+
+// Learn applies the action, raises its trace, and updates every traced
+// Q-value by its share of the temporal difference error, then decays
+// the traces.
+func (agent *LambdaAgent) Learn(action *StateAction, reward Rewarder) {
+	s, a := action.State.String(), action.Action.String()
+	if s != agent.last {
+		agent.NewEpisode()
+	}
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	r := rewardOf(reward, action, nextState, nil)
+	next := nextState.String()
+
+	end := ended(action, nextState)
+	target := r
+	if !end {
+		target += agent.d * maxRecorded(agent.q[next])
+	}
+	delta := target - agent.q[s][a]
+
+	if _, ok := agent.traces[s]; !ok {
+		agent.traces[s] = make(map[string]float32)
+	}
+	agent.traces[s][a]++
+
+	decay := agent.d * agent.lambda
+	for state, actions := range agent.traces {
+		if _, ok := agent.q[state]; !ok {
+			agent.q[state] = make(map[string]float32)
+		}
+
+		for action, e := range actions {
+			agent.q[state][action] += agent.lr * delta * e
+
+			if e *= decay; e <= agent.cutoff {
+				delete(actions, action)
+			} else {
+				actions[action] = e
+			}
+		}
+		if len(actions) == 0 {
+			delete(agent.traces, state)
+		}
+	}
+
+	agent.last = next
+	if end {
+		agent.NewEpisode()
+	}
+}
+
+// Value returns the Q-value of state and action, or 0 if it has not been
+// learned.
+func (agent *LambdaAgent) Value(state State, action Action) float32 {
+	return agent.q[state.String()][action.String()]
+}
+
+// Select implements Selector, choosing an action of state
+// epsilon-greedily, with ties broken at random. If the action chosen is
+// not one of the best, the traces are cleared.
+func (agent *LambdaAgent) Select(state State) *StateAction {
+	choice := epsilonGreedy(agent, state, agent.epsilon)
+	if choice == nil {
+		return nil
+	}
+
+	if best := bestActions(agent, state, 0); choice.Value < best[0].Value {
+		agent.NewEpisode()
+	}
+
+	return choice
+}
+
+// String returns the name of the agent, its trace decay, and its
+// exploration rate.
+func (agent *LambdaAgent) String() string {
+	return fmt.Sprintf("LambdaAgent(lambda %g, epsilon %g)", agent.lambda, agent.epsilon)
+}
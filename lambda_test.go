@@ -0,0 +1,146 @@
+package qlearning
+
+import (
+	"strconv"
+	"testing"
+)
+
+// line returns a graph in which each of n states has one action, a,
+// leading on to the next, so no state is visited twice.
+func line(n int) graph {
+	g := graph{}
+	for i := 0; i < n; i++ {
+		g["s"+strconv.Itoa(i)] = map[string]string{"a": "s" + strconv.Itoa(i+1)}
+	}
+
+	return g
+}
+
+func TestTraceCutoff(t *testing.T) {
+	g := line(30)
+
+	// Traces decay by half at every step, so with a cutoff of 0.1 only
+	// the last 3 actions, at 0.5, 0.25 and 0.125, are kept.
+	agent := NewLambdaAgent(0.1, 1, 0.5, 0)
+	agent.SetTraceCutoff(0.1)
+	for i := 0; i < 20; i++ {
+		agent.Learn(g.step("s"+strconv.Itoa(i), "a"), fixedReward(1))
+		want := i + 1
+		if want > 3 {
+			want = 3
+		}
+		if agent.Traces() != want {
+			t.Fatalf("%d traces after %d steps, want %d", agent.Traces(), i+1, want)
+		}
+	}
+
+	// Without a cutoff every action of the episode is kept.
+	agent.SetTraceCutoff(0)
+	agent.NewEpisode()
+	for i := 0; i < 20; i++ {
+		agent.Learn(g.step("s"+strconv.Itoa(i), "a"), fixedReward(1))
+	}
+	if agent.Traces() != 20 {
+		t.Errorf("%d traces after 20 steps without a cutoff, want 20", agent.Traces())
+	}
+}
+
+// maze returns a graph of an n by n grid of states named "x,y", whose
+// actions move one cell up, down, left or right, stopping at the edges.
+// The move that would reach the bottom-right corner is named win and
+// leads to the end instead, so that it is the only one rewarded by
+// rewards{"win": 1}, and the shortest way from "0,0" takes 2n-2 steps.
+func maze(n int) graph {
+	cell := func(x, y int) string {
+		if x < 0 {
+			x = 0
+		}
+		if y < 0 {
+			y = 0
+		}
+		if x >= n {
+			x = n - 1
+		}
+		if y >= n {
+			y = n - 1
+		}
+		if x == n-1 && y == n-1 {
+			return "end"
+		}
+		return strconv.Itoa(x) + "," + strconv.Itoa(y)
+	}
+
+	g := graph{}
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			moves := map[string]string{}
+			for name, to := range map[string]string{
+				"up":    cell(x, y-1),
+				"down":  cell(x, y+1),
+				"left":  cell(x-1, y),
+				"right": cell(x+1, y),
+			} {
+				if to == "end" {
+					name = "win"
+				}
+				moves[name] = to
+			}
+			g[cell(x, y)] = moves
+		}
+	}
+	delete(g, "end")
+
+	return g
+}
+
+// stepsToSolve trains agent on episodes of g from "0,0" until following
+// Best from there reaches the end in the fewest steps possible, and
+// returns the number of steps learned from by then, or -1 if that takes
+// more than 1000 episodes.
+func stepsToSolve(agent Agent, g graph, shortest int) int {
+	trainer := &Trainer{MaxStepsPerEpisode: 200}
+	steps := 0
+	for episode := 0; episode < 1000; episode++ {
+		steps += trainer.RunEpisode(agent, &walk{g, rewards{"win": 1}, "0,0"}).Steps
+
+		at := "0,0"
+		for i := 0; i < shortest && at != "end"; i++ {
+			at = g[at][Best(agent, g.at(at)).Action.String()]
+		}
+		if at == "end" {
+			return steps
+		}
+	}
+
+	return -1
+}
+
+func TestLambdaAgentSolvesFasterThanSimpleAgent(t *testing.T) {
+	const n = 6
+	g := maze(n)
+
+	// The reward is only found at the far corner. SimpleAgent carries it
+	// back one state per visit, while the traces of LambdaAgent carry it
+	// back along the whole path at once, so over a few fixed seeds the
+	// latter needs fewer steps in all before its greedy policy is optimal.
+	var lambdaSteps, simpleSteps int
+	for seed := int64(1); seed <= 5; seed++ {
+		lambda := NewLambdaAgent(0.5, 0.9, 0.9, 0.1)
+		lambda.SetSeed(seed)
+		simple := NewSimpleAgent(0.5, 0.9)
+		simple.SetSeed(seed)
+		simple.SetExplorationSchedule(Constant(0.1))
+
+		l, s := stepsToSolve(lambda, g, 2*n-2), stepsToSolve(simple, g, 2*n-2)
+		if l < 0 || s < 0 {
+			t.Fatalf("seed %d: LambdaAgent took %d steps and SimpleAgent %d, -1 meaning never solved", seed, l, s)
+		}
+		t.Logf("seed %d: LambdaAgent %d steps, SimpleAgent %d steps", seed, l, s)
+		lambdaSteps += l
+		simpleSteps += s
+	}
+
+	if lambdaSteps >= simpleSteps {
+		t.Errorf("LambdaAgent took %d steps to solve over all seeds, SimpleAgent %d, want fewer", lambdaSteps, simpleSteps)
+	}
+}
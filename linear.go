@@ -0,0 +1,162 @@
+package qlearning
+
+import (
+	"fmt"
+	"math"
+)
+
+// Featurizer describes a State and Action as a vector of features for
+// function approximation, such as whether the letter guessed is a vowel
+// and how many letters of the word are still hidden. Similar states and
+// actions should have similar features, as that is what an agent
+// learning from them generalizes over.
+type Featurizer interface {
+	Features(state State, action Action) []float32
+}
+
+// FeaturizerFunc is a function used as a Featurizer.
+type FeaturizerFunc func(state State, action Action) []float32
+
+// Features returns f(state, action).
+func (f FeaturizerFunc) Features(state State, action Action) []float32 {
+	return f(state, action)
+}
+
+// LinearAgent is an Agent approximating Q-values as a linear function of
+// features, rather than looking them up in a table: the Q-value of a
+// State and Action is the dot product of their features and a vector of
+// weights, which Learn adjusts by semi-gradient Q-learning:
+//
+//	delta = r + d*max Q(s') - Q(s, a)
+//	w += lr * delta * features(s, a)
+//
+// As every State and Action with a feature in common shares its weight,
+// what is learned about one carries over to others, including those
+// never seen, which a SimpleAgent knows nothing about. The cost is that
+// the values are only as good as the features allow, and that learning
+// is no longer guaranteed to converge, especially with large features
+// or rewards; SetGradientClipNorm guards against the weights blowing
+// up.
+//
+// The value of a next state is the highest Q-value of its actions, or 0
+// if it has none or the episode ends, as SimpleAgent.Learn describes.
+// Feature vectors need not all be the same length: weights are added as
+// longer ones are seen, and features past the end of a shorter one
+// count as 0.
+type LinearAgent struct {
+	randSource
+
+	features Featurizer
+	w        []float32
+	lr       float32
+	d        float32
+	epsilon  float32
+	clip     float32
+}
+
+// NewLinearAgent creates a LinearAgent with the provided Featurizer,
+// learning rate, and discount factor, exploring uniformly at random with
+// probability epsilon. Every weight starts at 0.
+func NewLinearAgent(features Featurizer, lr, d, epsilon float32) *LinearAgent {
+	return &LinearAgent{
+		features: features,
+		lr:       lr,
+		d:        d,
+		epsilon:  epsilon,
+	}
+}
+
+// SetGradientClipNorm limits the change Learn makes to the weights to a
+// Euclidean norm of at most n, scaling down any larger update while
+// keeping its direction. A large reward, or large features, makes a
+// large TD error and a large update, which overshoots and can feed on
+// itself until the weights overflow; clipping keeps each step bounded.
+// An n of 0 or less, the default, disables clipping.
+func (agent *LinearAgent) SetGradientClipNorm(n float32) {
+	agent.clip = n
+}
+
+// Learn applies the action and moves the weights along its features by
+// the learning rate times its temporal difference error, clipped as set
+// by SetGradientClipNorm.
+func (agent *LinearAgent) Learn(action *StateAction, reward Rewarder) {
+	features := agent.features.Features(action.State, action.Action)
+	value := dot(agent.w, features)
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	target := rewardOf(reward, action, nextState, nil)
+	if !ended(action, nextState) {
+		target += agent.d * agent.maxValue(nextState)
+	}
+
+	step := agent.lr * (target - value)
+
+	norm := float32(0.0)
+	for _, f := range features {
+		norm += (step * f) * (step * f)
+	}
+	if norm = float32(math.Sqrt(float64(norm))); agent.clip > 0 && norm > agent.clip {
+		step *= agent.clip / norm
+	}
+
+	for len(agent.w) < len(features) {
+		agent.w = append(agent.w, 0)
+	}
+	for i, f := range features {
+		agent.w[i] += step * f
+	}
+}
+
+// maxValue returns the highest Q-value of the actions of state, or 0 if
+// it has none.
+func (agent *LinearAgent) maxValue(state State) float32 {
+	max, found := float32(0.0), false
+	eachAction(state, func(action Action) bool {
+		if v := agent.Value(state, action); !found || v > max {
+			max, found = v, true
+		}
+		return true
+	})
+
+	return max
+}
+
+// dot returns the dot product of w and features, counting features past
+// the end of w as 0.
+func dot(w, features []float32) float32 {
+	sum := float32(0.0)
+	for i, f := range features {
+		if i < len(w) {
+			sum += w[i] * f
+		}
+	}
+
+	return sum
+}
+
+// Value returns the dot product of the features of state and action
+// and the weights.
+func (agent *LinearAgent) Value(state State, action Action) float32 {
+	return dot(agent.w, agent.features.Features(state, action))
+}
+
+// Weights returns a copy of the weights learned so far, one per feature.
+func (agent *LinearAgent) Weights() []float32 {
+	return append([]float32(nil), agent.w...)
+}
+
+// Select implements Selector, choosing an action of state
+// epsilon-greedily. Ties are broken at random.
+func (agent *LinearAgent) Select(state State) *StateAction {
+	return epsilonGreedy(agent, state, agent.epsilon)
+}
+
+// String returns the name of the agent, its number of weights, and its
+// exploration rate.
+func (agent *LinearAgent) String() string {
+	return fmt.Sprintf("LinearAgent(%d weights, epsilon %g)", len(agent.w), agent.epsilon)
+}
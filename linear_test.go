@@ -0,0 +1,135 @@
+package qlearning
+
+import (
+	"math"
+	"testing"
+)
+
+// bigFeatures gives every action of a graph large features, which make
+// large updates.
+var bigFeatures = FeaturizerFunc(func(state State, action Action) []float32 {
+	if action.String() == "a" {
+		return []float32{10, -20, 5}
+	}
+	return []float32{-30, 0, 40, 1}
+})
+
+// updateNorm returns the Euclidean norm of the change from before to
+// after, counting weights missing from before as 0.
+func updateNorm(before, after []float32) float32 {
+	sum := 0.0
+	for i, w := range after {
+		if i < len(before) {
+			w -= before[i]
+		}
+		sum += float64(w) * float64(w)
+	}
+
+	return float32(math.Sqrt(sum))
+}
+
+func TestGradientClipNorm(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "t"}, "t": {"a": "s", "b": "end"}}
+	agent := NewLinearAgent(bigFeatures, 0.5, 0.9, 0)
+	agent.SetGradientClipNorm(1)
+
+	clipped := 0
+	for i, r := range []float32{100, -250, 3, 1e4, -7, 0, 50, -1e3} {
+		before := agent.Weights()
+		from, action := "s", "a"
+		if i%2 == 1 {
+			from, action = "t", "b"
+		}
+		agent.Learn(g.step(from, action), fixedReward(r))
+
+		norm := updateNorm(before, agent.Weights())
+		if norm > 1+1e-5 {
+			t.Errorf("update %d (reward %g) changed the weights by a norm of %g, more than 1", i, r, norm)
+		}
+		if norm > 0.999 {
+			clipped++
+		}
+	}
+	if clipped == 0 {
+		t.Error("no update was large enough to be clipped")
+	}
+
+	for _, w := range agent.Weights() {
+		if math.IsNaN(float64(w)) || math.IsInf(float64(w), 0) {
+			t.Fatalf("weights %v are not finite", agent.Weights())
+		}
+	}
+}
+
+func TestGradientClipNormSmallUpdate(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewLinearAgent(bigFeatures, 0.001, 0, 0)
+	agent.SetGradientClipNorm(1)
+
+	// A step of 0.001*0.1 along features of norm sqrt(525) is well within
+	// the clip, and applied in full.
+	agent.Learn(g.step("s", "a"), fixedReward(0.1))
+	for i, f := range []float32{10, -20, 5} {
+		if w := agent.Weights()[i]; !near(w, 0.0001*f, 1e-9) {
+			t.Errorf("weight %d = %g, want %g", i, w, 0.0001*f)
+		}
+	}
+}
+
+// points are the states of a one-step problem with two features, x and
+// y: left is rewarded x and right y, and either ends the episode.
+var points = map[string][2]float32{
+	"p1": {1, 0}, "p2": {0, 1}, "p3": {0.5, 0.8}, "p4": {0.9, 0.3},
+	"held out": {0.2, 0.7},
+}
+
+// pointFeatures describes left by the x of its state and right by its y,
+// each in a feature of its own.
+var pointFeatures = FeaturizerFunc(func(state State, action Action) []float32 {
+	p := points[state.String()]
+	if action.String() == "left" {
+		return []float32{p[0], 0}
+	}
+	return []float32{0, p[1]}
+})
+
+func TestLinearAgentGeneralizes(t *testing.T) {
+	g := graph{}
+	for name := range points {
+		g[name] = map[string]string{"left": "end", "right": "end"}
+	}
+	pointReward := rewardFunc(func(sa *StateAction) float32 {
+		p := points[sa.State.String()]
+		if sa.Action.String() == "left" {
+			return p[0]
+		}
+		return p[1]
+	})
+
+	agent := NewLinearAgent(pointFeatures, 0.2, 0.9, 0)
+	for i := 0; i < 200; i++ {
+		for _, name := range []string{"p1", "p2", "p3", "p4"} {
+			agent.Learn(g.step(name, "left"), pointReward)
+			agent.Learn(g.step(name, "right"), pointReward)
+		}
+	}
+
+	// Never learned from, the held out state is valued by the weights
+	// the others taught, and is worth 0.2 going left and 0.7 right.
+	held := g.at("held out")
+	left, right := agent.Value(held, edge{g, "left", "end"}), agent.Value(held, edge{g, "right", "end"})
+	if right <= left {
+		t.Errorf("held out state values left %g and right %g, want right higher", left, right)
+	}
+	if !near(left, 0.2, 0.01) || !near(right, 0.7, 0.01) {
+		t.Errorf("held out state values left %g and right %g, want 0.2 and 0.7", left, right)
+	}
+	if got := Best(agent, held).Action.String(); got != "right" {
+		t.Errorf("Best chose %s in the held out state, want right", got)
+	}
+
+	// A table knows nothing of a state it has never seen.
+	if v := NewSimpleAgent(0.2, 0.9).Value(held, edge{g, "right", "end"}); v != 0 {
+		t.Errorf("SimpleAgent values the held out state %g, want 0", v)
+	}
+}
@@ -0,0 +1,39 @@
+package qlearning
+
+// Logger receives the diagnostics of the package, which prints nothing
+// itself. A *log.Logger from the standard library is a Logger, and any
+// structured logger can be adapted to one with a method formatting its
+// arguments. Nothing is logged unless a Logger is set, with
+// SimpleAgent.SetLogger or Trainer.Logger.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// SetLogger sets the Logger that Learn reports the errors it otherwise
+// ignores to, one message for each:
+//
+//   - an Action implementing FallibleAction that could not be applied;
+//   - an update exceeding the limit set by SetDivergenceLimit;
+//   - an update skipped with ErrNonFinite, for a reward that is NaN, or
+//     infinite without a reward clip, or for a Q-value that the update
+//     would make NaN or infinite.
+//
+// LearnE returns these instead, and logs nothing. A nil l, the default,
+// logs nothing.
+func (agent *SimpleAgent) SetLogger(l Logger) {
+	agent.logger = l
+}
+
+// logf logs to the Logger set with SetLogger, if any.
+func (agent *SimpleAgent) logf(format string, args ...interface{}) {
+	if agent.logger != nil {
+		agent.logger.Printf(format, args...)
+	}
+}
+
+// logf logs to Logger, if it is set.
+func (t *Trainer) logf(format string, args ...interface{}) {
+	if t.Logger != nil {
+		t.Logger.Printf(format, args...)
+	}
+}
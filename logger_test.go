@@ -0,0 +1,104 @@
+package qlearning
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// recorder is a Logger recording the messages it is given.
+type recorder struct {
+	messages []string
+}
+
+func (r *recorder) Printf(format string, args ...interface{}) {
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func TestSetLogger(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	for _, tc := range []struct {
+		name   string
+		setup  func(agent *SimpleAgent)
+		learn  func(agent *SimpleAgent)
+		inText string
+	}{
+		{
+			name: "failed action",
+			learn: func(agent *SimpleAgent) {
+				w := &walk{g: graph{"s": {"a": "fail"}}, at: "s"}
+				agent.Learn(NewStateAction(w, hop{"a", "fail"}, 0), fixedReward(1))
+			},
+			inText: "move to fail",
+		},
+		{
+			name:  "divergence",
+			setup: func(agent *SimpleAgent) { agent.SetDivergenceLimit(10) },
+			learn: func(agent *SimpleAgent) {
+				agent.Learn(g.step("s", "a"), fixedReward(100))
+			},
+			inText: ErrDiverged.Error(),
+		},
+		{
+			name: "NaN reward",
+			learn: func(agent *SimpleAgent) {
+				agent.Learn(g.step("s", "a"), fixedReward(float32(math.NaN())))
+			},
+			inText: ErrNonFinite.Error(),
+		},
+		{
+			name: "infinite Q-value",
+			learn: func(agent *SimpleAgent) {
+				agent.q.Set("t", "b", math.MaxFloat32)
+				graph := graph{"s": {"a": "t"}}
+				agent.Learn(graph.step("s", "a"), fixedReward(math.MaxFloat32))
+			},
+			inText: ErrNonFinite.Error(),
+		},
+	} {
+		agent := NewSimpleAgent(1, 1)
+		if tc.setup != nil {
+			tc.setup(agent)
+		}
+		var log recorder
+		agent.SetLogger(&log)
+		tc.learn(agent)
+
+		if len(log.messages) != 1 || !strings.Contains(log.messages[0], tc.inText) {
+			t.Errorf("%s: logged %q, want one message containing %q", tc.name, log.messages, tc.inText)
+		}
+	}
+}
+
+func TestSetLoggerQuiet(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 1)
+	var log recorder
+	agent.SetLogger(&log)
+
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+	w := &walk{g: graph{"s": {"a": "fail"}}, at: "s"}
+	if err := agent.LearnE(NewStateAction(w, hop{"a", "fail"}, 0), fixedReward(1)); err == nil {
+		t.Error("LearnE of a failing action returned no error")
+	}
+	if len(log.messages) != 0 {
+		t.Errorf("logged %q for an update and a LearnE, want nothing", log.messages)
+	}
+}
+
+func TestTrainerLogger(t *testing.T) {
+	var log recorder
+	trainer := &Trainer{MaxStepsPerEpisode: 3, Logger: &log}
+	trainer.RunEpisode(NewSimpleAgent(1, 1), &walk{g: graph{"s": {"a": "s"}}, at: "s"})
+
+	if len(log.messages) != 1 || !strings.Contains(log.messages[0], "timed out after 3 steps") {
+		t.Errorf("logged %q, want the episode timing out", log.messages)
+	}
+
+	log.messages = nil
+	trainer.RunEpisode(NewSimpleAgent(1, 1), newStuckWalk())
+	if len(log.messages) != 1 || !strings.Contains(log.messages[0], "ended after 1 steps") {
+		t.Errorf("logged %q, want the episode reaching a state with no actions", log.messages)
+	}
+}
@@ -0,0 +1,87 @@
+package qlearning
+
+import "math"
+
+// NextLookahead chooses an action for state by searching depth steps
+// ahead instead of trusting the agent's Q-values alone. Every action is
+// applied to state, rewarded with rewarder, and scored as
+//
+//	reward + discount * V(next)
+//
+// where V(next) is the best such score over the actions of next with
+// one less step to go, and at the last step the highest Q-value of the
+// agent for next. A next state with no actions is worth 0. The discount
+// is the agent's, if it has a Discount method as SimpleAgent does, and
+// 1 otherwise. The returned StateAction holds the winning score as its
+// Value, and ties are broken at random. With a depth below 1,
+// NextLookahead is Next.
+//
+// This trades compute for better choices without further learning: a
+// search of depth k applies up to b^k actions for states with b actions
+// each. Nothing is learned from the search.
+//
+// Every candidate action is applied to the same state, so Apply must be
+// pure, returning a new State and leaving its argument unchanged. An
+// Environment changed in place by Apply, such as the hangman example's
+// Game, cannot be searched this way.
+func NextLookahead(agent Agent, state State, rewarder Rewarder, depth int) *StateAction {
+	if depth < 1 {
+		return Next(agent, state)
+	}
+
+	discount := float32(1.0)
+	if d, ok := agent.(interface{ Discount() float32 }); ok {
+		discount = d.Discount()
+	}
+
+	score := func(action Action) float32 {
+		return lookaheadScore(agent, state, action, rewarder, discount, depth)
+	}
+
+	best := scoreBest(func(fn func(Action) bool) { eachAction(state, fn) }, score, 0)
+	if len(best) == 0 {
+		return nil
+	}
+
+	choice := best[randIntn(agentRand(agent), len(best))]
+	return NewStateAction(state, choice.action, choice.value)
+}
+
+// lookaheadScore returns the score of applying action to state with
+// depth steps to go.
+func lookaheadScore(agent Agent, state State, action Action, rewarder Rewarder, discount float32, depth int) float32 {
+	sa := NewStateAction(state, action, 0)
+
+	next, err := applyAction(action, state)
+	if err != nil {
+		// An action that cannot be applied is never worth choosing over
+		// one that can; score it below anything a search can reach.
+		return -math.MaxFloat32
+	}
+
+	reward := rewardOf(rewarder, sa, next, nil)
+
+	var value float32
+	if depth > 1 {
+		value = lookaheadValue(next, func(a Action) float32 {
+			return lookaheadScore(agent, next, a, rewarder, discount, depth-1)
+		})
+	} else {
+		value = lookaheadValue(next, func(a Action) float32 {
+			return agent.Value(next, a)
+		})
+	}
+
+	return reward + discount*value
+}
+
+// lookaheadValue returns the best score of the actions of state, or 0 if
+// it has none.
+func lookaheadValue(state State, score func(Action) float32) float32 {
+	best := scoreBest(func(fn func(Action) bool) { eachAction(state, fn) }, score, 0)
+	if len(best) == 0 {
+		return 0
+	}
+
+	return best[0].value
+}
@@ -0,0 +1,30 @@
+// Package matrix exports the Q-values of a qlearning.Agent as gonum
+// matrices for linear algebra, spectral analysis, or visualization.
+//
+// It is a separate package so that the qlearning package itself does
+// not depend on gonum.
+package matrix
+
+import (
+	"github.com/ecooper/qlearning"
+	"gonum.org/v1/gonum/mat"
+)
+
+// ValueMatrix returns a len(states) by len(actions) matrix whose element
+// (i, j) is the agent's Q-value for states[i] and actions[j]. State and
+// Action pairs the agent has not learned have whatever value the agent
+// reports for them, usually 0.
+//
+// ValueMatrix panics if states or actions is empty, as gonum does not
+// allow empty matrices.
+func ValueMatrix(agent qlearning.Agent, states []qlearning.State, actions []qlearning.Action) *mat.Dense {
+	m := mat.NewDense(len(states), len(actions), nil)
+
+	for i, state := range states {
+		for j, action := range actions {
+			m.Set(i, j, float64(agent.Value(state, action)))
+		}
+	}
+
+	return m
+}
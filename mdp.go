@@ -0,0 +1,87 @@
+package qlearning
+
+import "math"
+
+// MDP is a small, fully known Markov decision process, described by the
+// string representations of its states and actions, for computing exact
+// Q-values with ValueIteration.
+type MDP struct {
+	// States lists every state.
+	States []string
+
+	// Actions returns the actions of a state. A state with no actions is
+	// terminal and worth 0.
+	Actions func(state string) []string
+
+	// Transition returns the probability of each state an action can
+	// lead to. The probabilities should sum to 1.
+	Transition func(state, action string) map[string]float32
+
+	// Reward returns the reward for taking action in state and reaching
+	// next.
+	Reward func(state, action, next string) float32
+
+	// Discount is the discount factor, as given to NewSimpleAgent.
+	Discount float32
+}
+
+// ValueIteration returns the optimal Q-value of every state and action
+// of mdp, keyed as StateAction.Key keys them, so that the Q-values
+// learned by an agent can be checked against ground truth. It iterates
+// the Bellman optimality update until no Q-value changes by more than
+// 1e-6, or 100000 sweeps have been made, which is only possible if the
+// discount is 1 and some states never reach a terminal one.
+//
+// ValueIteration makes a full sweep over every state, action, and
+// transition per iteration, so it is only suited to small MDPs.
+func ValueIteration(mdp MDP) map[string]float32 {
+	const (
+		tolerance = 1e-6
+		maxSweeps = 100000
+	)
+
+	v := make(map[string]float64, len(mdp.States))
+	q := make(map[string]float64)
+
+	for sweep := 0; sweep < maxSweeps; sweep++ {
+		change := 0.0
+
+		for _, state := range mdp.States {
+			for _, action := range mdp.Actions(state) {
+				value := 0.0
+				for next, p := range mdp.Transition(state, action) {
+					r := float64(mdp.Reward(state, action, next))
+					value += float64(p) * (r + float64(mdp.Discount)*v[next])
+				}
+
+				key := cellKey(state, action)
+				change = math.Max(change, math.Abs(value-q[key]))
+				q[key] = value
+			}
+		}
+
+		for _, state := range mdp.States {
+			actions := mdp.Actions(state)
+			if len(actions) == 0 {
+				continue
+			}
+
+			best := math.Inf(-1)
+			for _, action := range actions {
+				best = math.Max(best, q[cellKey(state, action)])
+			}
+			v[state] = best
+		}
+
+		if change <= tolerance {
+			break
+		}
+	}
+
+	values := make(map[string]float32, len(q))
+	for key, value := range q {
+		values[key] = float32(value)
+	}
+
+	return values
+}
@@ -0,0 +1,122 @@
+package qlearning
+
+import "testing"
+
+// mdpOf returns the MDP of the deterministic graph g, rewarded by reward
+// and discounted by discount.
+func mdpOf(g graph, reward rewards, discount float32) MDP {
+	var states []string
+	for state, actions := range g {
+		states = append(states, state)
+		for _, to := range actions {
+			states = append(states, to)
+		}
+	}
+
+	return MDP{
+		States: states,
+		Actions: func(state string) []string {
+			var actions []string
+			for _, action := range g.at(state).Next() {
+				actions = append(actions, action.String())
+			}
+			return actions
+		},
+		Transition: func(state, action string) map[string]float32 {
+			return map[string]float32{g[state][action]: 1}
+		},
+		Reward: func(state, action, next string) float32 {
+			return reward[action]
+		},
+		Discount: discount,
+	}
+}
+
+// TestChainConverges trains a SimpleAgent on a chain of five states, s0
+// to s4, whose optimal Q-values are known exactly, and checks that the
+// learned values converge to them. From every state but the last the
+// agent can move left or right, moving left from s0 staying there, and
+// only finishing, moving right from s3 into s4, which ends the episode,
+// is rewarded, so with discount d the optimal value of moving right from
+// s is d^(3-s). Updates are drawn by a seeded Sampler, so the test is
+// reproducible; it guards the core update math against regressions as
+// features are added.
+func TestChainConverges(t *testing.T) {
+	const (
+		discount  = 0.9
+		rate      = 0.5
+		steps     = 20000
+		tolerance = 0.001
+	)
+
+	g := graph{
+		"s0": {"left": "s0", "right": "s1"},
+		"s1": {"left": "s0", "right": "s2"},
+		"s2": {"left": "s1", "right": "s3"},
+		"s3": {"left": "s2", "finish": "s4"},
+	}
+	reward := rewards{"finish": 1}
+
+	var pairs []*StateAction
+	for _, state := range []string{"s0", "s1", "s2", "s3"} {
+		for _, action := range g.at(state).Next() {
+			pairs = append(pairs, NewStateAction(g.at(state), action, 0))
+		}
+	}
+
+	agent := NewSimpleAgent(rate, discount)
+	sampler := NewSampler(len(pairs), 1, false)
+	for i := 0; i < steps; i++ {
+		agent.Learn(pairs[sampler.Next()], reward)
+	}
+
+	optimal := ValueIteration(mdpOf(g, reward, discount))
+	for _, pair := range pairs {
+		learned, want := agent.Value(pair.State, pair.Action), optimal[pair.Key()]
+		if !near(learned, want, tolerance) {
+			t.Errorf("%s %s: learned %.4f, optimal %.4f", pair.State, pair.Action, learned, want)
+		}
+	}
+}
+
+func TestValueIteration(t *testing.T) {
+	// From s, go ends the episode with a reward of 1 half the time and
+	// otherwise stays in s, and quit ends it with a reward of 0.2, so
+	// Q(s, go) = 0.5*1 + 0.5*0.9*Q(s, go) = 0.5/0.55.
+	mdp := MDP{
+		States: []string{"s", "end"},
+		Actions: func(state string) []string {
+			if state == "end" {
+				return nil
+			}
+			return []string{"go", "quit"}
+		},
+		Transition: func(state, action string) map[string]float32 {
+			if action == "go" {
+				return map[string]float32{"end": 0.5, "s": 0.5}
+			}
+			return map[string]float32{"end": 1}
+		},
+		Reward: func(state, action, next string) float32 {
+			switch {
+			case action == "quit":
+				return 0.2
+			case next == "end":
+				return 1
+			}
+			return 0
+		},
+		Discount: 0.9,
+	}
+
+	q := ValueIteration(mdp)
+	if len(q) != 2 {
+		t.Errorf("ValueIteration = %v, want the 2 Q-values of s", q)
+	}
+	if got := q[cellKey("s", "go")]; !near(got, 0.5/0.55, 1e-5) {
+		t.Errorf("Q(s, go) = %g, want %g", got, 0.5/0.55)
+	}
+	if got := q[cellKey("s", "quit")]; !near(got, 0.2, 1e-6) {
+		t.Errorf("Q(s, quit) = %g, want 0.2", got)
+	}
+}
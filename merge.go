@@ -0,0 +1,140 @@
+package qlearning
+
+// MergeStrategy combines the Q-values two agents have learned for the
+// same State and Action into one, given each value and the number of
+// updates behind it.
+type MergeStrategy func(a float32, aVisits int, b float32, bVisits int) float32
+
+// MergeMax is a MergeStrategy keeping the higher of the two values.
+func MergeMax(a float32, aVisits int, b float32, bVisits int) float32 {
+	if b > a {
+		return b
+	}
+
+	return a
+}
+
+// MergeMean is a MergeStrategy averaging the two values.
+func MergeMean(a float32, aVisits int, b float32, bVisits int) float32 {
+	return (a + b) / 2
+}
+
+// MergeVisitWeighted is a MergeStrategy averaging the two values
+// weighted by the number of updates behind each, so the value learned
+// from more experience counts for more. If neither has been updated, it
+// is the plain average.
+func MergeVisitWeighted(a float32, aVisits int, b float32, bVisits int) float32 {
+	total := aVisits + bVisits
+	if total == 0 {
+		return MergeMean(a, aVisits, b, bVisits)
+	}
+
+	return (a*float32(aVisits) + b*float32(bVisits)) / float32(total)
+}
+
+// MergeWeighted returns a MergeStrategy blending the two values as
+//
+//	(1-weight)*a + weight*b
+//
+// so that a weight of 0 keeps the receiver's value, 1 takes the other
+// agent's, and 0.5 is MergeMean. Blending a value with itself leaves it
+// unchanged at any weight.
+func MergeWeighted(weight float32) MergeStrategy {
+	return func(a float32, aVisits int, b float32, bVisits int) float32 {
+		return (1-weight)*a + weight*b
+	}
+}
+
+// Merge combines the Q-values learned by other into the agent. A State
+// and Action learned by both agents gets the value chosen by strategy
+// and the sum of both agents' update counts. One learned only by other
+// is copied, along with its update count, and one learned only by the
+// agent is kept unchanged. other is not modified.
+func (agent *SimpleAgent) Merge(other *SimpleAgent, strategy MergeStrategy) {
+	other.Range(func(state, action string, theirs float32) bool {
+		theirVisits := other.n[state][action]
+
+		if mine, ok := agent.q.Get(state, action); ok {
+			myVisits := agent.n[state][action]
+			agent.setValue(state, action, strategy(mine, myVisits, theirs, theirVisits))
+		} else {
+			agent.setValue(state, action, theirs)
+		}
+
+		if theirVisits > 0 {
+			if _, ok := agent.n[state]; !ok {
+				agent.n[state] = make(map[string]int)
+			}
+			agent.n[state][action] += theirVisits
+		}
+
+		return true
+	})
+}
+
+// AverageAgents returns a new agent whose Q-values are the visit-weighted
+// average of the given agents' Q-values, as in federated averaging.
+//
+// The new agent learns every State and Action learned by any of the
+// agents. Each Q-value is averaged only over the agents that learned it,
+// weighted by their update counts, so an agent that never saw a state
+// does not drag its value toward the default. Q-values that none of
+// those agents updated, such as ones copied in by Merge, are averaged
+// equally. Update counts and steps are summed, and the learning rate,
+// discount, and default value are taken from the first agent.
+//
+// AverageAgents returns nil if agents is empty.
+func AverageAgents(agents []*SimpleAgent) *SimpleAgent {
+	if len(agents) == 0 {
+		return nil
+	}
+
+	avg := NewSimpleAgent(agents[0].lr, agents[0].d)
+	avg.init = agents[0].init
+
+	type sum struct {
+		weighted, plain float32
+		visits, count   int
+	}
+	sums := make(map[string]map[string]*sum)
+
+	for _, agent := range agents {
+		avg.steps += agent.steps
+
+		agent.Range(func(state, action string, value float32) bool {
+			if _, ok := sums[state]; !ok {
+				sums[state] = make(map[string]*sum)
+			}
+			s, ok := sums[state][action]
+			if !ok {
+				s = &sum{}
+				sums[state][action] = s
+			}
+
+			visits := agent.n[state][action]
+			s.weighted += value * float32(visits)
+			s.plain += value
+			s.visits += visits
+			s.count++
+
+			return true
+		})
+	}
+
+	for state, actions := range sums {
+		for action, s := range actions {
+			if s.visits == 0 {
+				avg.q.Set(state, action, s.plain/float32(s.count))
+				continue
+			}
+
+			avg.q.Set(state, action, s.weighted/float32(s.visits))
+			if _, ok := avg.n[state]; !ok {
+				avg.n[state] = make(map[string]int)
+			}
+			avg.n[state][action] = s.visits
+		}
+	}
+
+	return avg
+}
@@ -0,0 +1,117 @@
+package qlearning
+
+import "testing"
+
+func TestMergeVisitWeighted(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+
+	// a learns s/a three times and s/b once; b learns s/a once and s/c.
+	a := NewSimpleAgent(1, 0)
+	for i := 0; i < 3; i++ {
+		a.Learn(g.step("s", "a"), fixedReward(2))
+	}
+	a.Learn(g.step("s", "b"), fixedReward(5))
+	b := NewSimpleAgent(1, 0)
+	b.Learn(g.step("s", "a"), fixedReward(6))
+	b.Learn(g.step("s", "c"), fixedReward(7))
+
+	a.Merge(b, MergeVisitWeighted)
+
+	want := map[string]struct {
+		value  float32
+		visits int
+	}{
+		"a": {(3*2 + 1*6) / 4.0, 4},
+		"b": {5, 1},
+		"c": {7, 1},
+	}
+	for action, w := range want {
+		e := edge{g, action, "end"}
+		if v, n := a.Value(g.at("s"), e), a.Visits(g.at("s"), e); v != w.value || n != w.visits {
+			t.Errorf("%s: merged value %g from %d updates, want %g from %d", action, v, n, w.value, w.visits)
+		}
+	}
+	if v := b.Value(g.at("s"), edge{g, "a", "end"}); v != 6 {
+		t.Errorf("other agent changed to %g by Merge, want 6", v)
+	}
+}
+
+func TestMergeStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy MergeStrategy
+		want     float32
+	}{
+		{"max", MergeMax, 4},
+		{"mean", MergeMean, 3},
+		{"visit weighted", MergeVisitWeighted, 2.5},
+	}
+	for _, test := range tests {
+		if got := test.strategy(2, 3, 4, 1); got != test.want {
+			t.Errorf("%s(2 from 3, 4 from 1) = %g, want %g", test.name, got, test.want)
+		}
+	}
+
+	if got := MergeVisitWeighted(2, 0, 4, 0); got != 3 {
+		t.Errorf("visit weighted with no updates = %g, want the mean 3", got)
+	}
+}
+
+func TestMergeWeighted(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	train := func(rewards map[string]float32) *SimpleAgent {
+		agent := NewSimpleAgent(1, 0)
+		for action, r := range rewards {
+			agent.Learn(g.step("s", action), fixedReward(r))
+		}
+		return agent
+	}
+
+	for _, tc := range []struct {
+		weight  float32
+		a, b, c float32
+	}{
+		{0, 2, 5, 7},
+		{0.25, 3, 5, 7},
+		{1, 6, 5, 7},
+	} {
+		// Only the receiver has b, and only the other agent c, so they
+		// are kept and copied whatever the weight.
+		mine, theirs := train(map[string]float32{"a": 2, "b": 5}), train(map[string]float32{"a": 6, "c": 7})
+		mine.Merge(theirs, MergeWeighted(tc.weight))
+
+		for action, want := range map[string]float32{"a": tc.a, "b": tc.b, "c": tc.c} {
+			if v := mine.Value(g.at("s"), edge{g, action, "end"}); v != want {
+				t.Errorf("weight %g: Q(s, %s) = %g, want %g", tc.weight, action, v, want)
+			}
+		}
+	}
+}
+
+func TestMergeWithItself(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "end"}, "t": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0.9)
+	for i, step := range [][2]string{{"s", "a"}, {"t", "a"}, {"s", "b"}, {"s", "a"}} {
+		agent.Learn(g.step(step[0], step[1]), fixedReward(float32(i)))
+	}
+
+	before := map[string]float32{}
+	agent.Range(func(state, action string, v float32) bool {
+		before[cellKey(state, action)] = v
+		return true
+	})
+
+	agent.Merge(agent, MergeWeighted(0.5))
+
+	after := 0
+	agent.Range(func(state, action string, v float32) bool {
+		after++
+		if want := before[cellKey(state, action)]; v != want {
+			t.Errorf("Q(%s, %s) = %g after merging with itself, want %g", state, action, v, want)
+		}
+		return true
+	})
+	if after != len(before) {
+		t.Errorf("%d Q-values after merging with itself, want %d", after, len(before))
+	}
+}
@@ -0,0 +1,176 @@
+package qlearning
+
+import "fmt"
+
+// NStepAgent is an Agent learning with n-step Q-learning. Where
+// SimpleAgent updates a Q-value toward its reward and the value of the
+// next state, NStepAgent waits n steps and updates it toward the
+// discounted rewards of all of them and the value of the state reached
+// after the last:
+//
+//	Q(s, a) += lr * (r0 + d*r1 + ... + d^(n-1)*r(n-1) + d^n*max Q(s_n) - Q(s, a))
+//
+// so a reward reaches the n states before it in a single episode, rather
+// than moving back one state per episode, which speeds up learning from
+// sparse rewards such as winning a game of hangman. The cost is that the
+// update includes the following actions as they were taken, exploration
+// included.
+//
+// As in SimpleAgent.Learn, the value of a state is the highest of its
+// recorded Q-values and 0, the value of an action not tried yet. When an
+// action ends the episode, as SimpleAgent.Learn describes, every update
+// still waiting is made toward the rest of the rewards alone.
+//
+// Rewards are discounted geometrically by the discount factor unless a
+// Discounter is set with SetDiscounter.
+type NStepAgent struct {
+	randSource
+
+	q          map[string]map[string]float32
+	lr         float32
+	d          float32
+	discounter Discounter
+	n          int
+	epsilon    float32
+
+	// pending are the steps not yet learned from, oldest first, and
+	// last the string representation of the state the newest led to.
+	pending []nstep
+	last    string
+}
+
+// nstep is a step waiting for its n-step return.
+type nstep struct {
+	state, action string
+	reward        float32
+}
+
+// NewNStepAgent creates an NStepAgent with the provided learning rate
+// and discount factor, learning from returns of n steps, or 1 if n is
+// less, and exploring uniformly at random with probability epsilon.
+func NewNStepAgent(lr, d float32, n int, epsilon float32) *NStepAgent {
+	if n < 1 {
+		n = 1
+	}
+
+	return &NStepAgent{
+		q:       make(map[string]map[string]float32),
+		lr:      lr,
+		d:       d,
+		n:       n,
+		epsilon: epsilon,
+	}
+}
+
+// SetDiscounter makes the agent weight the reward i steps after the one
+// it updates with discounter.Discount(i), as DiscountedReturnsWith does,
+// and the value of the state reached after n steps with
+// discounter.Discount(n), in place of powers of its discount factor. A
+// nil discounter, the default, goes back to the discount factor.
+func (agent *NStepAgent) SetDiscounter(discounter Discounter) {
+	agent.discounter = discounter
+}
+
+// Learn applies the action and records its reward, then updates the
+// Q-value of the step n steps back, if there is one, or of every step
+// still waiting if the action ends the episode.
+//
+// If action does not start from the state the previous action led to,
+// such as when an episode was cut short by a step limit, the steps still
+// waiting are first learned from as they are, bootstrapping from the
+// state they stopped at.
+func (agent *NStepAgent) Learn(action *StateAction, reward Rewarder) {
+	s, a := action.State.String(), action.Action.String()
+	if len(agent.pending) > 0 && s != agent.last {
+		agent.flush(true)
+	}
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	r := rewardOf(reward, action, nextState, nil)
+	agent.pending = append(agent.pending, nstep{s, a, r})
+	agent.last = nextState.String()
+
+	if ended(action, nextState) {
+		agent.flush(false)
+	} else if len(agent.pending) == agent.n {
+		agent.update(true)
+	}
+}
+
+// Flush learns from every step still waiting as if the episode had ended
+// after the last, toward the rest of the rewards alone. Learn flushes by
+// itself at the end of an episode it can tell has ended; Flush is for
+// episodes that end any other way.
+func (agent *NStepAgent) Flush() {
+	agent.flush(false)
+}
+
+// NewEpisode implements EpisodeResetter. Every step still waiting is
+// learned from as it is, bootstrapping from the state it stopped at, as
+// for an episode cut short: steps of an episode known to have ended
+// have already been learned from by Learn.
+func (agent *NStepAgent) NewEpisode() {
+	agent.flush(true)
+}
+
+// flush updates every step still waiting, bootstrapping from the last
+// state if bootstrap is set.
+func (agent *NStepAgent) flush(bootstrap bool) {
+	for len(agent.pending) > 0 {
+		agent.update(bootstrap)
+	}
+}
+
+// update updates the Q-value of the oldest step waiting toward the
+// discounted rewards of every step waiting, and, if bootstrap is set,
+// the value of the last state, then stops it waiting.
+func (agent *NStepAgent) update(bootstrap bool) {
+	target := float32(0.0)
+	discount := float32(1.0)
+	if agent.discounter != nil {
+		for i, step := range agent.pending {
+			target += agent.discounter.Discount(i) * step.reward
+		}
+		discount = agent.discounter.Discount(len(agent.pending))
+	} else {
+		for _, step := range agent.pending {
+			target += discount * step.reward
+			discount *= agent.d
+		}
+	}
+	if bootstrap {
+		target += discount * maxRecorded(agent.q[agent.last])
+	}
+
+	oldest := agent.pending[0]
+	if _, ok := agent.q[oldest.state]; !ok {
+		agent.q[oldest.state] = make(map[string]float32)
+	}
+
+	old := agent.q[oldest.state][oldest.action]
+	agent.q[oldest.state][oldest.action] = old + agent.lr*(target-old)
+
+	agent.pending = agent.pending[1:]
+}
+
+// Value returns the Q-value of state and action, or 0 if it has not been
+// learned.
+func (agent *NStepAgent) Value(state State, action Action) float32 {
+	return agent.q[state.String()][action.String()]
+}
+
+// Select implements Selector, choosing an action of state
+// epsilon-greedily. Ties are broken at random.
+func (agent *NStepAgent) Select(state State) *StateAction {
+	return epsilonGreedy(agent, state, agent.epsilon)
+}
+
+// String returns the name of the agent, its number of steps, and its
+// exploration rate.
+func (agent *NStepAgent) String() string {
+	return fmt.Sprintf("NStepAgent(n %d, epsilon %g)", agent.n, agent.epsilon)
+}
@@ -0,0 +1,72 @@
+package qlearning
+
+import "testing"
+
+func TestNStepAgent(t *testing.T) {
+	g := graph{"s0": {"a": "s1"}, "s1": {"a": "s2"}, "s2": {"a": "s3"}, "s3": {"a": "end"}}
+	agent := NewNStepAgent(1, 0.5, 2, 0)
+
+	// Nothing is learned until n steps are waiting.
+	agent.Learn(g.step("s0", "a"), rewards{"a": 1})
+	if v := agent.Value(g.at("s0"), edge{g, "a", "s1"}); v != 0 {
+		t.Errorf("Q(s0) = %g after one of 2 steps, want 0", v)
+	}
+
+	agent.Learn(g.step("s1", "a"), rewards{"a": 1})
+	if v := agent.Value(g.at("s0"), edge{g, "a", "s1"}); v != 1.5 {
+		t.Errorf("Q(s0) = %g, want 1 + 0.5*1", v)
+	}
+}
+
+func TestNStepAgentPropagation(t *testing.T) {
+	// Only the last step of the chain is rewarded.
+	g := graph{"s0": {"a": "s1"}, "s1": {"a": "s2"}, "s2": {"a": "s3"}, "s3": {"a": "s4"}, "s4": {"win": "end"}}
+	reward := rewards{"win": 1}
+	chain := []*StateAction{g.step("s0", "a"), g.step("s1", "a"), g.step("s2", "a"), g.step("s3", "a"), g.step("s4", "win")}
+
+	nstep := NewNStepAgent(1, 1, 3, 0)
+	simple := NewSimpleAgent(1, 1)
+	for _, step := range chain {
+		nstep.Learn(step, reward)
+		simple.Learn(step, reward)
+	}
+	nstep.Flush()
+
+	// In one episode the reward reaches the 3 states before it with 3
+	// steps, and only the last with one.
+	for i, step := range chain {
+		want := float32(0)
+		if i >= 2 {
+			want = 1
+		}
+		if v := nstep.Value(step.State, step.Action); v != want {
+			t.Errorf("n-step Q(%s) = %g after one episode, want %g", step.State, v, want)
+		}
+
+		want = 0
+		if i == 4 {
+			want = 1
+		}
+		if v := simple.Value(step.State, step.Action); v != want {
+			t.Errorf("one-step Q(%s) = %g after one episode, want %g", step.State, v, want)
+		}
+	}
+}
+
+func TestNStepAgentDiscounter(t *testing.T) {
+	g := graph{"s0": {"a": "s1"}, "s1": {"a": "s2"}, "s2": {"a": "s3"}, "s3": {"a": "end"}}
+	reward := rewards{"a": 1}
+
+	agent := NewNStepAgent(1, 0.5, 3, 0)
+	agent.SetDiscounter(Hyperbolic(1))
+	agent.Learn(g.step("s3", "a"), reward)
+	agent.Flush()
+	for _, state := range []string{"s0", "s1", "s2"} {
+		agent.Learn(g.step(state, "a"), reward)
+	}
+
+	// 1 + 1/2 + 1/3 for the rewards, and 1/4 of Q(s3) = 1.
+	if v := agent.Value(g.at("s0"), edge{g, "a", "s1"}); !near(v, 1+0.5+1.0/3+0.25*1, 1e-6) {
+		t.Errorf("Q(s0) = %g with hyperbolic discounting, want %g", v, 1+0.5+1.0/3+0.25)
+	}
+}
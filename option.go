@@ -0,0 +1,98 @@
+package qlearning
+
+// Option is an Action that is extended in time: applying it runs a
+// policy of its own over primitive actions until it terminates. Because
+// an Option is an Action, a State can offer options from Next alongside
+// primitive actions and an Agent learns their values like any other.
+//
+// See Sutton, Precup, and Singh, "Between MDPs and semi-MDPs" (1999).
+type Option interface {
+	Action
+
+	// Terminated reports whether the option stops in state.
+	Terminated(state State) bool
+}
+
+// AgentOption is an Option that follows the greedy policy of an Agent,
+// typically one trained beforehand on a subtask. It is the simplest way
+// to compose trained agents into a hierarchy:
+//
+//	reach := &AgentOption{
+//		Name:     "reach-door",
+//		Agent:    doorAgent,
+//		Until:    func(s State) bool { return s.(*Room).AtDoor() },
+//		MaxSteps: 50,
+//		Rewarder: room,
+//		Discount: 0.9,
+//	}
+//
+// An outer agent then learns the value of reach-door as a single action.
+// Because the outer agent sees a single reward for the whole option, its
+// Rewarder should return the option's accumulated Reward:
+//
+//	func (r *Room) Reward(sa *StateAction) float32 {
+//		if opt, ok := sa.Action.(*AgentOption); ok {
+//			return opt.Reward
+//		}
+//		...
+//	}
+type AgentOption struct {
+	Name  string
+	Agent Agent
+
+	// Until reports whether the option stops in a State. The option
+	// also stops in a State with no actions.
+	Until func(State) bool
+
+	// MaxSteps, if positive, limits the number of primitive actions a
+	// single Apply takes.
+	MaxSteps int
+
+	// Rewarder, if set, rewards each primitive action taken, and
+	// Discount discounts them into Reward.
+	Rewarder Rewarder
+	Discount float32
+
+	// Reward and Steps are the discounted reward accumulated and the
+	// number of primitive actions taken by the most recent Apply.
+	Reward float32
+	Steps  int
+}
+
+// String returns the name of the option.
+func (option *AgentOption) String() string {
+	return option.Name
+}
+
+// Terminated reports whether the option stops in state.
+func (option *AgentOption) Terminated(state State) bool {
+	return option.Until != nil && option.Until(state)
+}
+
+// Apply follows the option's Agent from state, choosing each primitive
+// action with Next, until the option terminates or reaches a State with
+// no actions, and returns the State reached. Nothing is learned by the option's Agent.
+func (option *AgentOption) Apply(state State) State {
+	option.Reward = 0
+	option.Steps = 0
+
+	discount := float32(1.0)
+	for !option.Terminated(state) && (option.MaxSteps <= 0 || option.Steps < option.MaxSteps) {
+		sa := Next(option.Agent, state)
+		if sa == nil {
+			break
+		}
+
+		next := sa.Action.Apply(state)
+
+		if option.Rewarder != nil {
+			option.Reward += discount * rewardOf(option.Rewarder, sa, next, nil)
+			discount *= option.Discount
+		}
+
+		state = next
+		option.Steps++
+	}
+
+	return state
+}
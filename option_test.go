@@ -0,0 +1,38 @@
+package qlearning
+
+import "testing"
+
+func TestAgentOption(t *testing.T) {
+	g := graph{"s0": {"a": "s1"}, "s1": {"b": "s2"}, "s2": {"c": "s3"}}
+
+	tests := []struct {
+		name   string
+		option AgentOption
+		at     string
+		steps  int
+		reward float32
+	}{
+		{"no actions left", AgentOption{}, "s3", 3, 0},
+		{"until", AgentOption{Until: func(s State) bool { return s.String() == "s2" }}, "s2", 2, 0},
+		{"max steps", AgentOption{MaxSteps: 1}, "s1", 1, 0},
+		{"rewarded", AgentOption{Rewarder: rewards{"a": 1, "b": 2, "c": 4}, Discount: 0.5}, "s3", 3, 1 + 0.5*2 + 0.25*4},
+	}
+	for _, test := range tests {
+		option := test.option
+		option.Agent = NewSimpleAgent(1, 0)
+
+		if at := option.Apply(g.at("s0")).String(); at != test.at || option.Steps != test.steps || option.Reward != test.reward {
+			t.Errorf("%s: reached %s in %d steps with reward %g; want %s in %d with %g",
+				test.name, at, option.Steps, option.Reward, test.at, test.steps, test.reward)
+		}
+	}
+}
+
+func TestAgentOptionNextIter(t *testing.T) {
+	state := iterOnly{wideIter{newWide(3)}}
+	option := &AgentOption{Agent: NewSimpleAgent(1, 0)}
+
+	if at := option.Apply(state).String(); at != "end" || option.Steps != 1 {
+		t.Errorf("reached %s in %d steps, want end in 1", at, option.Steps)
+	}
+}
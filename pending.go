@@ -0,0 +1,59 @@
+package qlearning
+
+import "errors"
+
+// ErrResolved is returned by Resolve for a Handle that has already been
+// resolved.
+var ErrResolved = errors.New("qlearning: handle already resolved")
+
+// Handle identifies a transition whose reward is not known yet, returned
+// by Pending and passed to Resolve.
+type Handle struct {
+	t *pendingTransition
+}
+
+// pendingTransition is the transition behind a Handle.
+type pendingTransition struct {
+	transition
+	resolved bool
+}
+
+// Pending applies the action of sa, as Learn does, but defers the update
+// until its reward is known, for environments where feedback arrives
+// some time after the action. The returned Handle is passed to Resolve
+// with the reward to make the update.
+//
+// The value of the next state that the update bootstraps from is
+// captured by Pending, from the Q-values of the next state at that
+// time; updates made before Resolve do not change it. The Q-value being
+// updated, on the other hand, is read by Resolve, so updates to the
+// same State and Action in between are built on rather than lost.
+//
+// Pending returns an error, and no Handle, if sa's Action implements
+// FallibleAction and cannot be applied. Nothing about the agent changes
+// until Resolve is called, and a Handle that is never resolved costs
+// nothing beyond its memory.
+func (agent *SimpleAgent) Pending(sa *StateAction) (Handle, error) {
+	t, _, err := agent.apply(sa.State.String(), sa)
+	if err != nil {
+		return Handle{}, err
+	}
+
+	return Handle{&pendingTransition{transition: t}}, nil
+}
+
+// Resolve makes the update deferred by Pending with the given reward, as
+// Learn would have made it had the Rewarder returned reward. It returns
+// ErrResolved if h has already been resolved, and otherwise the error
+// LearnE would return for the update.
+func (agent *SimpleAgent) Resolve(h Handle, reward float32) error {
+	if h.t == nil {
+		return errors.New("qlearning: Resolve of a Handle not returned by Pending")
+	}
+	if h.t.resolved {
+		return ErrResolved
+	}
+	h.t.resolved = true
+
+	return agent.commit(agent.planReward(h.t.transition, reward))
+}
@@ -0,0 +1,360 @@
+package qlearning
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// snapshotFormat identifies a stream written by SimpleAgent.Save.
+const snapshotFormat = "qlearning.SimpleAgent"
+
+// SnapshotVersion is the version of the format written by
+// SimpleAgent.Save. Load reads this version and every earlier one:
+//
+//	0: a Q table, map[string]map[string]float32, gob-encoded alone
+//	   without a header
+//	1: the header, then Q-values, update counts, learning rate, discount,
+//	   step count, and reward normalization statistics
+//	2: as 1, with the largest absolute reward seen for SetRewardScaleByMaxAbs
+//
+// The version is bumped whenever the shape of the body changes, with a
+// new type for the body and a function upgrading the previous one.
+const SnapshotVersion = 2
+
+// ErrSnapshotFormat is returned by Load when the stream was not written
+// by SimpleAgent.Save, or was written in a version of the format this
+// package cannot read.
+var ErrSnapshotFormat = errors.New("qlearning: unrecognized snapshot format")
+
+// snapshotHeader precedes the body of every snapshot, so that the
+// version of the body is known before it is decoded.
+type snapshotHeader struct {
+	Format  string
+	Version int
+}
+
+// snapshotV0 is the whole of a version 0 snapshot.
+type snapshotV0 map[string]map[string]float32
+
+// upgrade returns s as a version 1 body, with the learning rate and
+// discount, which version 0 lacks, given by the caller.
+func (s snapshotV0) upgrade(lr, d float32) snapshotV1 {
+	return snapshotV1{
+		LearningRate: lr,
+		Discount:     d,
+		Q:            s,
+	}
+}
+
+// snapshotV1 is the body of a version 1 snapshot.
+type snapshotV1 struct {
+	LearningRate float32
+	Discount     float32
+	Steps        int64
+	Q            map[string]map[string]float32
+	Visits       map[string]map[string]int
+	Rewards      runningStatV1
+}
+
+// runningStatV1 is a runningStat as saved in a version 1 snapshot.
+type runningStatV1 struct {
+	N    int64
+	Mean float64
+	M2   float64
+}
+
+// upgrade returns s as a version 2 body, with no reward yet seen for
+// scaling by the largest absolute reward.
+func (s snapshotV1) upgrade() snapshotV2 {
+	return snapshotV2{
+		LearningRate: s.LearningRate,
+		Discount:     s.Discount,
+		Steps:        s.Steps,
+		Q:            s.Q,
+		Visits:       s.Visits,
+		Rewards: runningStat{
+			N:    s.Rewards.N,
+			Mean: s.Rewards.Mean,
+			M2:   s.Rewards.M2,
+		},
+	}
+}
+
+// snapshotV2 is the body of a version 2 snapshot.
+type snapshotV2 struct {
+	LearningRate float32
+	Discount     float32
+	Steps        int64
+	Q            map[string]map[string]float32
+	Visits       map[string]map[string]int
+	Rewards      runningStat
+}
+
+// Save writes the agent's Q-values, update counts, learning rate,
+// discount, and the running statistics used by reward normalization and
+// scaling to w, in a versioned binary format readable by Load.
+//
+// Options set with the agent's Set methods are not saved.
+func (agent *SimpleAgent) Save(w io.Writer) error {
+	return encodeSnapshot(w, agent.snapshot())
+}
+
+// encodeSnapshot writes a snapshot header followed by body to w.
+func encodeSnapshot(w io.Writer, body snapshotV2) error {
+	enc := gob.NewEncoder(w)
+
+	if err := enc.Encode(snapshotHeader{snapshotFormat, SnapshotVersion}); err != nil {
+		return err
+	}
+
+	return enc.Encode(body)
+}
+
+// snapshot returns the body of a snapshot of the agent, sharing its
+// tables.
+func (agent *SimpleAgent) snapshot() snapshotV2 {
+	return snapshotV2{
+		LearningRate: agent.lr,
+		Discount:     agent.d,
+		Steps:        agent.steps,
+		Q:            agent.table(),
+		Visits:       agent.n,
+		Rewards:      agent.rewards,
+	}
+}
+
+// Snapshot returns a deep copy of the agent, with its own Q-values,
+// update counts, reward statistics, history, and every setting, that can
+// be saved or inspected on another goroutine while training goes on with
+// the original:
+//
+//	snap := agent.Snapshot()
+//	go func() { snap.Save(checkpointFile) }()
+//
+// Nothing done to either afterward changes the other. Snapshot itself
+// reads the agent, so it must not run concurrently with Learn; with a
+// SyncAgent, call it inside Read. The copy keeps its Q-values in a
+// MemoryStore whatever Store the agent uses, chooses at random from the
+// math/rand global source until SetSeed or SetRand is called on it, as a
+// source cannot be shared between agents, and has no OnNewState or
+// OnLearn callbacks.
+func (agent *SimpleAgent) Snapshot() *SimpleAgent {
+	snap := *agent
+
+	snap.q = &MemoryStore{q: copyTable(agent.table())}
+	snap.n = make(map[string]map[string]int, len(agent.n))
+	for state, actions := range agent.n {
+		snap.n[state] = make(map[string]int, len(actions))
+		for action, n := range actions {
+			snap.n[state][action] = n
+		}
+	}
+	if agent.smoothed != nil {
+		snap.smoothed = copyTable(agent.smoothed)
+	}
+	if agent.targets != nil {
+		snap.targets = make(map[string]map[string]targetValue, len(agent.targets))
+		for state, actions := range agent.targets {
+			snap.targets[state] = make(map[string]targetValue, len(actions))
+			for action, v := range actions {
+				snap.targets[state][action] = v
+			}
+		}
+	}
+
+	if agent.actionRates != nil {
+		snap.actionRates = make(map[string]float32, len(agent.actionRates))
+		for action, lr := range agent.actionRates {
+			snap.actionRates[action] = lr
+		}
+	}
+	if agent.actionRewards != nil {
+		snap.actionRewards = make(map[string]*RewardStat, len(agent.actionRewards))
+		for action, stat := range agent.actionRewards {
+			copied := *stat
+			snap.actionRewards[action] = &copied
+		}
+	}
+
+	snap.history = &updateRing{
+		buf:  append([]Update(nil), agent.history.buf...),
+		next: agent.history.next,
+		full: agent.history.full,
+	}
+	snap.unchanged = make(map[string]int, len(agent.unchanged))
+	for state, n := range agent.unchanged {
+		snap.unchanged[state] = n
+	}
+
+	if agent.recency != nil {
+		snap.recency = list.New()
+		snap.recent = make(map[string]*list.Element, len(agent.recent))
+		for e := agent.recency.Front(); e != nil; e = e.Next() {
+			state := e.Value.(string)
+			snap.recent[state] = snap.recency.PushBack(state)
+		}
+	}
+
+	snap.rng = nil
+	snap.onNewState = nil
+	snap.onLearn = nil
+
+	return &snap
+}
+
+// copyTable returns a copy of nested maps of Q-values.
+func copyTable(q map[string]map[string]float32) map[string]map[string]float32 {
+	c := make(map[string]map[string]float32, len(q))
+	for state, actions := range q {
+		c[state] = make(map[string]float32, len(actions))
+		for action, v := range actions {
+			c[state][action] = v
+		}
+	}
+
+	return c
+}
+
+// Load replaces the agent's Q-values, update counts, learning rate,
+// discount, and reward statistics with those read from r, which must
+// have been written by Save. Snapshots written by earlier versions of
+// the format are upgraded, with anything they lack left at its default;
+// a version 0 snapshot, holding only Q-values, keeps the agent's
+// learning rate and discount.
+//
+// Load returns an error wrapping ErrSnapshotFormat if r does not hold a
+// snapshot, or holds one from a newer version of the format. The agent
+// is unchanged if Load returns an error.
+func (agent *SimpleAgent) Load(r io.Reader) error {
+	body, err := decodeSnapshot(r, agent.lr, agent.d)
+	if err != nil {
+		return err
+	}
+
+	agent.restore(body)
+
+	return nil
+}
+
+// decodeSnapshot reads a snapshot written by Save, upgrading it to the
+// current version of the body. lr and d are the learning rate and
+// discount of a version 0 snapshot, which does not record them.
+func decodeSnapshot(r io.Reader, lr, d float32) (snapshotV2, error) {
+	// A version 0 snapshot has no header, so the stream is read whole
+	// to decode it again if the header is not there.
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return snapshotV2{}, err
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		var v0 snapshotV0
+		if gob.NewDecoder(bytes.NewReader(data)).Decode(&v0) == nil {
+			return v0.upgrade(lr, d).upgrade(), nil
+		}
+		return snapshotV2{}, fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+	}
+
+	if header.Format != snapshotFormat {
+		return snapshotV2{}, fmt.Errorf("%w: %q is not a %s snapshot", ErrSnapshotFormat, header.Format, snapshotFormat)
+	}
+
+	var body snapshotV2
+	switch header.Version {
+	case 1:
+		var v1 snapshotV1
+		if err := dec.Decode(&v1); err != nil {
+			return snapshotV2{}, err
+		}
+		body = v1.upgrade()
+	case 2:
+		if err := dec.Decode(&body); err != nil {
+			return snapshotV2{}, err
+		}
+	default:
+		return snapshotV2{}, fmt.Errorf("%w: version %d is not supported; this package reads versions 0 to %d",
+			ErrSnapshotFormat, header.Version, SnapshotVersion)
+	}
+
+	return body, nil
+}
+
+// SaveCompressed writes the snapshot Save writes, compressed with gzip
+// at the given level, one of the compress/gzip levels such as
+// gzip.BestSpeed or gzip.DefaultCompression. Tables keyed by long state
+// strings typically compress to half their size or less.
+//
+// The output is a standard gzip stream, which gunzip and similar tools
+// recognize by its magic number, with the comment
+// "qlearning.SimpleAgent snapshot" in its header. Decompressed, it is
+// exactly what Save writes, so it can be read by LoadCompressed or by
+// Load after decompressing.
+func (agent *SimpleAgent) SaveCompressed(w io.Writer, level int) error {
+	zw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	zw.Comment = snapshotFormat + " snapshot"
+
+	if err := agent.Save(zw); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// LoadCompressed reads a snapshot written by SaveCompressed, as Load
+// does. The agent is unchanged if LoadCompressed returns an error.
+func (agent *SimpleAgent) LoadCompressed(r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+	}
+	defer zr.Close()
+
+	return agent.Load(zr)
+}
+
+// restore replaces the learned state of the agent with a decoded
+// snapshot, discarding the history of recent updates and the
+// convergence metric. A zero SimpleAgent, such as one decoded into by
+// encoding/json, is given the default Store and history first.
+func (agent *SimpleAgent) restore(s snapshotV2) {
+	if s.Q == nil {
+		s.Q = make(map[string]map[string]float32)
+	}
+	if s.Visits == nil {
+		s.Visits = make(map[string]map[string]int)
+	}
+	if agent.q == nil {
+		agent.q = NewShardedStore(DefaultShards)
+	}
+	if agent.history == nil {
+		agent.history = newUpdateRing(DefaultUpdateHistory)
+	}
+
+	agent.lr = s.LearningRate
+	agent.d = s.Discount
+	agent.steps = s.Steps
+	agent.setTable(s.Q)
+	agent.n = s.Visits
+	agent.rewards = s.Rewards
+
+	if agent.targets != nil {
+		agent.targets = make(map[string]map[string]targetValue)
+	}
+
+	agent.history = newUpdateRing(len(agent.history.buf))
+	agent.policyChanged = false
+	agent.unchanged = make(map[string]int)
+	agent.resetConvergence()
+	agent.resetRecency()
+}
@@ -0,0 +1,204 @@
+package qlearning
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// loadFixture loads the snapshot in testdata/name into a new agent with
+// the given learning rate and discount.
+func loadFixture(t *testing.T, name string, lr, d float32) *SimpleAgent {
+	t.Helper()
+
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	agent := NewSimpleAgent(lr, d)
+	if err := agent.Load(f); err != nil {
+		t.Fatalf("loading %s: %v", name, err)
+	}
+
+	return agent
+}
+
+func TestLoadVersion0(t *testing.T) {
+	agent := loadFixture(t, "snapshot-v0.gob", 0.25, 0.75)
+
+	want := map[string]map[string]float32{
+		"start": {"left": -2.5, "right": 0.75},
+		"mid":   {"left": 5},
+	}
+	if got := agent.table(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Q = %v, want %v", got, want)
+	}
+	if agent.lr != 0.25 || agent.d != 0.75 || agent.steps != 0 || len(agent.n) != 0 {
+		t.Errorf("got learning rate %g, discount %g, %d steps, visits %v; want the agent's 0.25, 0.75 and nothing learned",
+			agent.lr, agent.d, agent.steps, agent.n)
+	}
+}
+
+func TestLoadVersion1(t *testing.T) {
+	agent := loadFixture(t, "snapshot-v1.gob", 0, 0)
+
+	wantQ := map[string]map[string]float32{
+		"start": {"left": -0.63012606, "right": -0.12855339},
+		"mid":   {"left": 0.5},
+	}
+	wantVisits := map[string]map[string]int{
+		"start": {"left": 1, "right": 2},
+		"mid":   {"left": 1},
+	}
+	if got := agent.table(); !reflect.DeepEqual(got, wantQ) {
+		t.Errorf("Q = %v, want %v", got, wantQ)
+	}
+	if !reflect.DeepEqual(agent.n, wantVisits) {
+		t.Errorf("visits = %v, want %v", agent.n, wantVisits)
+	}
+	if agent.lr != 0.5 || agent.d != 0.9 || agent.steps != 4 {
+		t.Errorf("got learning rate %g, discount %g, %d steps; want 0.5, 0.9, 4", agent.lr, agent.d, agent.steps)
+	}
+	if want := (runningStat{N: 4, Mean: 1.75, M2: 114.75}); agent.rewards != want {
+		t.Errorf("reward statistics %+v, want %+v with no MaxAbs", agent.rewards, want)
+	}
+}
+
+func TestLoadVersion2(t *testing.T) {
+	agent := loadFixture(t, "snapshot-v2.gob", 0, 0)
+
+	want := map[string]map[string]float32{
+		"start": {"left": -0.25, "right": 0.525},
+		"mid":   {"left": 0.5},
+	}
+	if got := agent.table(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Q = %v, want %v", got, want)
+	}
+	if agent.steps != 4 || agent.rewards.MaxAbs != 10 {
+		t.Errorf("got %d steps, MaxAbs %g; want 4, 10", agent.steps, agent.rewards.MaxAbs)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "end"}, "t": {"c": "end"}}
+	agent := NewSimpleAgent(0.5, 0.9)
+	agent.SetRewardScaleByMaxAbs(true)
+	agent.Learn(g.step("t", "c"), fixedReward(4))
+	agent.Learn(g.step("s", "a"), fixedReward(-2))
+
+	var buf bytes.Buffer
+	if err := agent.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewSimpleAgent(0, 0)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded.table(), agent.table()) || !reflect.DeepEqual(loaded.n, agent.n) {
+		t.Errorf("loaded Q %v, visits %v; want %v, %v", loaded.table(), loaded.n, agent.table(), agent.n)
+	}
+	if loaded.lr != agent.lr || loaded.d != agent.d || loaded.steps != agent.steps || loaded.rewards != agent.rewards {
+		t.Errorf("loaded settings differ from the saved agent's")
+	}
+}
+
+func TestLoadNewerVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshotHeader{snapshotFormat, SnapshotVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	agent := NewSimpleAgent(0.5, 0.9)
+	if err := agent.Load(&buf); !errors.Is(err, ErrSnapshotFormat) {
+		t.Errorf("Load of a newer version returned %v, want ErrSnapshotFormat", err)
+	}
+	if agent.lr != 0.5 {
+		t.Errorf("agent changed by a failed Load")
+	}
+}
+
+func TestLoadNotSnapshot(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	if err := agent.Load(bytes.NewReader([]byte("not a snapshot"))); !errors.Is(err, ErrSnapshotFormat) {
+		t.Errorf("Load of garbage returned %v, want ErrSnapshotFormat", err)
+	}
+}
+
+func TestSnapshotIndependent(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "end"}, "t": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0.9)
+	agent.SetUpdateHistory(10)
+	agent.SetRewardNormalization(true)
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+	agent.Learn(g.step("t", "a"), fixedReward(2))
+
+	snap := agent.Snapshot()
+	a := edge{g, "a", "t"}
+	if v, w := snap.Value(g.at("s"), a), agent.Value(g.at("s"), a); v != w {
+		t.Fatalf("snapshot Q(s, a) = %g, want the agent's %g", v, w)
+	}
+
+	// Each goes on learning something different.
+	agent.Learn(g.step("s", "a"), fixedReward(10))
+	snap.Learn(g.step("s", "b"), fixedReward(-3))
+	snap.Learn(g.step("s", "b"), fixedReward(-3))
+
+	fresh := NewSimpleAgent(0.5, 0.9)
+	fresh.SetUpdateHistory(10)
+	fresh.SetRewardNormalization(true)
+	fresh.Learn(g.step("s", "a"), fixedReward(1))
+	fresh.Learn(g.step("t", "a"), fixedReward(2))
+	fresh.Learn(g.step("s", "a"), fixedReward(10))
+
+	b := edge{g, "b", "end"}
+	if v, w := agent.Value(g.at("s"), a), fresh.Value(g.at("s"), a); v != w {
+		t.Errorf("agent Q(s, a) = %g, want %g as if never snapshotted", v, w)
+	}
+	if agent.Visits(g.at("s"), b) != 0 || agent.ActionCount(g.at("s")) != 1 {
+		t.Errorf("the snapshot's updates of b showed up in the agent")
+	}
+	if v := snap.Value(g.at("s"), a); v == agent.Value(g.at("s"), a) {
+		t.Errorf("the agent's update of a showed up in the snapshot")
+	}
+	if snap.Visits(g.at("s"), a) != 1 || snap.Visits(g.at("s"), b) != 2 {
+		t.Errorf("snapshot visits of a and b = %d and %d, want 1 and 2", snap.Visits(g.at("s"), a), snap.Visits(g.at("s"), b))
+	}
+	if agent.Steps() != 3 || snap.Steps() != 4 {
+		t.Errorf("Steps = %d for the agent and %d for the snapshot, want 3 and 4", agent.Steps(), snap.Steps())
+	}
+	if len(agent.RecentUpdates(10)) != 3 || len(snap.RecentUpdates(10)) != 4 {
+		t.Errorf("history of %d and %d updates, want 3 and 4", len(agent.RecentUpdates(10)), len(snap.RecentUpdates(10)))
+	}
+	if agent.rewards != fresh.rewards {
+		t.Errorf("agent reward statistics %+v, want %+v", agent.rewards, fresh.rewards)
+	}
+}
+
+func TestLoadZeroAgent(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0.9)
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	var buf bytes.Buffer
+	if err := agent.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var loaded SimpleAgent
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := loaded.table(), agent.table(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Q = %v loaded into a zero agent, want %v", got, want)
+	}
+	loaded.Learn(g.step("s", "a"), fixedReward(1))
+	if v := loaded.ValueKeyed("s", "a"); !near(v, 0.75, 1e-6) {
+		t.Errorf("Q = %g after another update, want 0.75", v)
+	}
+}
@@ -0,0 +1,66 @@
+package qlearning
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// policyFormat identifies a stream written by CheckpointPolicy.
+const policyFormat = "qlearning.Policy"
+
+// policyVersion is the version of the format written by
+// CheckpointPolicy.
+const policyVersion = 1
+
+// CheckpointPolicy writes the agent's greedy policy, the action
+// BestActionPerState returns for every recorded state, to w. It is far
+// smaller and quicker to write than a full snapshot, so it suits the
+// frequent checkpoints of an agent being deployed, while the occasional
+// Save keeps what training needs to resume:
+//
+//	if step%1000 == 0 {
+//		agent.CheckpointPolicy(policyFile)
+//	}
+//	if step%100000 == 0 {
+//		agent.Save(snapshotFile)
+//	}
+//
+// A policy cannot be loaded back into an agent, as it holds no Q-values;
+// read it with ReadPolicy. The format is versioned like that of Save.
+func (agent *SimpleAgent) CheckpointPolicy(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+
+	if err := enc.Encode(snapshotHeader{policyFormat, policyVersion}); err != nil {
+		return err
+	}
+
+	return enc.Encode(agent.BestActionPerState())
+}
+
+// ReadPolicy reads a policy written by CheckpointPolicy, mapping the
+// string representation of each state to that of its greedy action. It
+// returns an error wrapping ErrSnapshotFormat if r does not hold a
+// policy.
+func ReadPolicy(r io.Reader) (map[string]string, error) {
+	dec := gob.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+	}
+
+	if header.Format != policyFormat {
+		return nil, fmt.Errorf("%w: %q is not a %s", ErrSnapshotFormat, header.Format, policyFormat)
+	}
+	if header.Version != policyVersion {
+		return nil, fmt.Errorf("%w: policy version %d is not supported", ErrSnapshotFormat, header.Version)
+	}
+
+	var policy map[string]string
+	if err := dec.Decode(&policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
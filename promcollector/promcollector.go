@@ -0,0 +1,92 @@
+// Package promcollector exposes the internals of a training qlearning
+// agent as Prometheus metrics, for services that already export them.
+//
+// It is a separate package so that the qlearning package itself does
+// not depend on the Prometheus client.
+package promcollector
+
+import (
+	"math"
+
+	"github.com/ecooper/qlearning"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector reporting on a SyncAgent. It
+// reports:
+//
+//	qlearning_states          states with a recorded Q-value
+//	qlearning_steps_total     updates made
+//	qlearning_td_error_mean   mean TD error of the recent updates
+//	qlearning_policy_entropy  mean entropy of the selection distribution
+//	qlearning_epsilon         current exploration rate
+//
+// The TD error is averaged over the updates the agent retains for
+// RecentUpdates, and is 0 if it retains none.
+type Collector struct {
+	agent *qlearning.SyncAgent
+
+	states  *prometheus.Desc
+	steps   *prometheus.Desc
+	tdError *prometheus.Desc
+	entropy *prometheus.Desc
+	epsilon *prometheus.Desc
+}
+
+// New returns a Collector reporting on agent, to be registered with a
+// prometheus.Registerer. labels, which may be nil, are added to every
+// metric, to tell several agents apart.
+//
+// The agent is read through its read lock, and only when metrics are
+// collected, so training pays nothing for the Collector between scrapes.
+// Computing the policy entropy visits every Q-value, blocking training
+// while it does.
+func New(agent *qlearning.SyncAgent, labels prometheus.Labels) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("qlearning_"+name, help, nil, labels)
+	}
+
+	return &Collector{
+		agent:   agent,
+		states:  desc("states", "Number of states with a recorded Q-value."),
+		steps:   desc("steps_total", "Number of updates made by the agent."),
+		tdError: desc("td_error_mean", "Mean temporal difference error of the recent updates."),
+		entropy: desc("policy_entropy", "Mean entropy of the selection distribution over recorded states, in nats."),
+		epsilon: desc("epsilon", "Current exploration rate."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.states
+	ch <- c.steps
+	ch <- c.tdError
+	ch <- c.entropy
+	ch <- c.epsilon
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	var states, steps, tdError, entropy, epsilon float64
+
+	c.agent.Read(func(a *qlearning.SimpleAgent) {
+		states = float64(a.States())
+		steps = float64(a.Steps())
+		entropy = float64(a.PolicyEntropy())
+		epsilon = float64(a.Epsilon())
+
+		updates := a.RecentUpdates(math.MaxInt32)
+		for _, u := range updates {
+			tdError += float64(u.TDError)
+		}
+		if len(updates) > 0 {
+			tdError /= float64(len(updates))
+		}
+	})
+
+	ch <- prometheus.MustNewConstMetric(c.states, prometheus.GaugeValue, states)
+	ch <- prometheus.MustNewConstMetric(c.steps, prometheus.CounterValue, steps)
+	ch <- prometheus.MustNewConstMetric(c.tdError, prometheus.GaugeValue, tdError)
+	ch <- prometheus.MustNewConstMetric(c.entropy, prometheus.GaugeValue, entropy)
+	ch <- prometheus.MustNewConstMetric(c.epsilon, prometheus.GaugeValue, epsilon)
+}
@@ -0,0 +1,159 @@
+package qlearning
+
+import "container/list"
+
+// cell is the string representation of a State and an Action.
+type cell struct {
+	state, action string
+}
+
+// Prune forgets every recorded Q-value whose state and action have been
+// updated by Learn fewer than minVisits times, along with its update
+// count and any smoothed or target value, and returns the number
+// forgotten. Q-values recorded without an update, such as by Merge,
+// count as never visited. A state left with no Q-values is forgotten
+// entirely, and is valued as new the next time it is seen.
+//
+// Pruning caps the memory of a table over a huge state space, most of
+// whose states are seen once or twice and never again, but it is lossy:
+// a rarely visited state may still be worth knowing, such as one close
+// to a rare reward, and what was learned about it is lost. Pruning with
+// a low minVisits, between stages of training, loses the least.
+func (agent *SimpleAgent) Prune(minVisits int) int {
+	var drop []cell
+	agent.q.Range(func(state, action string, v float32) bool {
+		if agent.n[state][action] < minVisits {
+			drop = append(drop, cell{state, action})
+		}
+		return true
+	})
+
+	agent.forget(drop)
+
+	return len(drop)
+}
+
+// SetMaxStates caps the number of states the agent keeps Q-values for
+// at n. After each update Learn makes, while more than n states are
+// recorded, the state least recently given a Q-value is forgotten with
+// everything recorded about it, as by Prune, so the table stays bounded
+// however many distinct states are seen. The state just updated is
+// never the one forgotten. States already recorded when SetMaxStates is
+// called count as less recent than any updated afterward, in no
+// particular order among themselves.
+//
+// Like Prune, this loses what was learned about the states forgotten,
+// the more so the lower n is. An n of 0 or less, the default, removes
+// the cap.
+func (agent *SimpleAgent) SetMaxStates(n int) {
+	agent.maxStates = n
+	if n <= 0 {
+		agent.recency, agent.recent = nil, nil
+		return
+	}
+
+	if agent.recency == nil {
+		agent.resetRecency()
+	}
+}
+
+// resetRecency starts tracking the recency of every recorded state anew,
+// if SetMaxStates is on.
+func (agent *SimpleAgent) resetRecency() {
+	if agent.maxStates <= 0 {
+		return
+	}
+
+	agent.recency = list.New()
+	agent.recent = make(map[string]*list.Element)
+	for state := range agent.table() {
+		agent.recent[state] = agent.recency.PushBack(state)
+	}
+}
+
+// touch makes state the most recently updated, if SetMaxStates is on.
+func (agent *SimpleAgent) touch(state string) {
+	if agent.recency == nil {
+		return
+	}
+
+	if e, ok := agent.recent[state]; ok {
+		agent.recency.MoveToFront(e)
+		return
+	}
+	agent.recent[state] = agent.recency.PushFront(state)
+}
+
+// evict forgets the least recently updated states until no more than
+// the number set by SetMaxStates are recorded.
+func (agent *SimpleAgent) evict() {
+	if agent.recency == nil {
+		return
+	}
+
+	for agent.q.States() > agent.maxStates && agent.recency.Len() > 1 {
+		state := agent.recency.Back().Value.(string)
+
+		var drop []cell
+		agent.q.Actions(state, func(action string, v float32) bool {
+			drop = append(drop, cell{state, action})
+			return true
+		})
+		agent.forget(drop)
+
+		// forget drops the state from the list once it has no Q-values
+		// left, but it may have had none to begin with.
+		if e, ok := agent.recent[state]; ok {
+			agent.recency.Remove(e)
+			delete(agent.recent, state)
+		}
+	}
+}
+
+// forget deletes the Q-values of cells, with their update counts and
+// smoothed and target values, and everything kept about the states left
+// with no Q-values.
+func (agent *SimpleAgent) forget(cells []cell) {
+	if len(cells) == 0 {
+		return
+	}
+
+	if d, ok := agent.q.(Deleter); ok {
+		for _, c := range cells {
+			d.Delete(c.state, c.action)
+		}
+	} else {
+		dropped := make(map[cell]bool, len(cells))
+		for _, c := range cells {
+			dropped[c] = true
+		}
+
+		kept := agent.table()
+		agent.q.Clear()
+		for state, actions := range kept {
+			for action, v := range actions {
+				if !dropped[cell{state, action}] {
+					agent.q.Set(state, action, v)
+				}
+			}
+		}
+	}
+
+	for _, c := range cells {
+		delete(agent.n[c.state], c.action)
+		delete(agent.smoothed[c.state], c.action)
+		delete(agent.targets[c.state], c.action)
+
+		if agent.hasState(c.state) {
+			continue
+		}
+		delete(agent.n, c.state)
+		delete(agent.smoothed, c.state)
+		delete(agent.targets, c.state)
+		delete(agent.unchanged, c.state)
+		if e, ok := agent.recent[c.state]; ok {
+			agent.recency.Remove(e)
+			delete(agent.recent, c.state)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package qlearning
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSetMaxStatesBounded(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	agent.SetMaxStates(5)
+
+	// A walk through 200 distinct states, only the last 5 of which are
+	// kept, and a state revisited every step, which stays recent.
+	g := line(200)
+	g["hub"] = map[string]string{"a": "end"}
+	for i := 0; i < 200; i++ {
+		agent.Learn(g.step("s"+strconv.Itoa(i), "a"), fixedReward(1))
+		agent.Learn(g.step("hub", "a"), fixedReward(1))
+		if agent.States() > 5 {
+			t.Fatalf("%d states after %d steps, want at most 5", agent.States(), i+1)
+		}
+	}
+
+	for _, state := range []string{"hub", "s199", "s198", "s197", "s196"} {
+		if agent.ActionCount(g.at(state)) != 1 {
+			t.Errorf("recent state %s was forgotten", state)
+		}
+	}
+	if agent.ActionCount(g.at("s195")) != 0 || agent.Visits(g.at("s0"), edge{g, "a", "s1"}) != 0 {
+		t.Error("old states were kept")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}, "t": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0.9)
+	for _, step := range [][2]string{{"s", "a"}, {"s", "a"}, {"s", "b"}, {"t", "a"}, {"s", "a"}} {
+		agent.Learn(g.step(step[0], step[1]), fixedReward(1))
+	}
+
+	if n := agent.Prune(2); n != 2 {
+		t.Errorf("Prune(2) forgot %d Q-values, want s/b and t/a", n)
+	}
+	if agent.States() != 1 || agent.ActionCount(g.at("s")) != 1 {
+		t.Errorf("%d states and %d actions of s left, want only s/a", agent.States(), agent.ActionCount(g.at("s")))
+	}
+	if agent.Visits(g.at("s"), edge{g, "b", "end"}) != 0 {
+		t.Error("the visits of a pruned Q-value were kept")
+	}
+	if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != 0.875 {
+		t.Errorf("Q(s, a) = %g after pruning, want its 0.875 kept", v)
+	}
+}
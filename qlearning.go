@@ -8,8 +8,13 @@
 package qlearning
 
 import (
+	"container/list"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -23,9 +28,40 @@ type State interface {
 
 	// Next provides a slice of possible Actions that could be applied to
 	// a state.
+	//
+	// Next should return the same Actions in the same order every time
+	// it is called for equal states, which keeps user code such as
+	// action masks and dense indexes simple. The package itself does not
+	// rely on the order: wherever it chooses among several Actions, it
+	// orders them by String first, so that its choices, given the same
+	// random source, do not depend on the order Next returns.
 	Next() []Action
 }
 
+// ActionIterator is an optional interface for States with many possible
+// actions. NextIter calls fn for each Action that Next would return, in
+// the same order, stopping early if fn returns false. When a State
+// implements it, the package uses NextIter instead of Next so that no
+// slice of actions is built on every step.
+type ActionIterator interface {
+	NextIter(fn func(Action) bool)
+}
+
+// eachAction calls fn for each possible Action of state, stopping early
+// if fn returns false.
+func eachAction(state State, fn func(Action) bool) {
+	if iter, ok := state.(ActionIterator); ok {
+		iter.NextIter(fn)
+		return
+	}
+
+	for _, action := range state.Next() {
+		if !fn(action) {
+			return
+		}
+	}
+}
+
 // Action is an interface wrapping an action that can be applied to the
 // model's current state.
 //
@@ -36,6 +72,61 @@ type Action interface {
 	Apply(State) State
 }
 
+// FallibleAction is an optional interface for Actions that can only be
+// found to be invalid when applied. ApplyE applies the Action like
+// Apply, but returns an error instead of a State if the Action could not
+// be applied. Agents use ApplyE instead of Apply when it is implemented.
+type FallibleAction interface {
+	Action
+	ApplyE(State) (State, error)
+}
+
+// terminal reports whether state has no actions.
+func terminal(state State) bool {
+	none := true
+	eachAction(state, func(Action) bool {
+		none = false
+		return false
+	})
+
+	return none
+}
+
+// ended reports whether action, leading to next, ended the episode: if
+// it is marked Terminal, or next is an Environment that is Done.
+func ended(action *StateAction, next State) bool {
+	if action.Terminal {
+		return true
+	}
+
+	env, ok := next.(Environment)
+	return ok && env.Done()
+}
+
+// applyAction applies action to state, using ApplyE if action
+// implements FallibleAction.
+func applyAction(action Action, state State) (State, error) {
+	if fallible, ok := action.(FallibleAction); ok {
+		return fallible.ApplyE(state)
+	}
+
+	return action.Apply(state), nil
+}
+
+// maxRecorded returns the highest of 0 and the Q-values recorded for the
+// actions of a state, the value the map-based agents bootstrap from, as
+// an action not recorded yet is worth 0 to them.
+func maxRecorded[V float32 | float64](values map[string]V) V {
+	max := V(0)
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	return max
+}
+
 // Rewarder is an interface wrapping the ability to provide a reward
 // for the execution of an action in a given state.
 type Rewarder interface {
@@ -44,6 +135,18 @@ type Rewarder interface {
 	Reward(action *StateAction) float32
 }
 
+// NextRewarder is an optional interface for Rewarders whose reward
+// depends on the state an action leads to. When a Rewarder implements
+// it, SimpleAgent calls RewardNext instead of Reward.
+//
+// Rewards are evaluated after the action has been applied. For States
+// that Apply changes in place, sa.State already reflects the action by
+// the time either Reward or RewardNext is called; next is the State that
+// Apply returned.
+type NextRewarder interface {
+	RewardNext(sa *StateAction, next State) float32
+}
+
 // Agent is an interface for a model's agent and is able to learn
 // from actions and return the current Q-value of an action at a given state.
 type Agent interface {
@@ -60,10 +163,31 @@ type Agent interface {
 
 // StateAction is a struct grouping an action to a given State. Additionally,
 // a Value can be associated to StateAction, which is typically the Q-value.
+//
+// Meta carries arbitrary context for the caller, such as a timestamp or
+// the ID of a game, that should stay attached to the transition. Agents
+// ignore it beyond passing it on, to Update for SimpleAgent, and it is
+// not part of Key.
 type StateAction struct {
 	State  State
 	Action Action
 	Value  float32
+
+	// Terminal marks the action as ending the episode, so that learning
+	// from it bootstraps nothing from the state it leads to. An action
+	// leading to an Environment that is Done ends the episode too.
+	Terminal bool
+
+	// Unknown marks an action chosen by Next, or any of the other
+	// functions of this package choosing an action, that the agent has
+	// never learned from in State, so that Value is only what the agent
+	// assumes of an action it knows nothing about, such as SimpleAgent's
+	// default value, rather than a value that happens to be the same. It
+	// is only ever set for agents that count their updates with a Visits
+	// method, as SimpleAgent does, and is ignored by Learn.
+	Unknown bool
+
+	Meta map[string]interface{}
 }
 
 // NewStateAction creates a new StateAction for a State and Action.
@@ -75,91 +199,2038 @@ func NewStateAction(state State, action Action, val float32) *StateAction {
 	}
 }
 
+// Selector is implemented by Agents that select their own actions, for
+// instance to apply their own tie-breaking. Next defers to Select when
+// an Agent implements it.
+type Selector interface {
+	// Select returns the chosen StateAction for a State.
+	Select(State) *StateAction
+}
+
+// FallibleSelector is an optional interface for Selectors that can
+// report why they chose nothing, rather than only returning nil. NextErr
+// defers to SelectErr when an Agent implements it.
+type FallibleSelector interface {
+	Selector
+
+	// SelectErr is Select, returning an error instead of a nil
+	// StateAction when there is no action it can choose.
+	SelectErr(State) (*StateAction, error)
+}
+
+// keySeparator separates the State and Action parts of a key. It is
+// assumed not to appear in the string representation of a State.
+const keySeparator = "\x00"
+
+// Key returns a stable identity for the StateAction, made from the
+// string representations of its State and Action. These are the same
+// strings an Agent such as SimpleAgent indexes its Q-values by, so Key
+// can be used to keep external bookkeeping in line with the agent.
+func (sa *StateAction) Key() string {
+	return cellKey(sa.State.String(), sa.Action.String())
+}
+
+// SplitKey returns the State and Action strings of a key created by
+// StateAction.Key.
+func SplitKey(key string) (state, action string) {
+	i := strings.Index(key, keySeparator)
+	if i < 0 {
+		return key, ""
+	}
+
+	return key[:i], key[i+len(keySeparator):]
+}
+
+// cellKey joins State and Action strings into a single key.
+func cellKey(state, action string) string {
+	return state + keySeparator + action
+}
+
 // Next uses an Agent and State to find the highest scored Action.
 //
-// In the case of Q-value ties for a set of actions, a random
-// value is selected.
+// In the case of Q-value ties for a set of actions, one is chosen
+// uniformly at random among all of them, whatever order the State
+// offers them in; Best breaks ties deterministically instead. If agent
+// implements Selector, its Select method is used instead, with whatever
+// exploration and tie-breaking it does, such as SimpleAgent's choosing
+// among ties at random unless given a tie-breaker; otherwise Next never
+// explores, as GreedyPolicy.
+// Next returns nil if state has no actions.
 func Next(agent Agent, state State) *StateAction {
-	best := make([]*StateAction, 0)
-	bestVal := float32(0.0)
+	if selector, ok := agent.(Selector); ok {
+		return selector.Select(state)
+	}
 
-	for _, action := range state.Next() {
-		val := agent.Value(state, action)
+	return GreedyPolicy{}.Select(agent, state)
+}
 
-		if bestVal == float32(0.0) {
-			best = append(best, NewStateAction(state, action, val))
-			bestVal = val
-		} else {
-			if val > bestVal {
-				best = []*StateAction{NewStateAction(state, action, val)}
-				bestVal = val
-			} else if val == bestVal {
-				best = append(best, NewStateAction(state, action, val))
-			}
+// ErrNoActions is returned by NextErr for a State with no actions.
+var ErrNoActions = errors.New("qlearning: state has no actions")
+
+// NextErr is Next, returning an error wrapping ErrNoActions instead of a
+// nil StateAction when there is no action to choose, so that a State
+// stuck with no actions outside the end of an episode is reported where
+// it is found. If agent is a FallibleSelector, NextErr returns what its
+// SelectErr does instead, such as SimpleAgent's ErrDuplicateAction.
+func NextErr(agent Agent, state State) (*StateAction, error) {
+	if selector, ok := agent.(FallibleSelector); ok {
+		return selector.SelectErr(state)
+	}
+
+	sa := Next(agent, state)
+	if sa == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNoActions, state.String())
+	}
+
+	return sa, nil
+}
+
+// NextEpsilon chooses an Action of state at random with probability
+// epsilon, uniformly among all of them, and otherwise the Action with
+// the highest Q-value of agent, breaking ties at random. An epsilon of 0
+// is fully greedy and an epsilon of 1 fully random. NextEpsilon returns
+// nil if state has no actions.
+//
+// Unlike Next, NextEpsilon ignores any Select method of agent, so that
+// the caller alone controls exploration, such as by annealing epsilon
+// over training.
+func NextEpsilon(agent Agent, state State, epsilon float32) *StateAction {
+	rng := agentRand(agent)
+
+	if epsilon > 0 && randFloat32(rng) < epsilon {
+		var actions []Action
+		eachAction(state, func(action Action) bool {
+			actions = append(actions, action)
+			return true
+		})
+		if len(actions) == 0 {
+			return nil
+		}
+		sortActions(actions)
+
+		action := actions[randIntn(rng, len(actions))]
+		return chosen(agent, state, action, agent.Value(state, action))
+	}
+
+	best := bestActions(agent, state, 0)
+	if len(best) == 0 {
+		return nil
+	}
+
+	return best[randIntn(rng, len(best))]
+}
+
+// NextSoftmax chooses an Action of state with Boltzmann exploration: each
+// Action is chosen with probability proportional to exp(v/temperature),
+// where v is its Q-value. A low temperature approaches always choosing
+// the highest Q-value, and a high temperature approaches choosing
+// uniformly, while Actions of nearly equal value are chosen nearly
+// equally often either way. A temperature of 0 or less is fully greedy,
+// as NextEpsilon with an epsilon of 0. NextSoftmax returns nil if state
+// has no actions.
+//
+// Like NextEpsilon, NextSoftmax ignores any Select method of agent.
+func NextSoftmax(agent Agent, state State, temperature float32) *StateAction {
+	if temperature <= 0 {
+		return NextEpsilon(agent, state, 0)
+	}
+	rng := agentRand(agent)
+
+	var actions []Action
+	eachAction(state, func(action Action) bool {
+		actions = append(actions, action)
+		return true
+	})
+	if len(actions) == 0 {
+		return nil
+	}
+	sortActions(actions)
+
+	values := make([]float32, len(actions))
+	max := float32(math.Inf(-1))
+	for i, action := range actions {
+		values[i] = agent.Value(state, action)
+		if values[i] > max {
+			max = values[i]
+		}
+	}
+
+	// Values are shifted by the highest so that the weights cannot
+	// overflow, and the highest weighs exactly 1, so that the sum is
+	// never 0.
+	weights := make([]float64, len(actions))
+	sum := 0.0
+	for i, v := range values {
+		weights[i] = math.Exp(float64((v - max) / temperature))
+		sum += weights[i]
+	}
+
+	// The last action takes whatever rounding leaves of the draw.
+	r := float64(randFloat32(rng)) * sum
+	i := len(actions) - 1
+	for j, w := range weights {
+		r -= w
+		if r < 0 {
+			i = j
+			break
+		}
+	}
+
+	return chosen(agent, state, actions[i], values[i])
+}
+
+// NextWeighted chooses an Action of state at random, with higher
+// Q-values more likely, so that exploration is spent mostly on Actions
+// worth exploring rather than spread evenly over clearly poor ones as by
+// NextEpsilon. With min and max the lowest and highest Q-values of the
+// Actions of state and n their number, each Action is chosen with
+// probability proportional to
+//
+//	v - min + (max-min)/n
+//
+// where v is its Q-value: shifted so that no weight is negative, with
+// the lowest still weighing a share of the spread, so that it too is
+// tried now and then. If no Action has a positive Q-value, such as in a
+// state not learned from yet, or all have the same one, the choice is
+// uniform. NextWeighted returns nil if state has no actions.
+//
+// Like NextEpsilon, NextWeighted ignores any Select method of agent.
+func NextWeighted(agent Agent, state State) *StateAction {
+	var actions []Action
+	eachAction(state, func(action Action) bool {
+		actions = append(actions, action)
+		return true
+	})
+	if len(actions) == 0 {
+		return nil
+	}
+	sortActions(actions)
+	rng := agentRand(agent)
+
+	values := make([]float32, len(actions))
+	min, max := float32(math.Inf(1)), float32(math.Inf(-1))
+	for i, action := range actions {
+		values[i] = agent.Value(state, action)
+		if values[i] < min {
+			min = values[i]
+		}
+		if values[i] > max {
+			max = values[i]
+		}
+	}
+
+	if max <= 0 || max == min {
+		i := randIntn(rng, len(actions))
+		return chosen(agent, state, actions[i], values[i])
+	}
+
+	floor := float64(max-min) / float64(len(actions))
+	weights := make([]float64, len(actions))
+	sum := 0.0
+	for i, v := range values {
+		weights[i] = float64(v-min) + floor
+		sum += weights[i]
+	}
+
+	// The last action takes whatever rounding leaves of the draw.
+	r := float64(randFloat32(rng)) * sum
+	i := len(actions) - 1
+	for j, w := range weights {
+		r -= w
+		if r < 0 {
+			i = j
+			break
+		}
+	}
+
+	return chosen(agent, state, actions[i], values[i])
+}
+
+// NextUCB chooses an Action of state by upper confidence bound, UCB1.
+// Every Action the agent has not learned from in state yet is tried
+// first, and after that the one maximizing
+//
+//	v + c*sqrt(ln(n)/visits)
+//
+// where v is its Q-value, visits the number of times it has been learned
+// from in state, and n the total of those visits for every Action of
+// state. Rather than spreading exploration evenly over every Action as
+// NextEpsilon does, this explores an Action in proportion to how
+// uncertain its value still is, so that Actions that have proven clearly
+// worse are soon left alone. Ties are broken at random. NextUCB returns
+// nil if state has no actions.
+//
+// The visits are those reported by the agent's Visits method, as
+// SimpleAgent counts them. An agent without one is chosen for greedily,
+// as by NextEpsilon with an epsilon of 0. Like NextEpsilon, NextUCB
+// ignores any Select method of agent.
+func NextUCB(agent Agent, state State, c float32) *StateAction {
+	counter, ok := agent.(interface{ Visits(State, Action) int })
+	if !ok {
+		return NextEpsilon(agent, state, 0)
+	}
+
+	var actions []Action
+	eachAction(state, func(action Action) bool {
+		actions = append(actions, action)
+		return true
+	})
+	if len(actions) == 0 {
+		return nil
+	}
+
+	visits := make(map[string]int, len(actions))
+	total := 0
+	for _, action := range actions {
+		n := counter.Visits(state, action)
+		visits[action.String()] = n
+		total += n
+	}
+
+	score := func(action Action) float32 {
+		n := visits[action.String()]
+		if n == 0 {
+			return math.MaxFloat32
 		}
+
+		bonus := math.Sqrt(math.Log(float64(total)) / float64(n))
+		return agent.Value(state, action) + c*float32(bonus)
 	}
 
-	return best[rand.Intn(len(best))]
+	best := scoreBest(eachOf(actions), score, 0)
+	action := best[randIntn(agentRand(agent), len(best))].action
+
+	return chosen(agent, state, action, agent.Value(state, action))
+}
+
+// Values returns the current Q-value of every Action of state, keyed by
+// the string representation of the Action. Actions an agent has not
+// learned anything about have whatever value the agent reports for
+// them, usually 0.
+//
+// Values works with any Agent, so unlike a method it does not need to be
+// implemented by each Agent.
+func Values(agent Agent, state State) map[string]float32 {
+	values := make(map[string]float32)
+
+	eachAction(state, func(action Action) bool {
+		values[action.String()] = agent.Value(state, action)
+		return true
+	})
+
+	return values
+}
+
+// ActionValue is an Action with its Q-value.
+type ActionValue struct {
+	Action Action
+	Value  float32
+}
+
+// SortedValues returns every Action of state with its current Q-value,
+// highest first, with equal values ordered by the string representation
+// of their Actions, ready for display. Actions the agent has not learned
+// anything about have whatever value the agent reports for them, such
+// as SimpleAgent's default value.
+func SortedValues(agent Agent, state State) []ActionValue {
+	var values []ActionValue
+
+	eachAction(state, func(action Action) bool {
+		values = append(values, ActionValue{action, agent.Value(state, action)})
+		return true
+	})
+
+	sort.SliceStable(values, func(i, j int) bool {
+		if values[i].Value != values[j].Value {
+			return values[i].Value > values[j].Value
+		}
+		return values[i].Action.String() < values[j].Action.String()
+	})
+
+	return values
 }
 
 // SimpleAgent is an Agent implementation that stores Q-values in a
-// map of maps.
+// Store, by default a ShardedStore of DefaultShards shards.
 type SimpleAgent struct {
-	q  map[string]map[string]float32
+	q  Store
+	n  map[string]map[string]int
 	lr float32
 	d  float32
+
+	// init is the value of every state and action not in q.
+	init        float32
+	initializer func(state, action string) float32
+
+	steps int64
+
+	// episode is the number of calls to NewEpisode, and episodeDiscount
+	// what discountSchedule gave for it.
+	episode          int
+	discountSchedule func(episode int) float32
+	episodeDiscount  float32
+
+	sampleAverage  bool
+	rewardInit     bool
+	skipZeroReward bool
+	maxVisits      int
+	actionRates    map[string]float32
+	lrDecay        float32
+
+	// demo is set while LearnFromDemo learns at a learning rate of 1.
+	demo bool
+
+	// weight scales the learning rate while LearnFromPrioritized
+	// corrects for its sampling, or is 0 otherwise.
+	weight float32
+
+	targetClip           bool
+	targetMin, targetMax float32
+	maxDelta             float32
+	squashScale          float32
+
+	reduce        RewardReducer
+	rewardTiming  RewardTiming
+	normalize     bool
+	scaleByMaxAbs bool
+	rewards       runningStat
+	actionRewards map[string]*RewardStat
+
+	rewardClip           bool
+	rewardMin, rewardMax float32
+
+	tieBreaker func(a, b Action) bool
+	tieEpsilon float32
+
+	exploration EpsilonSchedule
+	warmup      int64
+
+	// bySelection is set if the exploration schedule is followed by
+	// selections, counted in selections, rather than by updates.
+	bySelection bool
+	selections  int64
+
+	tryUnseen  bool
+	rng        *rand.Rand
+	duplicates DuplicateActionMode
+
+	// restartPeriod and restartPeak are set by SetEpsilonRestart.
+	restartPeriod int
+	restartPeak   float32
+
+	eliminateBelow  float32
+	eliminateVisits int
+
+	history       *updateRing
+	policyChanged bool
+	unchanged     map[string]int
+
+	// tdAverage is the moving average of the magnitude of the TD errors
+	// of the last updates, tdCount the number of updates it averages,
+	// and tdRate the rate set by SetConvergenceRate.
+	tdAverage float32
+	tdCount   int
+	tdRate    float32
+
+	smoothing float32
+	smoothed  map[string]map[string]float32
+
+	targetTau float32
+	targets   map[string]map[string]targetValue
+
+	divergenceLimit float32
+	diverged        bool
+
+	roundScale float64
+
+	// recency lists the recorded states, most recently updated first,
+	// if SetMaxStates is on, and recent indexes it by state.
+	maxStates int
+	recency   *list.List
+	recent    map[string]*list.Element
+
+	onNewState func(state string)
+	onLearn    func(sa *StateAction, tdError, reward float32)
+
+	logger Logger
+}
+
+// NewSimpleAgentWithRand creates a SimpleAgent as NewSimpleAgent does,
+// making every random choice with r, as set by SetRand.
+func NewSimpleAgentWithRand(lr, d float32, r *rand.Rand) *SimpleAgent {
+	agent := NewSimpleAgent(lr, d)
+	agent.SetRand(r)
+
+	return agent
+}
+
+// NewSimpleAgentWithInit creates a SimpleAgent as NewSimpleAgent does,
+// valuing every State and Action it has not learned at init, as set by
+// SetDefaultValue. An init above the rewards the agent can expect is
+// optimistic initialization, which makes the agent try every action
+// before settling.
+func NewSimpleAgentWithInit(lr, d, init float32) *SimpleAgent {
+	agent := NewSimpleAgent(lr, d)
+	agent.SetDefaultValue(init)
+
+	return agent
 }
 
 // NewSimpleAgent creates a SimpleAgent with the provided learning rate
 // and discount factor.
 func NewSimpleAgent(lr, d float32) *SimpleAgent {
 	return &SimpleAgent{
-		q:  make(map[string]map[string]float32),
+		q:  NewShardedStore(DefaultShards),
+		n:  make(map[string]map[string]int),
 		d:  d,
 		lr: lr,
+
+		history:   newUpdateRing(DefaultUpdateHistory),
+		unchanged: make(map[string]int),
+	}
+}
+
+// OnNewState sets a function called with the string representation of
+// a state the first time the agent records a Q-value for it, whether by
+// Learn or Merge, so that the rate at which new states are discovered
+// can be followed; a falling rate is a sign that exploration has covered
+// the reachable states. fn is called exactly once per state, during the
+// call that records it, and must not call back into the agent. States in
+// a snapshot read by Load are already recorded. A nil fn, the default,
+// disables the callback.
+func (agent *SimpleAgent) OnNewState(fn func(state string)) {
+	agent.onNewState = fn
+}
+
+// OnLearn sets a function called after every update Learn makes, once
+// the new Q-value is recorded, with the StateAction learned from, the
+// temporal difference error of the update, and its reward as used in
+// the update, after any clipping, scaling, or normalization. This is
+// the same TDError and Reward that History records, and lets training be
+// followed without keeping counters around the agent; a TD error that
+// shrinks toward 0 is a sign that the Q-values are converging. Updates
+// skipped as set by SetSkipZeroReward or SetMaxVisitsPerCell are not
+// reported. The action has been applied by then, so a State that Apply
+// changes in place, such as the hangman example's Game, is already
+// changed. fn must not call back into the agent. A nil fn, the default,
+// disables the callback.
+func (agent *SimpleAgent) OnLearn(fn func(sa *StateAction, tdError, reward float32)) {
+	agent.onLearn = fn
+}
+
+// setValue stores the Q-value for a state and action, rounded as set by
+// SetValueRounding, and returns the value stored. Every change to a
+// Q-value goes through setValue.
+func (agent *SimpleAgent) setValue(state, action string, v float32) float32 {
+	if agent.onNewState != nil && !agent.hasState(state) {
+		agent.onNewState(state)
+	}
+
+	v = agent.round(v)
+	agent.q.Set(state, action, v)
+	agent.touch(state)
+	return v
+}
+
+// updateValue records the new Q-value of u and returns it. If the Store
+// is an Updater, the change u makes is applied to the Q-value as it is
+// when recorded instead, if another agent sharing the Store has updated
+// it since u was planned.
+func (agent *SimpleAgent) updateValue(u *pendingUpdate) float32 {
+	updater, ok := agent.q.(Updater)
+	if !ok {
+		return agent.setValue(u.state, u.action, u.new)
+	}
+
+	if agent.onNewState != nil && !agent.hasState(u.state) {
+		agent.onNewState(u.state)
+	}
+
+	v := updater.Update(u.state, u.action, func(v float32, ok bool) float32 {
+		if !ok || v == u.old {
+			return agent.round(u.new)
+		}
+		return agent.round(v + u.new - u.old)
+	})
+	agent.touch(u.state)
+	return v
+}
+
+// SetValueRounding rounds every Q-value the agent stores to the given
+// number of decimal places, which keeps float noise out of saved tables
+// and the diffs between them, and can slightly regularize learning. A
+// negative number of decimals disables rounding, which is the default.
+// Q-values already stored are not rounded until they are next updated.
+func (agent *SimpleAgent) SetValueRounding(decimals int) {
+	if decimals < 0 {
+		agent.roundScale = 0
+		return
 	}
+
+	agent.roundScale = math.Pow(10, float64(decimals))
 }
 
-// getActions returns the current Q-values for a given state.
-func (agent *SimpleAgent) getActions(state string) map[string]float32 {
-	if _, ok := agent.q[state]; !ok {
-		agent.q[state] = make(map[string]float32)
+// round rounds v as set by SetValueRounding.
+func (agent *SimpleAgent) round(v float32) float32 {
+	if agent.roundScale == 0 {
+		return v
 	}
 
-	return agent.q[state]
+	return float32(math.Round(float64(v)*agent.roundScale) / agent.roundScale)
 }
 
 // Learn updates the existing Q-value for the given State and Action
 // using the Rewarder.
 //
+// Each call updates a single Q-value, bootstrapping from the next
+// state's Q-values as they are at the time of the call. A sequence of
+// calls is therefore applied strictly in order: an update that reaches a
+// state updated earlier in the sequence sees the earlier result.
+// LearnBatch instead bootstraps every update of a batch from the
+// Q-values as they were before it.
+//
+// Nothing is assumed about the next state beyond the call it was
+// reached in: Apply may be stochastic, leading the same State and Action
+// to different next states on different calls. Each update moves the
+// Q-value toward a target sampled from that single transition, so the
+// Q-value tracks the expected target over the transitions seen. With a
+// constant learning rate it keeps fluctuating around the expectation; a
+// decaying rate, such as SetSampleAverage, lets it settle.
+//
+// The maximum Q-value of the next state is taken over the Q-values
+// stored under its key, and the default value, never by calling Next or
+// NextIter on it, so the cost of an update does not grow with the number
+// of actions a state offers. See SetDefaultValue for why the default
+// value is included. If the action ends the episode, because it is
+// marked Terminal or leads to an Environment that is Done, nothing is
+// bootstrapped, and the Q-value moves toward its reward alone.
+//
+// The Rewarder is asked for the reward after the action is applied,
+// so a State changed in place by Apply, such as the hangman example's
+// Game, is rewarded as it is after the action. SetRewardTiming can move
+// this before the action is applied.
+//
+// Learn ignores errors from Actions implementing FallibleAction, other
+// than logging them as set by SetLogger; use LearnE to receive them.
+//
 // See https://en.wikipedia.org/wiki/Q-learning#Algorithm
 func (agent *SimpleAgent) Learn(action *StateAction, reward Rewarder) {
-	current := action.State.String()
-	next := action.Action.Apply(action.State).String()
+	if agent.logger == nil {
+		agent.LearnE(action, reward)
+		return
+	}
+
+	// The names are found first, as applying the action may change its
+	// State.
+	s, a := action.State.String(), action.Action.String()
+	if err := agent.LearnKeyed(s, action, reward); err != nil {
+		agent.logf("qlearning: learning from %q in %q: %v", a, s, err)
+	}
+}
 
-	actions := agent.getActions(current)
+// LearnBatch learns from each of experiences, all rewarded by reward,
+// as a single simultaneous update, such as to learn from a recorded
+// trajectory. Every action is applied and the value of its next state
+// captured before any Q-value changes, so every update in the batch
+// bootstraps from the Q-values as they were before the batch, and the
+// result does not depend on the order of experiences when they update
+// different Q-values. The updates are then made in order, so an
+// experience repeated in the batch moves its Q-value once per
+// occurrence. Experiences whose action cannot be applied are skipped, as
+// LearnE would.
+func (agent *SimpleAgent) LearnBatch(experiences []*StateAction, reward Rewarder) {
+	type observed struct {
+		t   transition
+		raw float32
+	}
 
-	maxNextVal := float32(0.0)
-	for _, v := range agent.getActions(next) {
-		if v > maxNextVal {
-			maxNextVal = v
+	batch := make([]observed, 0, len(experiences))
+	for _, action := range experiences {
+		t, raw, err := agent.observe(action.State.String(), action, reward)
+		if err == nil {
+			batch = append(batch, observed{t, raw})
 		}
 	}
 
-	currentVal := actions[action.Action.String()]
-	actions[action.Action.String()] = currentVal + agent.lr*(reward.Reward(action)+agent.d*maxNextVal-currentVal)
+	for _, o := range batch {
+		agent.commit(agent.planReward(o.t, o.raw))
+	}
 }
 
-// Value gets the current Q-value for a State and Action.
-func (agent *SimpleAgent) Value(state State, action Action) float32 {
-	return agent.getActions(state.String())[action.String()]
+// LearnE is Learn for Actions that may fail to apply. If the Action
+// implements FallibleAction and ApplyE returns an error, nothing is
+// learned and the error is returned.
+func (agent *SimpleAgent) LearnE(action *StateAction, reward Rewarder) error {
+	return agent.LearnKeyed(action.State.String(), action, reward)
+}
+
+// LearnKeyed is LearnE for callers that already have the string
+// representation of action's State, such as from a cache of their own,
+// and would rather not have it found again. state must be exactly what
+// action.State.String() would return.
+func (agent *SimpleAgent) LearnKeyed(state string, action *StateAction, reward Rewarder) error {
+	u, err := agent.plan(state, action, reward)
+	if err != nil {
+		return err
+	}
+
+	return agent.commit(u)
+}
+
+// pendingUpdate is an update computed by plan that has not yet been made
+// to the agent.
+type pendingUpdate struct {
+	sa     *StateAction
+	state  string
+	action string
+	meta   map[string]interface{}
+
+	// raw is the reward given by the Rewarder, and reward the reward as
+	// used in the update. rewards are the reward statistics including
+	// this reward.
+	raw     float32
+	reward  float32
+	rewards runningStat
+
+	old    float32
+	new    float32
+	target float32
+
+	// visits is the number of updates of the state and action,
+	// including this one.
+	visits int
+
+	// diverged is the error to return if the update exceeded the
+	// divergence limit.
+	diverged error
+
+	// skip is set if the update is to be skipped, as set by
+	// SetSkipZeroReward or SetMaxVisitsPerCell, or because its reward or
+	// Q-value is not finite, in which case invalid is the error to
+	// return.
+	skip    bool
+	invalid error
+}
+
+// transition is what an update needs to know about the state an action
+// led to, captured when the action is applied.
+type transition struct {
+	sa     *StateAction
+	state  string
+	action string
+	meta   map[string]interface{}
+
+	// maxNext is the value of the next state to bootstrap from, and
+	// terminal whether it has no actions, which is only found out if
+	// zero rewards are skipped.
+	maxNext  float32
+	terminal bool
+
+	// ended is whether the action ended the episode, in which case
+	// nothing is bootstrapped from the next state.
+	ended bool
+}
+
+// apply applies action, whose State has the string representation key,
+// and captures the transition it makes.
+func (agent *SimpleAgent) apply(key string, action *StateAction) (transition, State, error) {
+	t := transition{
+		sa:     action,
+		state:  key,
+		action: action.Action.String(),
+		meta:   action.Meta,
+	}
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return transition{}, nil, err
+	}
+
+	t.ended = ended(action, nextState)
+	if !t.ended {
+		t.maxNext = agent.maxNext(nextState.String())
+	}
+	if agent.skipZeroReward {
+		t.terminal = terminal(nextState)
+	}
+
+	return t, nextState, nil
+}
+
+// plan applies action, whose State has the string representation key,
+// and computes the update Learn would make for it, without changing the
+// agent.
+func (agent *SimpleAgent) plan(key string, action *StateAction, rewarder Rewarder) (*pendingUpdate, error) {
+	t, raw, err := agent.observe(key, action, rewarder)
+	if err != nil {
+		return nil, err
+	}
+
+	return agent.planReward(t, raw), nil
+}
+
+// observe applies action, whose State has the string representation
+// key, and captures the transition it makes and its reward, without
+// changing the agent.
+func (agent *SimpleAgent) observe(key string, action *StateAction, rewarder Rewarder) (transition, float32, error) {
+	_, next := rewarder.(NextRewarder)
+	before := agent.rewardTiming == RewardBeforeApply && !next
+
+	var raw float32
+	if before {
+		raw = rewardOf(rewarder, action, nil, agent.reduce)
+	}
+
+	t, nextState, err := agent.apply(key, action)
+	if err != nil {
+		return transition{}, 0, err
+	}
+
+	if !before {
+		raw = rewardOf(rewarder, action, nextState, agent.reduce)
+	}
+
+	return t, raw, nil
+}
+
+// planReward computes the update for a transition given its reward,
+// without changing the agent.
+func (agent *SimpleAgent) planReward(t transition, raw float32) *pendingUpdate {
+	u := &pendingUpdate{
+		sa:     t.sa,
+		state:  t.state,
+		action: t.action,
+		meta:   t.meta,
+		raw:    raw,
+	}
+
+	u.visits = agent.n[u.state][u.action] + 1
+
+	old, ok := agent.q.Get(u.state, u.action)
+	if !ok {
+		old = agent.seedValue(u.state, u.action)
+	}
+	u.old = old
+
+	if agent.skipZeroReward && u.raw == 0 && !t.terminal && !t.ended ||
+		agent.maxVisits > 0 && u.visits > agent.maxVisits {
+		u.skip = true
+		u.new, u.target = old, old
+		return u
+	}
+
+	if isNaN(u.raw) || isInf(u.raw) && !agent.rewardClip {
+		return agent.invalid(u, "reward", u.raw)
+	}
+
+	u.reward, u.rewards = agent.processReward(u.raw)
+	u.target = u.reward
+	if !t.ended {
+		u.target += agent.bootstrap(t.maxNext)
+	}
+
+	lr := agent.learningRate(u.action, u.visits)
+	if agent.weight > 0 {
+		lr *= agent.weight
+	}
+	u.new = old + lr*(u.target-old)
+	if agent.rewardInit && u.visits == 1 {
+		u.new = u.reward
+	}
+	if agent.maxDelta > 0 {
+		u.new = clamp(u.new, old-agent.maxDelta, old+agent.maxDelta)
+	}
+	if agent.squashScale > 0 {
+		u.new = agent.squashScale * float32(math.Tanh(float64(u.new/agent.squashScale)))
+	}
+
+	if agent.divergenceLimit > 0 && (u.new > agent.divergenceLimit || u.new < -agent.divergenceLimit) {
+		u.diverged = fmt.Errorf("%w: Q-value %g for %q in %q exceeds limit %g",
+			ErrDiverged, u.new, u.action, u.state, agent.divergenceLimit)
+		u.new = clamp(u.new, -agent.divergenceLimit, agent.divergenceLimit)
+	}
+	if isNaN(u.new) || isInf(u.new) {
+		return agent.invalid(u, "Q-value", u.new)
+	}
+	u.new = agent.round(u.new)
+
+	return u
+}
+
+// invalid marks u to be skipped for the value v, named what, not being
+// finite.
+func (agent *SimpleAgent) invalid(u *pendingUpdate, what string, v float32) *pendingUpdate {
+	u.skip = true
+	u.invalid = fmt.Errorf("%w: %s %g for %q in %q", ErrNonFinite, what, v, u.action, u.state)
+	u.new, u.target = u.old, u.old
+	u.reward, u.rewards = 0, agent.rewards
+
+	return u
+}
+
+// isNaN reports whether v is not a number.
+func isNaN(v float32) bool {
+	return v != v
+}
+
+// isInf reports whether v is an infinity of either sign.
+func isInf(v float32) bool {
+	return v > math.MaxFloat32 || v < -math.MaxFloat32
+}
+
+// commit makes an update computed by plan, returning its divergence
+// error, if any.
+func (agent *SimpleAgent) commit(u *pendingUpdate) error {
+	if u.skip {
+		return u.invalid
+	}
+
+	agent.steps++
+
+	agent.recordActionReward(u.action, u.raw)
+	agent.rewards = u.rewards
+	agent.visit(u.state, u.action)
+
+	oldBest := agent.greedyAction(u.state)
+
+	agent.updateTarget(u.state, u.action, u.new)
+	u.new = agent.updateValue(u)
+	agent.smooth(u.state, u.action, u.new)
+	agent.evict()
+
+	agent.trackConvergence(u.target - u.old)
+
+	agent.policyChanged = agent.greedyAction(u.state) != oldBest
+	if agent.policyChanged {
+		agent.unchanged[u.state] = 0
+	} else {
+		agent.unchanged[u.state]++
+	}
+	if u.diverged != nil {
+		agent.diverged = true
+	}
+
+	agent.history.add(Update{
+		State:   u.state,
+		Action:  u.action,
+		Reward:  u.reward,
+		Old:     u.old,
+		New:     u.new,
+		TDError: u.target - u.old,
+		Step:    agent.steps,
+
+		PolicyChanged: agent.policyChanged,
+		Meta:          u.meta,
+	})
+
+	if agent.onLearn != nil {
+		agent.onLearn(u.sa, u.target-u.old, u.reward)
+	}
+
+	return u.diverged
+}
+
+// PreviewLearn computes the update Learn would make for action without
+// making it, returning the current Q-value, the Q-value Learn would set,
+// and the temporal difference error, the difference between the update
+// target and the current Q-value. Nothing about the agent changes,
+// including update counts and reward statistics, which makes it suitable
+// for prioritizing experiences by their TD error.
+//
+// PreviewLearn still applies the action to find the next state, so a
+// State that Apply changes in place is changed. If the action implements
+// FallibleAction and cannot be applied, or the update would be skipped
+// as set by SetSkipZeroReward, PreviewLearn reports the current Q-value
+// with no change.
+func (agent *SimpleAgent) PreviewLearn(action *StateAction, rewarder Rewarder) (oldValue, newValue, tdError float32) {
+	u, err := agent.plan(action.State.String(), action, rewarder)
+	if err != nil {
+		v := agent.Value(action.State, action.Action)
+		return v, v, 0
+	}
+
+	return u.old, u.new, u.target - u.old
+}
+
+// ErrDiverged is returned by LearnE when an update exceeds the limit set
+// with SetDivergenceLimit.
+var ErrDiverged = errors.New("qlearning: Q-values diverged")
+
+// ErrNonFinite is returned by LearnE for an update it skips rather than
+// record a value that is not finite, as once a NaN or infinite Q-value
+// is in the table, every update bootstrapping from it is poisoned too
+// and the agent never recovers. A Rewarder returning NaN, or an
+// infinity while SetRewardClip is not set, has its reward rejected; a
+// clip clamps an infinite reward to its bounds instead. An update whose
+// Q-value overflows to an infinity is clamped by SetDivergenceLimit if
+// it is set, and rejected otherwise, as is one whose Q-value is NaN.
+// Nothing about the agent changes for a rejected update, which Learn
+// logs as set by SetLogger.
+var ErrNonFinite = errors.New("qlearning: reward or Q-value is not finite")
+
+// SetDivergenceLimit guards against Q-values growing without bound, as
+// they do with a discount of 1 and no terminal states. Once set, an
+// update that would move a Q-value beyond [-limit, limit] clamps it to
+// the limit instead, LearnE returns an error wrapping ErrDiverged, and
+// Diverged reports true from then on. Training loops should stop, or at
+// least log, when that happens. A limit of 0, the default, disables the
+// guard.
+func (agent *SimpleAgent) SetDivergenceLimit(limit float32) {
+	agent.divergenceLimit = limit
+}
+
+// Diverged reports whether any update has exceeded the limit set with
+// SetDivergenceLimit.
+func (agent *SimpleAgent) Diverged() bool {
+	return agent.diverged
+}
+
+// greedyAction returns the recorded action of state with the highest
+// Q-value, with ties broken by choosing the action that sorts first, or
+// "" if no actions are recorded.
+func (agent *SimpleAgent) greedyAction(state string) string {
+	best := ""
+	bestVal := float32(0.0)
+	found := false
+
+	agent.q.Actions(state, func(action string, val float32) bool {
+		if !found || val > bestVal || (val == bestVal && action < best) {
+			best = action
+			bestVal = val
+			found = true
+		}
+		return true
+	})
+
+	return best
+}
+
+// LastPolicyChanged reports whether the most recent call to Learn
+// changed which recorded action has the highest Q-value in the state it
+// updated, including the first update of a state. The rate at which the
+// policy changes is a cheap signal of convergence.
+func (agent *SimpleAgent) LastPolicyChanged() bool {
+	return agent.policyChanged
+}
+
+// IsStable reports whether the last window updates of state all left
+// its recorded action with the highest Q-value unchanged, as reported by
+// LastPolicyChanged, so that callers can freeze the parts of a policy
+// that have settled. A state that has never been updated is not stable.
+func (agent *SimpleAgent) IsStable(state State, window int) bool {
+	n, ok := agent.unchanged[state.String()]
+	return ok && n >= window
+}
+
+// bootstrap returns the discounted estimate of future value given the
+// highest Q-value of the next state, clipped if a target clip is set.
+func (agent *SimpleAgent) bootstrap(maxNextVal float32) float32 {
+	b := agent.discount() * maxNextVal
+
+	if agent.targetClip {
+		b = clamp(b, agent.targetMin, agent.targetMax)
+	}
+
+	return b
+}
+
+// SetTargetClip limits the bootstrapped part of every update target,
+// the discounted value of the next state, to [min, max]. Unlike clipping
+// rewards, this directly stops values from growing without bound when
+// they bootstrap off each other, for instance in a cycle of states with
+// a discount of 1.
+func (agent *SimpleAgent) SetTargetClip(min, max float32) {
+	agent.targetClip = true
+	agent.targetMin = min
+	agent.targetMax = max
+}
+
+// SetMaxUpdateDelta limits how far a single update can move a Q-value to
+// d in either direction, however large the temporal difference error.
+// Unlike clipping rewards or targets, this leaves what a Q-value
+// converges to unchanged and only slows how fast it gets there, so a
+// rare, very large reward moves a value gradually instead of all at
+// once. The limit applies to the first update with SetRewardInit too.
+// A d of 0 or less, the default, disables the limit.
+func (agent *SimpleAgent) SetMaxUpdateDelta(d float32) {
+	agent.maxDelta = d
+}
+
+// SetValueSquashing makes every update store scale*tanh(v/scale) in
+// place of the value v it computes, so Q-values approach ±scale without
+// reaching it instead of growing without bound, for instance with a
+// discount of 1 and no terminal states. Unlike clipping, squashing
+// preserves the order of values, so an agent can still tell a large
+// value from a slightly smaller one. Values well inside ±scale are
+// nearly unchanged, but every Q-value is biased toward 0, more so the
+// closer it is to scale. A scale of 0 or less, the default, disables
+// squashing.
+func (agent *SimpleAgent) SetValueSquashing(scale float32) {
+	agent.squashScale = scale
+}
+
+// clamp limits v to [min, max].
+func clamp(v, min, max float32) float32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+
+	return v
+}
+
+// visit records an update of a state and action, returning the number
+// of times it has now been updated.
+func (agent *SimpleAgent) visit(state, action string) int {
+	if _, ok := agent.n[state]; !ok {
+		agent.n[state] = make(map[string]int)
+	}
+
+	agent.n[state][action]++
+
+	return agent.n[state][action]
+}
+
+// learningRate returns the learning rate to use for an update of a
+// state and action that has been updated the given number of times,
+// including the current update.
+func (agent *SimpleAgent) learningRate(action string, visits int) float32 {
+	if agent.demo {
+		return 1
+	}
+	if agent.sampleAverage {
+		return 1 / float32(visits)
+	}
+
+	lr, ok := agent.actionRates[action]
+	if !ok {
+		lr = agent.lr
+	}
+
+	if agent.lrDecay > 0 {
+		lr /= 1 + agent.lrDecay*float32(visits-1)
+	}
+
+	return lr
+}
+
+// SetLearningRateDecay makes the learning rate of each state and action
+// decay as it is updated, to
+//
+//	lr / (1 + decay*n)
+//
+// where lr is the agent's learning rate, or the one set for the action
+// with SetActionLearningRate, and n the number of times the state and
+// action have been updated before. The first update uses lr itself, and
+// later ones move the Q-value less and less, so that it settles instead
+// of following every noisy reward, while states seen rarely still learn
+// quickly. Update counts are saved, so a loaded agent continues its
+// decay. A decay of 0 or less, the default, keeps the rate constant, and
+// sample-average updates, if enabled, take precedence.
+func (agent *SimpleAgent) SetLearningRateDecay(decay float32) {
+	agent.lrDecay = decay
+}
+
+// SetActionLearningRate sets the learning rate for updates of the action
+// whose string representation is action, in every state, overriding the
+// agent's learning rate. This suits actions whose rewards are noisier
+// than others. Sample-average updates, if enabled, take precedence.
+func (agent *SimpleAgent) SetActionLearningRate(action string, lr float32) {
+	if agent.actionRates == nil {
+		agent.actionRates = make(map[string]float32)
+	}
+
+	agent.actionRates[action] = lr
+}
+
+// SetSampleAverage enables or disables sample-average updates. When
+// enabled, the learning rate for each state and action is 1/N, where N
+// is the number of times it has been updated, so each Q-value is the
+// average of its targets. This converges without tuning for stationary
+// problems, such as bandits, and ignores the configured learning rate.
+func (agent *SimpleAgent) SetSampleAverage(enabled bool) {
+	agent.sampleAverage = enabled
+}
+
+// Steps returns the number of times Learn has been called over the life
+// of the agent. It is independent of episodes and can drive schedules or
+// be logged.
+func (agent *SimpleAgent) Steps() int64 {
+	return agent.steps
+}
+
+// States returns the number of states the agent has recorded a Q-value
+// for.
+func (agent *SimpleAgent) States() int {
+	return agent.q.States()
+}
+
+// ValuesFor returns the recorded Q-value of every action of state, keyed
+// by the string representation of the action. Unlike Values, it leaves
+// out actions the agent has not recorded a Q-value for, and does not
+// enumerate the actions of state. The result is a copy that later
+// updates do not change.
+func (agent *SimpleAgent) ValuesFor(state State) map[string]float32 {
+	values := make(map[string]float32)
+	agent.q.Actions(state.String(), func(action string, v float32) bool {
+		values[action] = v
+		return true
+	})
+
+	return values
+}
+
+// TopActions returns up to k of the actions of state the agent has
+// recorded a Q-value for, highest first, with equal values ordered by
+// the string representation of their Actions, for showing what the
+// agent has learned of a state or expanding only its most promising
+// actions in a search. Fewer are returned if fewer are recorded, and
+// none if k is not positive. Unlike SortedValues, actions the agent has
+// not learned are left out rather than given the default value. The
+// actions of state are enumerated to find the Actions, and nothing about
+// the agent changes.
+func (agent *SimpleAgent) TopActions(state State, k int) []*StateAction {
+	if k <= 0 {
+		return nil
+	}
+
+	s := state.String()
+	var top []*StateAction
+	eachAction(state, func(action Action) bool {
+		if v, ok := agent.q.Get(s, action.String()); ok {
+			top = append(top, NewStateAction(state, action, v))
+		}
+		return true
+	})
+
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].Value != top[j].Value {
+			return top[i].Value > top[j].Value
+		}
+		return top[i].Action.String() < top[j].Action.String()
+	})
+	if len(top) > k {
+		top = top[:k]
+	}
+
+	return top
+}
+
+// StateCount returns the number of distinct states the agent has
+// recorded a Q-value for, the number of keys of its Q-table. It is the
+// same as States, and named to go with ActionCount.
+func (agent *SimpleAgent) StateCount() int {
+	return agent.q.States()
+}
+
+// ActionCount returns the number of actions of state the agent has
+// recorded a Q-value for. Together with StateCount, it shows how fast
+// the table is growing, and when it stops.
+func (agent *SimpleAgent) ActionCount(state State) int {
+	n := 0
+	agent.q.Actions(state.String(), func(string, float32) bool {
+		n++
+		return true
+	})
+
+	return n
+}
+
+// MaxValue returns the highest recorded Q-value of state, and whether
+// the agent has recorded any, without enumerating the actions of state.
+// For a state the agent has not seen, it returns 0 and false. Learn
+// bootstraps from the higher of MaxValue and the default value, as
+// SetDefaultValue describes, since an action not yet recorded may be
+// better than every one that is; with SetTargetTau, Learn uses the
+// target values instead.
+func (agent *SimpleAgent) MaxValue(state State) (float32, bool) {
+	max, found := float32(0.0), false
+	agent.q.Actions(state.String(), func(action string, v float32) bool {
+		if !found || v > max {
+			max, found = v, true
+		}
+		return true
+	})
+
+	return max, found
+}
+
+// SetRewardInit enables or disables initializing Q-values with their
+// first reward. When enabled, the first update of each State and Action
+// sets its Q-value to the reward alone, ignoring the learning rate and
+// without bootstrapping off a next state that has likely not been
+// learned yet. Later updates are unaffected. This can speed up early
+// learning when rewards are sparse.
+func (agent *SimpleAgent) SetRewardInit(enabled bool) {
+	agent.rewardInit = enabled
+}
+
+// SetSkipZeroReward enables or disables skipping updates with a reward of
+// exactly 0 that do not end in a terminal state, one with no actions.
+// Learn still applies the action, but otherwise changes nothing: no
+// Q-value, update count, step count, or reward statistic.
+//
+// In sparse-reward tasks most steps are such updates, so skipping them
+// saves time, and with a discount of 1 it stops values drifting through
+// the bootstrap alone. The cost is that values only propagate backward
+// through steps with a reward, so an agent learns slower, or not at all,
+// in tasks where reaching a rewarding state takes several unrewarded
+// steps.
+func (agent *SimpleAgent) SetSkipZeroReward(enabled bool) {
+	agent.skipZeroReward = enabled
+}
+
+// SetMaxVisitsPerCell sets the number of updates after which the Q-value
+// of a state and action is considered settled. Learn still applies the
+// action and asks for its reward, but otherwise changes nothing for a
+// cell that has been updated n times, as with SetSkipZeroReward, so
+// training spends its updates on cells that are less well known. Values
+// of n of 0 or less, the default, set no limit.
+//
+// Like SetSkipZeroReward, the limit is only checked when Learn is
+// called; lowering it does not undo updates already made.
+func (agent *SimpleAgent) SetMaxVisitsPerCell(n int) {
+	agent.maxVisits = n
+}
+
+// Visits returns the number of times Learn has updated the Q-value for
+// a State and Action.
+func (agent *SimpleAgent) Visits(state State, action Action) int {
+	return agent.n[state.String()][action.String()]
+}
+
+// Value gets the current Q-value for a State and Action, or the value it
+// would be seeded with, the default value unless an initializer is set,
+// if the agent has not recorded it. Value does not record anything for a
+// State and Action the agent has not seen.
+func (agent *SimpleAgent) Value(state State, action Action) float32 {
+	return agent.ValueKeyed(state.String(), action.String())
+}
+
+// ValueKeyed is Value for the string representations of a State and
+// Action, for callers that already have them, such as from a Range over
+// the agent, and would rather not find them again.
+func (agent *SimpleAgent) ValueKeyed(state, action string) float32 {
+	if v, ok := agent.q.Get(state, action); ok {
+		return v
+	}
+
+	return agent.seedValue(state, action)
+}
+
+// LearningRate returns the learning rate the agent was created with.
+func (agent *SimpleAgent) LearningRate() float32 {
+	return agent.lr
+}
+
+// Discount returns the discount factor the agent was created with.
+func (agent *SimpleAgent) Discount() float32 {
+	return agent.d
+}
+
+// SetDiscountSchedule makes the discount factor used by Learn change
+// from episode to episode: in episode n, counted by NewEpisode from 0,
+// it is schedule(n). Starting with a low discount, which looks only a
+// few steps ahead, and raising it as training goes on lets the agent
+// learn the immediate consequences of its actions before the values of
+// distant rewards, which are noisy at first, weigh on them. A nil
+// schedule, the default, goes back to the discount the agent was
+// created with.
+func (agent *SimpleAgent) SetDiscountSchedule(schedule func(episode int) float32) {
+	agent.discountSchedule = schedule
+	if schedule != nil {
+		agent.episodeDiscount = schedule(agent.episode)
+	}
+}
+
+// NewEpisode implements EpisodeResetter, telling the agent that a new
+// episode is starting and counting it toward the episode number the
+// schedule set by SetDiscountSchedule is given. The first episode,
+// before NewEpisode is called, is episode 0. Trainer and SelfPlay call
+// it after every episode, so it need only be called directly by
+// training loops of one's own.
+func (agent *SimpleAgent) NewEpisode() {
+	agent.episode++
+	if agent.discountSchedule != nil {
+		agent.episodeDiscount = agent.discountSchedule(agent.episode)
+	}
+}
+
+// Episodes returns the number of the current episode, the number of
+// times NewEpisode has been called.
+func (agent *SimpleAgent) Episodes() int {
+	return agent.episode
+}
+
+// CurrentDiscount returns the discount factor Learn uses, that of the
+// current episode if SetDiscountSchedule is set, and otherwise Discount.
+func (agent *SimpleAgent) CurrentDiscount() float32 {
+	return agent.discount()
+}
+
+// discount returns the discount factor in use.
+func (agent *SimpleAgent) discount() float32 {
+	if agent.discountSchedule != nil {
+		return agent.episodeDiscount
+	}
+
+	return agent.d
+}
+
+// SetDefaultValue sets the value of every State and Action the agent has
+// not learned, which is 0 unless set. It is the single place unseen
+// actions get their value:
+//
+//   - Value, and so action selection, reports it for unseen actions.
+//   - Learn starts from it the first time it updates a Q-value.
+//   - Learn bootstraps from the higher of it and the best recorded
+//     Q-value of the next state. Learn does not enumerate the actions of
+//     the next state, so it assumes one may not have been tried yet.
+//
+// A default above the rewards an agent can expect is optimistic, and
+// makes it try every action before settling. With a default of 0 and
+// negative rewards, an agent prefers untried actions to any it has
+// tried, and a state whose tried actions are all negative is still
+// worth 0 as a next state. Either way, an action chosen without having
+// been tried is marked Unknown, so a caller can tell it apart from one
+// tried and valued the same.
+func (agent *SimpleAgent) SetDefaultValue(v float32) {
+	agent.init = v
+}
+
+// SetInitializer sets a heuristic giving the starting Q-value of each
+// State and Action, by their string representations, in place of the
+// single default value; for hangman, for instance, the frequency of a
+// letter. initial is called once per State and Action, the first time
+// the agent touches it: when Select considers the actions of a state,
+// or when Learn first updates it. Its result is then recorded as the
+// Q-value and learned from normally, so Learn bootstraps from it too.
+//
+// Seeding a Q-value is not an update: it does not count as a visit,
+// a step, or an entry in RecentUpdates, and Visits stays 0 until Learn
+// updates it. Value reports what a Q-value would be seeded with without
+// seeding it. A nil initializer, the default, seeds nothing, leaving
+// unrecorded Q-values at the default value.
+func (agent *SimpleAgent) SetInitializer(initial func(state, action string) float32) {
+	agent.initializer = initial
+}
+
+// seedValue returns the value a Q-value not yet recorded starts from.
+func (agent *SimpleAgent) seedValue(state, action string) float32 {
+	if agent.initializer != nil {
+		return agent.initializer(state, action)
+	}
+
+	return agent.init
+}
+
+// seed records the initial Q-value of every action of state not yet
+// recorded, if an initializer is set.
+func (agent *SimpleAgent) seed(state State, actions []Action) {
+	if agent.initializer == nil {
+		return
+	}
+
+	s := state.String()
+	for _, action := range actions {
+		a := action.String()
+		if _, ok := agent.q.Get(s, a); !ok {
+			agent.setValue(s, a, agent.initializer(s, a))
+		}
+	}
+}
+
+// DefaultValue returns the value of every State and Action the agent has
+// not learned.
+func (agent *SimpleAgent) DefaultValue() float32 {
+	return agent.init
+}
+
+// maxNext returns the value Learn bootstraps from for the next state: the
+// higher of the default value and its best recorded Q-value, or target
+// value if SetTargetTau is enabled. It only reads the stored Q-values and
+// must not enumerate the actions of next, which can be expensive.
+func (agent *SimpleAgent) maxNext(next string) float32 {
+	max := agent.init
+	agent.q.Actions(next, func(action string, v float32) bool {
+		if agent.targets != nil {
+			v = agent.target(next, action, v, agent.steps)
+		}
+		if v > max {
+			max = v
+		}
+		return true
+	})
+
+	return max
+}
+
+// SetTieBreaker sets the function used to choose between actions with
+// equal Q-values. prefer reports whether a should be chosen over b.
+//
+// With no tie-breaker, or after calling SetTieBreaker(nil), one of the
+// tied actions is chosen uniformly at random, so that the learned policy
+// is not biased towards any of them; to always choose the one whose
+// string representation sorts first, as Best does, set a tie-breaker
+// comparing strings. The tie-breaker only affects selection, never
+// learning.
+func (agent *SimpleAgent) SetTieBreaker(prefer func(a, b Action) bool) {
+	agent.tieBreaker = prefer
+}
+
+// SetTieEpsilon sets how close two Q-values must be to be considered
+// tied when selecting an action. Actions within eps of the highest
+// Q-value are chosen between at random, or by the tie-breaker, instead of
+// committing to whichever is ahead by floating point noise. The default
+// is 0, for exact ties only.
+func (agent *SimpleAgent) SetTieEpsilon(eps float32) {
+	agent.tieEpsilon = eps
+}
+
+// TieEpsilon returns the tie epsilon set with SetTieEpsilon.
+func (agent *SimpleAgent) TieEpsilon() float32 {
+	return agent.tieEpsilon
+}
+
+// SetSeed gives the agent its own source of randomness, seeded with
+// seed, for every random decision Select makes: whether to explore,
+// which action to explore, and which of several tied actions to choose.
+// Two agents with the same seed, configuration, and Q-values, asked to
+// select for the same sequence of states, make exactly the same choices,
+// since candidate actions are ordered by String before choosing; so a
+// training segment can be replayed to see why an action was taken. The
+// source is not saved, and without SetSeed the math/rand global source
+// is used.
+func (agent *SimpleAgent) SetSeed(seed int64) {
+	agent.rng = rand.New(rand.NewSource(seed))
+}
+
+// SetRand is SetSeed with a source of randomness the caller provides,
+// which the agent then owns: as a *rand.Rand is not safe for concurrent
+// use, it must not be used elsewhere while the agent is. A nil r goes
+// back to the math/rand global source.
+func (agent *SimpleAgent) SetRand(r *rand.Rand) {
+	agent.rng = r
+}
+
+// Rand returns the source of randomness set with SetSeed or SetRand, or
+// nil if the agent uses the math/rand global source. NextEpsilon,
+// NextSoftmax, and the other package functions choosing actions for an
+// agent draw from it too, so that training with them is as reproducible
+// as training with Select.
+func (agent *SimpleAgent) Rand() *rand.Rand {
+	return agent.rng
+}
+
+// SetExplorationSchedule makes the agent explore: Select chooses an
+// action of the state uniformly at random with the probability schedule
+// gives for the number of updates made so far, which it asks for on
+// every selection. A nil schedule, the default, never explores.
+func (agent *SimpleAgent) SetExplorationSchedule(schedule EpsilonSchedule) {
+	agent.exploration = schedule
+	agent.bySelection = false
+}
+
+// SetEpsilonSchedule makes the agent explore at a rate starting at
+// start and multiplied by decay at every selection it makes, by Select,
+// SelectExplained or SelectErr, and so by Next, but never falling below
+// min. Unlike a schedule set by SetExplorationSchedule, which follows
+// the updates counted by Steps, the rate shrinks with every action the
+// agent chooses, whether or not it is learned from; ActionProbabilities
+// and Epsilon do not count as selections. The count of selections is
+// not saved, so a loaded agent starts again from start.
+func (agent *SimpleAgent) SetEpsilonSchedule(start, min, decay float32) {
+	agent.SetExplorationSchedule(ExponentialDecay{
+		Start: start,
+		Min:   min,
+		Rate:  decay,
+	})
+	agent.bySelection = true
+	agent.selections = 0
+}
+
+// SetEpsilonRestart adds periodic bursts of exploration to the
+// exploration schedule, to escape a policy that the decaying schedule
+// has settled on too early. Every period episodes, counted by
+// NewEpisode, the exploration rate jumps to peak and falls back in a
+// sawtooth over the following period episodes: in episode n it is at
+// least
+//
+//	peak * (1 - (n%period)/period)
+//
+// so episode 0, period, 2*period and so on explore at peak. The burst
+// only ever raises the rate, never lowering it below the schedule's,
+// which goes on decaying with Steps as before underneath; once the
+// schedule has decayed below the burst, the rate follows the sawtooth.
+// Unlike the Cyclic schedule, restarts follow episodes rather than
+// steps, so a long episode does not use up its burst. A period below 1,
+// the default, disables restarts.
+func (agent *SimpleAgent) SetEpsilonRestart(period int, peak float32) {
+	agent.restartPeriod = period
+	agent.restartPeak = peak
+}
+
+// SetWarmupSteps makes Select choose uniformly at random, whatever the
+// exploration schedule, until the agent has made n updates, to seed the
+// table before acting on it. Updates are counted by Steps, which Save
+// records, so a resumed agent continues where its warmup left off; the
+// number of warmup steps itself is not saved and must be set again.
+func (agent *SimpleAgent) SetWarmupSteps(n int) {
+	agent.warmup = int64(n)
+}
+
+// SetTryUnseen makes Select try every action of a state once before
+// relying on its Q-values: while any of the actions it would consider
+// has never been updated, it chooses one of those at random, whatever
+// the exploration rate, and only once all have been learned from does
+// it choose greedily or explore as usual. This guarantees every action
+// is tried even at a low or zero exploration rate, at the cost of
+// spending a step on each action of every state visited, however poor
+// it looks. SelectExplained reports such a choice as explored.
+func (agent *SimpleAgent) SetTryUnseen(try bool) {
+	agent.tryUnseen = try
+}
+
+// Epsilon returns the probability that Select currently explores: 1
+// during warmup, and otherwise the rate given by the exploration
+// schedule for the updates made so far, or for the selections made so
+// far if it was set by SetEpsilonSchedule, raised by any burst set with
+// SetEpsilonRestart.
+func (agent *SimpleAgent) Epsilon() float32 {
+	if agent.steps < agent.warmup {
+		return 1
+	}
+
+	eps := float32(0)
+	if agent.exploration != nil {
+		step := agent.steps
+		if agent.bySelection {
+			step = agent.selections
+		}
+		eps = agent.exploration.Epsilon(step)
+	}
+
+	if agent.restartPeriod > 0 {
+		into := agent.episode % agent.restartPeriod
+		burst := agent.restartPeak * (1 - float32(into)/float32(agent.restartPeriod))
+		if burst > eps {
+			eps = burst
+		}
+	}
+
+	return eps
+}
+
+// Select implements Selector. With the probability given by the
+// agent's exploration schedule, if any, it returns an Action of state
+// chosen uniformly at random. Otherwise it returns the highest scored
+// Action for state, using the agent's tie-breaker to choose among ties,
+// or choosing one of them uniformly at random if it has none. With
+// SetTryUnseen, actions never updated come first.
+func (agent *SimpleAgent) Select(state State) *StateAction {
+	explained := agent.SelectExplained(state)
+	if explained == nil {
+		return nil
+	}
+
+	return explained.Choice
+}
+
+// SelectErr implements FallibleSelector. It chooses as Select does, but
+// returns an error wrapping ErrNoActions if state has no actions, or
+// ErrDuplicateAction if it offers duplicate Actions while they are
+// rejected, as set by SetDuplicateActions.
+func (agent *SimpleAgent) SelectErr(state State) (*StateAction, error) {
+	explained, err := agent.selectExplained(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return explained.Choice, nil
+}
+
+// SelectExplained implements ExplainingSelector. It chooses as Select
+// does, and reports the probability of the choice: with epsilon the
+// current exploration rate and n the number of actions of state, every
+// action has a probability of epsilon/n of being explored, and the
+// greedy choice a further 1-epsilon, shared evenly among the tied
+// actions if there is no tie-breaker to choose between them.
+func (agent *SimpleAgent) SelectExplained(state State) *Explanation {
+	explained, _ := agent.selectExplained(state)
+	return explained
+}
+
+// selectExplained is SelectExplained, returning the error SelectErr
+// reports instead of a nil Explanation.
+func (agent *SimpleAgent) selectExplained(state State) (*Explanation, error) {
+	sel, err := agent.selection(state, true)
+	if err != nil {
+		return nil, err
+	}
+	agent.selections++
+
+	if len(sel.unseen) > 0 {
+		action := sel.unseen[randIntn(agent.rng, len(sel.unseen))]
+		return &Explanation{
+			Choice:      sel.choice(agent, state, action, sel.value(action)),
+			Explored:    true,
+			Probability: sel.probability(action),
+		}, nil
+	}
+
+	if sel.eps > 0 && randFloat32(agent.rng) < sel.eps {
+		action := sel.actions[randIntn(agent.rng, len(sel.actions))]
+		return &Explanation{
+			Choice:      sel.choice(agent, state, action, sel.value(action)),
+			Explored:    true,
+			Probability: sel.probability(action),
+		}, nil
+	}
+
+	action := sel.preferred
+	if action == nil {
+		action = sel.best[randIntn(agent.rng, len(sel.best))].action
+	}
+
+	return &Explanation{
+		Choice:      sel.choice(agent, state, action, sel.value(action)),
+		Probability: sel.probability(action),
+	}, nil
+}
+
+// ActionProbabilities returns the probability that Select chooses each
+// Action of state, keyed by the string representation of the Action,
+// as SelectExplained reports them. Actions Select would not consider,
+// such as those eliminated by SetActionElimination, are left out. It
+// returns nil if state has no actions, and changes nothing about the
+// agent.
+func (agent *SimpleAgent) ActionProbabilities(state State) map[string]float32 {
+	sel, err := agent.selection(state, false)
+	if err != nil {
+		return nil
+	}
+
+	probabilities := make(map[string]float32, len(sel.actions))
+	for _, action := range sel.actions {
+		probabilities[action.String()] = sel.probability(action)
+	}
+
+	return probabilities
+}
+
+// selection is what Select chooses between for a state.
+type selection struct {
+	// actions are the candidate actions, sorted, and best those with
+	// the highest value, within the tie epsilon.
+	actions []Action
+	best    []scored
+
+	// preferred is the action chosen among best by the tie-breaker, or
+	// nil if the agent has none and chooses among them at random.
+	preferred Action
+
+	// unseen are the candidate actions never updated, chosen between
+	// before any other as set by SetTryUnseen, or nil otherwise.
+	unseen []Action
+
+	// key is the string representation of the state.
+	key   string
+	eps   float32
+	value func(Action) float32
+}
+
+// selection returns what Select chooses between for state, or an error
+// wrapping ErrNoActions if it has no actions, or ErrDuplicateAction if
+// it offers duplicates that are rejected. With seed, the Q-values of the
+// actions are seeded as set by SetInitializer.
+func (agent *SimpleAgent) selection(state State, seed bool) (*selection, error) {
+	var actions []Action
+	eachAction(state, func(action Action) bool {
+		actions = append(actions, action)
+		return true
+	})
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNoActions, state.String())
+	}
+	sortActions(actions)
+	actions, err := agent.dedupeActions(state, actions)
+	if err != nil {
+		return nil, err
+	}
+	if seed {
+		agent.seed(state, actions)
+	}
+
+	key := state.String()
+	sel := &selection{
+		key: key,
+		eps: agent.Epsilon(),
+		value: func(action Action) float32 {
+			return agent.ValueKeyed(key, action.String())
+		},
+	}
+
+	sel.actions = agent.eliminateActions(state, actions, sel.value)
+	sel.best = scoreBest(eachOf(sel.actions), sel.value, agent.tieEpsilon)
+	sel.preferred = agent.breakTie(sel.best)
+
+	if agent.tryUnseen {
+		for _, action := range sel.actions {
+			if agent.n[key][action.String()] == 0 {
+				sel.unseen = append(sel.unseen, action)
+			}
+		}
+	}
+
+	return sel, nil
+}
+
+// choice returns a StateAction for action of state with the given value,
+// as chosen does, without finding the string representation of state
+// again.
+func (sel *selection) choice(agent *SimpleAgent, state State, action Action, value float32) *StateAction {
+	sa := NewStateAction(state, action, value)
+	sa.Unknown = agent.n[sel.key][action.String()] == 0
+
+	return sa
+}
+
+// probability returns the probability that action is chosen: its share
+// of exploration, and of the greedy choice if it is among the best, or
+// its share of the unseen actions if there are any to try.
+func (sel *selection) probability(action Action) float32 {
+	if len(sel.unseen) > 0 {
+		for _, u := range sel.unseen {
+			if u.String() == action.String() {
+				return 1 / float32(len(sel.unseen))
+			}
+		}
+		return 0
+	}
+
+	p := sel.eps / float32(len(sel.actions))
+	if sel.preferred != nil {
+		if action.String() == sel.preferred.String() {
+			p += 1 - sel.eps
+		}
+		return p
+	}
+
+	for _, b := range sel.best {
+		if b.action.String() == action.String() {
+			p += (1 - sel.eps) / float32(len(sel.best))
+			break
+		}
+	}
+
+	return p
+}
+
+// DuplicateActionMode is how a SimpleAgent treats a state offering
+// several Actions with the same string representation. The agent keys
+// Q-values by that string, so such Actions always share a Q-value; the
+// mode decides whether that is accepted.
+type DuplicateActionMode int
+
+const (
+	// CollapseDuplicateActions treats Actions with the same string as
+	// one, the first of them offered by the state, so that duplicates
+	// are not more likely to be chosen than other actions. This is the
+	// default.
+	CollapseDuplicateActions DuplicateActionMode = iota
+
+	// RejectDuplicateActions makes Select choose nothing when a state
+	// offers duplicate Actions, to catch a String method that is not
+	// unique enough. SelectErr and NextErr report ErrDuplicateAction.
+	RejectDuplicateActions
+
+	// DistinctDuplicateActions keeps duplicate Actions apart by the
+	// order the state offers them in, giving each a Q-value of its own:
+	// the second Action offered with the string "a" is keyed as "a#2",
+	// the third as "a#3", and so on, while the first keeps "a". Select
+	// returns such an Action wrapped so that its String is that key,
+	// which is what Learn then records it under; Value must be given
+	// the Action Select returned, not the one the state offered. The
+	// keys only stay attached to the same Actions if the state offers
+	// its duplicates in the same order every time.
+	DistinctDuplicateActions
+)
+
+// ErrDuplicateAction is returned by SelectErr, and so NextErr, for a
+// state offering several Actions with the same string representation
+// while SetDuplicateActions rejects them.
+var ErrDuplicateAction = errors.New("qlearning: state offers an action more than once")
+
+// SetDuplicateActions sets how Select treats a state offering several
+// Actions with the same string representation, which the agent would
+// otherwise key under a single Q-value.
+func (agent *SimpleAgent) SetDuplicateActions(mode DuplicateActionMode) {
+	agent.duplicates = mode
+}
+
+// dedupeActions removes the Actions in actions, which must be sorted by
+// sortActions, whose string representation duplicates an earlier one,
+// or gives them distinct keys, as set by SetDuplicateActions. It returns
+// an error wrapping ErrDuplicateAction if duplicates are rejected.
+func (agent *SimpleAgent) dedupeActions(state State, actions []Action) ([]Action, error) {
+	unique := actions[:1]
+	last := actions[0].String()
+	occurrence := 1
+	renamed := false
+
+	for _, action := range actions[1:] {
+		key := action.String()
+		if key != last {
+			unique = append(unique, action)
+			last = key
+			occurrence = 1
+			continue
+		}
+
+		occurrence++
+		switch agent.duplicates {
+		case RejectDuplicateActions:
+			return nil, fmt.Errorf("%w: %q in %q", ErrDuplicateAction, key, state.String())
+		case DistinctDuplicateActions:
+			unique = append(unique, distinctAction{action, fmt.Sprintf("%s#%d", key, occurrence)})
+			renamed = true
+		}
+	}
+
+	// A renamed Action can sort after Actions it preceded.
+	if renamed {
+		sortActions(unique)
+	}
+
+	return unique, nil
+}
+
+// distinctAction is a duplicate Action kept apart from the others by its
+// key, as set by DistinctDuplicateActions.
+type distinctAction struct {
+	Action
+	key string
+}
+
+func (a distinctAction) String() string {
+	return a.key
+}
+
+// ApplyE implements FallibleAction for the Action wrapped, whether or not
+// it is fallible itself.
+func (a distinctAction) ApplyE(state State) (State, error) {
+	return applyAction(a.Action, state)
+}
+
+// SetActionElimination makes Select skip actions it has learned are
+// hopeless: any action updated at least minVisits times whose Q-value is
+// more than threshold below the best Q-value of its state is excluded,
+// both from exploration and from the greedy choice, for as long as that
+// holds. In large action spaces, such as the 26 letters of hangman, this
+// stops exploration being spent on actions that are clearly dominated.
+//
+// Elimination is aggressive: an action that only looks bad through an
+// unlucky run is not tried again unless the best Q-value of its state
+// falls to within threshold of it, or it is learned from some other way
+// than through Select. A threshold of 0 or less, the default, disables
+// it.
+func (agent *SimpleAgent) SetActionElimination(threshold float32, minVisits int) {
+	agent.eliminateBelow = threshold
+	agent.eliminateVisits = minVisits
+}
+
+// eliminateActions removes the actions of state excluded by
+// SetActionElimination from actions.
+func (agent *SimpleAgent) eliminateActions(state State, actions []Action, value func(Action) float32) []Action {
+	if agent.eliminateBelow <= 0 {
+		return actions
+	}
+
+	values := make([]float32, len(actions))
+	best := float32(0.0)
+	for i, action := range actions {
+		values[i] = value(action)
+		if i == 0 || values[i] > best {
+			best = values[i]
+		}
+	}
+
+	visits := agent.n[state.String()]
+	kept := make([]Action, 0, len(actions))
+	for i, action := range actions {
+		if values[i] < best-agent.eliminateBelow && visits[action.String()] >= agent.eliminateVisits {
+			continue
+		}
+		kept = append(kept, action)
+	}
+
+	return kept
+}
+
+// breakTie returns the action among best preferred by the agent's
+// tie-breaker, or nil if it has none and a tie is broken at random.
+func (agent *SimpleAgent) breakTie(best []scored) Action {
+	if agent.tieBreaker == nil {
+		return nil
+	}
+
+	choice := best[0].action
+	for _, candidate := range best[1:] {
+		if agent.tieBreaker(candidate.action, choice) {
+			choice = candidate.action
+		}
+	}
+
+	return choice
+}
+
+// Range calls fn for each recorded Q-value, with the string
+// representations of its State and Action. If fn returns false, Range
+// stops. The order of iteration is not specified.
+func (agent *SimpleAgent) Range(fn func(state, action string, value float32) bool) {
+	agent.q.Range(fn)
+}
+
+// BestActionPerState returns the recorded action with the highest Q-value
+// for every state the agent has recorded, keyed by state, in a single
+// pass over the table. Ties go to the action that sorts first, so the
+// result is the same on every call. Only recorded Q-values are
+// considered: an action the agent has never updated is not chosen even
+// if the default value is higher.
+//
+// The map is new on every call and only read from the agent, so it is a
+// snapshot of the policy that can be kept, compared with that of a later
+// checkpoint, or written out as a state and action per line.
+func (agent *SimpleAgent) BestActionPerState() map[string]string {
+	best := make(map[string]string, agent.q.States())
+	bestVal := make(map[string]float32, agent.q.States())
+
+	agent.q.Range(func(state, action string, val float32) bool {
+		if v, ok := bestVal[state]; !ok || val > v || (val == v && action < best[state]) {
+			best[state] = action
+			bestVal[state] = val
+		}
+		return true
+	})
+
+	return best
+}
+
+// Policy returns the greedy policy the agent has learned: for every
+// state it has recorded, the recorded action with the highest Q-value,
+// with ties going to the action that sorts first. It is the same as
+// BestActionPerState, and like it only reads the agent.
+func (agent *SimpleAgent) Policy() map[string]string {
+	return agent.BestActionPerState()
 }
 
 // String returns the current Q-value map as a printed string.
 //
 // BUG (ecooper): This is useless.
 func (agent *SimpleAgent) String() string {
-	return fmt.Sprintf("%v", agent.q)
+	return fmt.Sprintf("%v", agent.table())
 }
 
 func init() {
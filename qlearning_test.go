@@ -0,0 +1,1510 @@
+package qlearning
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// shuffled is a state offering the actions of a graph state in the
+// reverse of their String order.
+type shuffled struct {
+	graphState
+}
+
+func (s shuffled) Next() []Action {
+	actions := s.graphState.Next()
+	for i, j := 0, len(actions)-1; i < j; i, j = i+1, j-1 {
+		actions[i], actions[j] = actions[j], actions[i]
+	}
+
+	return actions
+}
+
+func TestSelectTiesAtRandom(t *testing.T) {
+	g := graph{"s": {"b": "end", "a": "end", "c": "end"}}
+	state := shuffled{g.at("s")}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+
+	// a, b and c tie at the default value, and with no tie-breaker and
+	// no exploration each is the greedy choice a third of the time,
+	// whatever order the state offers them in.
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		counts[Next(agent, state).Action.String()]++
+	}
+	for _, action := range []string{"a", "b", "c"} {
+		if counts[action] < 900 || counts[action] > 1100 {
+			t.Errorf("Next chose %s %d times in 3000, want about 1000: %v", action, counts[action], counts)
+		}
+	}
+}
+
+func TestSelectTieBreaker(t *testing.T) {
+	g := graph{"s": {"b": "end", "a": "end", "c": "end"}}
+	state := shuffled{g.at("s")}
+
+	agent := NewSimpleAgent(1, 0)
+	agent.SetTieBreaker(func(a, b Action) bool { return a.String() > b.String() })
+	if got := agent.Select(state).Action.String(); got != "c" {
+		t.Errorf("Select chose %q with a tie-breaker preferring the last, want %q", got, "c")
+	}
+
+	agent.SetTieBreaker(func(a, b Action) bool { return a.String() < b.String() })
+	if got := agent.Select(state).Action.String(); got != "a" {
+		t.Errorf("Select chose %q with a tie-breaker preferring the first, want %q", got, "a")
+	}
+
+	agent.SetTieBreaker(nil)
+	for action, p := range agent.ActionProbabilities(state) {
+		if !near(p, 1.0/3, 1e-6) {
+			t.Errorf("probability of %s after SetTieBreaker(nil) = %g, want 1/3", action, p)
+		}
+	}
+}
+
+func TestSelectExplainedTieProbability(t *testing.T) {
+	g := graph{"s": {"b": "end", "a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+
+	explained := agent.SelectExplained(g.at("s"))
+	if explained.Probability != 0.5 {
+		t.Errorf("chose %q with probability %g, want 0.5, shared with the other tied action",
+			explained.Choice.Action, explained.Probability)
+	}
+
+	agent.SetTieBreaker(func(a, b Action) bool { return a.String() < b.String() })
+	explained = agent.SelectExplained(g.at("s"))
+	if explained.Choice.Action.String() != "a" || explained.Probability != 1 {
+		t.Errorf("chose %q with probability %g with a tie-breaker, want %q with 1",
+			explained.Choice.Action, explained.Probability, "a")
+	}
+}
+
+func TestSampleAverage(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(0.9, 0)
+	agent.SetSampleAverage(true)
+
+	rng := rand.New(rand.NewSource(1))
+	var sum float64
+	const n = 5000
+	for i := 0; i < n; i++ {
+		r := 10 * rng.Float32()
+		sum += float64(r)
+		agent.Learn(g.step("s", "a"), fixedReward(r))
+	}
+
+	v := agent.Value(g.at("s"), edge{g, "a", "end"})
+	if mean := float32(sum / n); !near(v, mean, 1e-3) {
+		t.Errorf("Q = %g, want the sample mean %g", v, mean)
+	}
+	if !near(v, 5, 0.2) {
+		t.Errorf("Q = %g, want near the true mean 5", v)
+	}
+	if visits := agent.Visits(g.at("s"), edge{g, "a", "end"}); visits != n {
+		t.Errorf("Visits = %d, want %d", visits, n)
+	}
+}
+
+// wide is a state with many actions, built once, that Next copies as a
+// State computing its actions on every call would.
+type wide struct {
+	actions []Action
+}
+
+func newWide(n int) wide {
+	g := graph{"wide": {}}
+	for i := 0; i < n; i++ {
+		name := strconv.Itoa(i)
+		g["wide"][name] = "end"
+	}
+
+	return wide{g.at("wide").Next()}
+}
+
+func (s wide) String() string {
+	return "wide"
+}
+
+func (s wide) Next() []Action {
+	return append([]Action(nil), s.actions...)
+}
+
+// wideIter is a wide state that streams its actions through NextIter.
+type wideIter struct {
+	wide
+}
+
+func (s wideIter) NextIter(fn func(Action) bool) {
+	for _, action := range s.actions {
+		if !fn(action) {
+			return
+		}
+	}
+}
+
+// iterOnly is a state whose actions can only be streamed.
+type iterOnly struct {
+	wideIter
+}
+
+func (s iterOnly) Next() []Action {
+	panic("Next called on a State implementing NextIter")
+}
+
+func TestNextIterPreferred(t *testing.T) {
+	state := iterOnly{wideIter{newWide(3)}}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(NewStateAction(state, state.actions[1], 0), fixedReward(1))
+
+	if got := NextEpsilon(agent, state, 0).Action.String(); got != "1" {
+		t.Errorf("NextEpsilon chose %q, want %q", got, "1")
+	}
+	if values := Values(agent, state); len(values) != 3 || values["1"] != 1 {
+		t.Errorf("Values = %v, want 3 actions with 1 valued 1", values)
+	}
+}
+
+func TestNextSoftmax(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	// At a temperature of 1, a is chosen e/(e+1) of the time, about 73%.
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		counts[NextSoftmax(agent, g.at("s"), 1).Action.String()]++
+	}
+	if counts["a"] < 2750 || counts["a"] > 3100 {
+		t.Errorf("a chosen %d times in 4000 at temperature 1, want about 2924", counts["a"])
+	}
+
+	for i := 0; i < 100; i++ {
+		if sa := NextSoftmax(agent, g.at("s"), 0); sa.Action.String() != "a" {
+			t.Fatalf("chose %s at temperature 0, want the greedy a", sa.Action)
+		}
+	}
+	if sa := NextSoftmax(agent, g.at("end"), 1); sa != nil {
+		t.Errorf("chose %s for a state with no actions, want nil", sa.Action)
+	}
+}
+
+func TestNextErr(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+
+	if sa, err := NextErr(agent, g.at("s")); err != nil || sa.Action.String() != "a" {
+		t.Errorf("NextErr = %v, %v; want a and no error", sa, err)
+	}
+	if sa, err := NextErr(agent, g.at("stuck")); sa != nil || !errors.Is(err, ErrNoActions) {
+		t.Errorf("NextErr for a state with no actions = %v, %v; want nil and ErrNoActions", sa, err)
+	}
+}
+
+func benchmarkNextEpsilon(b *testing.B, state State, first Action) {
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(NewStateAction(state, first, 0), fixedReward(1))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NextEpsilon(agent, state, 0)
+	}
+}
+
+func BenchmarkNextEpsilonNext(b *testing.B) {
+	state := newWide(10000)
+	benchmarkNextEpsilon(b, state, state.actions[0])
+}
+
+func BenchmarkNextEpsilonNextIter(b *testing.B) {
+	state := wideIter{newWide(10000)}
+	benchmarkNextEpsilon(b, state, state.actions[0])
+}
+
+func TestValuesMatchesValue(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	agent := NewSimpleAgentWithInit(1, 0, 0.5)
+	agent.Learn(g.step("s", "b"), fixedReward(2))
+
+	values := Values(agent, g.at("s"))
+	if len(values) != 3 {
+		t.Fatalf("Values = %v, want all 3 actions", values)
+	}
+	for _, action := range g.at("s").Next() {
+		if got, want := values[action.String()], agent.Value(g.at("s"), action); got != want {
+			t.Errorf("Values[%q] = %g, Value = %g", action, got, want)
+		}
+	}
+	if values["a"] != 0.5 {
+		t.Errorf("unseen action valued %g, want the initial value 0.5", values["a"])
+	}
+}
+
+func TestValuesFor(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	agent := NewSimpleAgentWithInit(1, 0, 0.5)
+	agent.Learn(g.step("s", "b"), fixedReward(2))
+
+	// Only the recorded b is reported, not the initial value of the
+	// others, and the result is a copy.
+	values := agent.ValuesFor(g.at("s"))
+	if len(values) != 1 || values["b"] != 2 {
+		t.Errorf("ValuesFor = %v, want only b valued 2", values)
+	}
+	values["b"] = 5
+	if v := agent.Value(g.at("s"), edge{g, "b", "end"}); v != 2 {
+		t.Errorf("Q(s, b) = %g after changing the result of ValuesFor, want 2", v)
+	}
+
+	if values := agent.ValuesFor(g.at("end")); len(values) != 0 {
+		t.Errorf("ValuesFor an unseen state = %v, want none", values)
+	}
+}
+
+func TestMaxValue(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetDefaultValue(5)
+	agent.Learn(g.step("s", "a"), fixedReward(-2))
+	agent.Learn(g.step("s", "b"), fixedReward(-1))
+
+	// The default value of the unrecorded c is not considered.
+	if max, ok := agent.MaxValue(g.at("s")); !ok || max != -1 {
+		t.Errorf("MaxValue = %g, %v; want -1, true", max, ok)
+	}
+	if max, ok := agent.MaxValue(g.at("end")); ok || max != 0 {
+		t.Errorf("MaxValue of an unseen state = %g, %v; want 0, false", max, ok)
+	}
+}
+
+func TestTopActions(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end", "d": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	for action, r := range map[string]float32{"a": 1, "b": 3, "c": 1} {
+		agent.Learn(g.step("s", action), fixedReward(r))
+	}
+
+	// The unrecorded d is left out, and a comes before c, tied with it.
+	top := agent.TopActions(g.at("s"), 5)
+	if len(top) != 3 {
+		t.Fatalf("TopActions = %v, want the 3 recorded actions", top)
+	}
+	for i, want := range []struct {
+		action string
+		value  float32
+	}{{"b", 3}, {"a", 1}, {"c", 1}} {
+		if top[i].Action.String() != want.action || top[i].Value != want.value {
+			t.Errorf("TopActions[%d] = %s valued %g, want %s valued %g", i, top[i].Action, top[i].Value, want.action, want.value)
+		}
+	}
+
+	if top := agent.TopActions(g.at("s"), 2); len(top) != 2 || top[1].Action.String() != "a" {
+		t.Errorf("TopActions(2) = %v, want b and a", top)
+	}
+	if top := agent.TopActions(g.at("s"), 0); top != nil {
+		t.Errorf("TopActions(0) = %v, want none", top)
+	}
+}
+
+func TestLearnBatchSnapshot(t *testing.T) {
+	// x and y lead to each other, so each update bootstraps from the
+	// other's cell, which both see as it was before the batch.
+	g := graph{"x": {"a": "y"}, "y": {"b": "x"}}
+	reward := rewards{"a": 1}
+
+	for _, batch := range [][]*StateAction{
+		{g.step("x", "a"), g.step("y", "b")},
+		{g.step("y", "b"), g.step("x", "a")},
+	} {
+		agent := NewSimpleAgent(1, 0.5)
+		agent.Learn(g.step("x", "a"), reward)
+		agent.LearnBatch(batch, reward)
+
+		// x moves to 1 plus half of y, 0, and y to half of x, 1.
+		x, y := agent.Value(g.at("x"), edge{g, "a", "y"}), agent.Value(g.at("y"), edge{g, "b", "x"})
+		if x != 1 || y != 0.5 {
+			t.Errorf("%s first: Q = %g, %g; want 1, 0.5 in either order", batch[0].State, x, y)
+		}
+	}
+}
+
+func TestLearnInOrder(t *testing.T) {
+	// Separate calls to Learn see each other's updates.
+	g := graph{"x": {"a": "y"}, "y": {"b": "x"}}
+	reward := rewards{"a": 1}
+
+	agent := NewSimpleAgent(1, 0.5)
+	agent.Learn(g.step("x", "a"), reward)
+	agent.Learn(g.step("y", "b"), reward)
+	if y := agent.Value(g.at("y"), edge{g, "b", "x"}); y != 0.5 {
+		t.Errorf("Q(y) = %g after x, want 0.5", y)
+	}
+	agent.Learn(g.step("x", "a"), reward)
+	if x := agent.Value(g.at("x"), edge{g, "a", "y"}); x != 1.25 {
+		t.Errorf("Q(x) = %g after y, want 1.25, seeing the update of y", x)
+	}
+}
+
+func TestTargetClip(t *testing.T) {
+	// A single state looping on itself with a discount of 1 has no
+	// fixed point, so its value grows with every update.
+	g := graph{"s": {"loop": "s"}}
+	loop := func(agent *SimpleAgent) float32 {
+		for i := 0; i < 1000; i++ {
+			agent.Learn(g.step("s", "loop"), fixedReward(1))
+		}
+		return agent.Value(g.at("s"), edge{g, "loop", "s"})
+	}
+
+	if v := loop(NewSimpleAgent(0.5, 1)); v < 100 {
+		t.Errorf("unclipped Q = %g, want it to diverge", v)
+	}
+
+	clipped := NewSimpleAgent(0.5, 1)
+	clipped.SetTargetClip(-5, 5)
+	if v := loop(clipped); v > 6 || !near(v, 6, 1e-3) {
+		t.Errorf("clipped Q = %g, want 6, the reward plus the clip", v)
+	}
+}
+
+func TestTieEpsilon(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(g.step("s", "a"), fixedReward(0.1))
+	agent.Learn(g.step("s", "b"), fixedReward(0.10000001))
+	agent.SetTieBreaker(func(a, b Action) bool { return a.String() < b.String() })
+
+	if got := agent.Select(g.at("s")).Action.String(); got != "b" {
+		t.Errorf("with exact ties, Select chose %q, want the higher %q", got, "b")
+	}
+
+	agent.SetTieEpsilon(1e-6)
+	if got := agent.Select(g.at("s")).Action.String(); got != "a" {
+		t.Errorf("with a tie epsilon, Select chose %q, want %q by the tie-breaker", got, "a")
+	}
+}
+
+func TestRewardInit(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"b": "end"}}
+	learnTwice := func(agent *SimpleAgent) (first, second float32) {
+		agent.Learn(g.step("t", "b"), fixedReward(10))
+		agent.Learn(g.step("s", "a"), fixedReward(2))
+		first = agent.Value(g.at("s"), edge{g, "a", "t"})
+		agent.Learn(g.step("s", "a"), fixedReward(2))
+		second = agent.Value(g.at("s"), edge{g, "a", "t"})
+		return first, second
+	}
+
+	// By default the first update bootstraps from Q(t, b) = 5, at the
+	// learning rate.
+	if first, _ := learnTwice(NewSimpleAgent(0.5, 0.5)); !near(first, 2.25, 1e-6) {
+		t.Errorf("default first update = %g, want 2.25", first)
+	}
+
+	agent := NewSimpleAgent(0.5, 0.5)
+	agent.SetRewardInit(true)
+	// Q(t, b) is initialized to its reward, 10, and Q(s, a) to 2 before
+	// bootstrapping from it.
+	first, second := learnTwice(agent)
+	if first != 2 {
+		t.Errorf("first update with reward init = %g, want the reward 2", first)
+	}
+	if !near(second, 4.5, 1e-6) {
+		t.Errorf("second update with reward init = %g, want 4.5, bootstrapping normally", second)
+	}
+}
+
+func TestEpsilonRestart(t *testing.T) {
+	agent := NewSimpleAgent(1, 0)
+	agent.SetExplorationSchedule(Constant(0.1))
+	agent.SetEpsilonRestart(4, 0.8)
+
+	want := []float32{0.8, 0.6, 0.4, 0.2, 0.8, 0.6, 0.4, 0.2, 0.8}
+	for episode, w := range want {
+		if got := agent.Epsilon(); !near(got, w, 1e-6) {
+			t.Errorf("episode %d: Epsilon = %g, want %g", episode, got, w)
+		}
+		agent.NewEpisode()
+	}
+
+	// The burst never lowers a schedule above it.
+	agent.SetExplorationSchedule(Constant(0.5))
+	if got := agent.Epsilon(); got != 0.6 {
+		t.Errorf("Epsilon = %g one episode into a burst over 0.5, want 0.6", got)
+	}
+	agent.NewEpisode()
+	agent.NewEpisode()
+	if got := agent.Epsilon(); got != 0.5 {
+		t.Errorf("Epsilon = %g late in a burst over 0.5, want the schedule's 0.5", got)
+	}
+
+	agent.SetEpsilonRestart(0, 0.8)
+	if got := agent.Epsilon(); got != 0.5 {
+		t.Errorf("Epsilon = %g with restarts disabled, want 0.5", got)
+	}
+}
+
+func TestEpsilonScheduleDecaysBySelection(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	agent.SetEpsilonSchedule(1, 0.1, 0.5)
+
+	want := []float32{1, 0.5, 0.25, 0.125, 0.1, 0.1}
+	for n, w := range want {
+		if got := agent.Epsilon(); !near(got, w, 1e-6) {
+			t.Errorf("after %d selections: Epsilon = %g, want %g", n, got, w)
+		}
+		Next(agent, g.at("s"))
+	}
+
+	// Updates and reading the probabilities are not selections.
+	agent.SetEpsilonSchedule(1, 0.1, 0.5)
+	for i := 0; i < 3; i++ {
+		agent.Learn(g.step("s", "a"), rewards{"a": 1})
+		agent.ActionProbabilities(g.at("s"))
+	}
+	if got := agent.Epsilon(); got != 1 {
+		t.Errorf("Epsilon = %g after updates only, want 1", got)
+	}
+}
+
+func TestLastPolicyChanged(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+
+	steps := []struct {
+		action  string
+		reward  float32
+		changed bool
+	}{
+		{"a", 1, true}, // the first update of a state
+		{"b", 0.5, false},
+		{"b", 2, true},  // b overtakes a
+		{"b", 3, false}, // b stays best
+		{"a", 4, true},  // a is back ahead
+	}
+	for i, step := range steps {
+		agent.Learn(g.step("s", step.action), fixedReward(step.reward))
+		if got := agent.LastPolicyChanged(); got != step.changed {
+			t.Errorf("update %d (%s = %g): LastPolicyChanged = %v, want %v", i, step.action, step.reward, got, step.changed)
+		}
+	}
+}
+
+func TestIsStable(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}, "t": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+
+	// a leads until the third update, when b takes over for good.
+	for i, r := range []float32{1, 2, 3} {
+		action := "a"
+		if i == 2 {
+			action, r = "b", 5
+		}
+		agent.Learn(g.step("s", action), fixedReward(r))
+	}
+	if agent.IsStable(g.at("s"), 1) {
+		t.Error("s is stable right after its best action changed")
+	}
+
+	for i := 1; i <= 3; i++ {
+		agent.Learn(g.step("s", "a"), fixedReward(float32(i)))
+		if !agent.IsStable(g.at("s"), i) || agent.IsStable(g.at("s"), i+1) {
+			t.Errorf("after %d updates keeping b best, stable for window %d: %v, for %d: %v",
+				i, i, agent.IsStable(g.at("s"), i), i+1, agent.IsStable(g.at("s"), i+1))
+		}
+	}
+
+	if agent.IsStable(g.at("t"), 0) {
+		t.Error("a state never updated is stable")
+	}
+}
+
+// coin is an action leading to heads with probability p, and otherwise
+// to tails.
+type coin struct {
+	rng          *rand.Rand
+	p            float32
+	heads, tails State
+}
+
+func (c coin) String() string {
+	return "flip"
+}
+
+func (c coin) Apply(State) State {
+	if c.rng.Float32() < c.p {
+		return c.heads
+	}
+
+	return c.tails
+}
+
+func TestStochasticTransitions(t *testing.T) {
+	g := graph{"heads": {"a": "end"}, "tails": {"a": "end"}}
+	agent := NewSimpleAgent(1, 1)
+	agent.Learn(g.step("heads", "a"), fixedReward(10))
+	agent.Learn(g.step("tails", "a"), fixedReward(2))
+	agent.SetSampleAverage(true)
+
+	flip := coin{rand.New(rand.NewSource(1)), 0.3, g.at("heads"), g.at("tails")}
+	start := g.at("start")
+	for i := 0; i < 20000; i++ {
+		agent.Learn(NewStateAction(start, flip, 0), fixedReward(1))
+	}
+
+	// The expected target is 1 + 0.3*10 + 0.7*2 = 5.4, each next state
+	// being bootstrapped from as it is reached.
+	if v := agent.Value(start, flip); !near(v, 5.4, 0.1) {
+		t.Errorf("Q = %g, want the expected target 5.4", v)
+	}
+}
+
+func TestDefaultValueAllUnseen(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0.5)
+	agent.SetDefaultValue(-1)
+
+	choice := agent.Select(g.at("s"))
+	if choice.Value != -1 || !choice.Unknown {
+		t.Errorf("chose %v valued %g, unknown %v; want the default -1 and unknown", choice.Action, choice.Value, choice.Unknown)
+	}
+
+	// An unseen next state is bootstrapped from at the default.
+	agent.Learn(g.step("x", "a"), fixedReward(1))
+	if v := agent.Value(g.at("x"), edge{g, "a", "end"}); v != 0.5 {
+		t.Errorf("Q = %g after bootstrapping from an unseen state, want 1 + 0.5*-1", v)
+	}
+}
+
+func TestDefaultValueMixedNegative(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}, "x": {"go": "s"}}
+	agent := NewSimpleAgent(1, 1)
+	agent.Learn(g.step("s", "a"), fixedReward(-2))
+
+	// The untried action, at the default 0, beats the tried, negative
+	// one.
+	if choice := agent.Select(g.at("s")); choice.Action.String() != "b" || !choice.Unknown {
+		t.Errorf("chose %v, unknown %v; want the untried b", choice.Action, choice.Unknown)
+	}
+
+	// As the next state, s is worth the default, not its tried -2.
+	agent.Learn(g.step("x", "go"), fixedReward(1))
+	if v := agent.Value(g.at("x"), edge{g, "go", "s"}); v != 1 {
+		t.Errorf("Q = %g after bootstrapping from a partly tried state, want 1", v)
+	}
+}
+
+func TestDefaultValueOptimistic(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	reward := rewards{"a": 1, "b": 3, "c": 2}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetDefaultValue(10)
+
+	tried := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		choice := agent.Select(g.at("s"))
+		tried[choice.Action.String()] = true
+		agent.Learn(choice, reward)
+	}
+	if len(tried) != 3 {
+		t.Errorf("optimistic agent tried %v in its first 3 choices, want every action", tried)
+	}
+
+	if got := agent.Select(g.at("s")).Action.String(); got != "b" {
+		t.Errorf("after trying every action, chose %q, want the best b", got)
+	}
+}
+
+func TestDivergenceLimit(t *testing.T) {
+	// A state looping on itself with a discount of 1 diverges.
+	g := graph{"s": {"loop": "s"}}
+	agent := NewSimpleAgent(0.5, 1)
+	agent.SetDivergenceLimit(50)
+
+	var err error
+	steps := 0
+	for ; steps < 1000 && err == nil; steps++ {
+		err = agent.LearnE(g.step("s", "loop"), fixedReward(1))
+	}
+
+	if !errors.Is(err, ErrDiverged) || !agent.Diverged() {
+		t.Fatalf("after %d steps: LearnE returned %v, Diverged %v; want ErrDiverged", steps, err, agent.Diverged())
+	}
+	if v := agent.Value(g.at("s"), edge{g, "loop", "s"}); v != 50 {
+		t.Errorf("diverged Q = %g, want it clamped to the limit 50", v)
+	}
+}
+
+func TestActionLearningRate(t *testing.T) {
+	g := graph{"s": {"noisy": "end", "steady": "end"}}
+	agent := NewSimpleAgent(0.5, 0)
+	agent.SetActionLearningRate("noisy", 0.1)
+
+	agent.Learn(g.step("s", "noisy"), fixedReward(10))
+	agent.Learn(g.step("s", "steady"), fixedReward(10))
+
+	if v := agent.Value(g.at("s"), edge{g, "noisy", "end"}); !near(v, 1, 1e-6) {
+		t.Errorf("Q(noisy) = %g, want 1 at its own rate 0.1", v)
+	}
+	if v := agent.Value(g.at("s"), edge{g, "steady", "end"}); v != 5 {
+		t.Errorf("Q(steady) = %g, want 5 at the agent's rate 0.5", v)
+	}
+}
+
+func TestSkipZeroReward(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "end"}, "t": {"c": "end"}}
+	agent := NewSimpleAgent(1, 1)
+	agent.Learn(g.step("t", "c"), fixedReward(1))
+	agent.SetSkipZeroReward(true)
+
+	agent.Learn(g.step("s", "a"), fixedReward(0))
+	if v, n := agent.Value(g.at("s"), edge{g, "a", "t"}), agent.Steps(); v != 0 || n != 1 {
+		t.Errorf("unrewarded step: Q = %g after %d steps, want it skipped with 0 after 1", v, n)
+	}
+
+	agent.Learn(g.step("s", "b"), fixedReward(0))
+	if n := agent.Visits(g.at("s"), edge{g, "b", "end"}); n != 1 {
+		t.Errorf("unrewarded terminal step visited %d times, want it learned from once", n)
+	}
+
+	agent.Learn(g.step("s", "a"), fixedReward(2))
+	if v := agent.Value(g.at("s"), edge{g, "a", "t"}); v != 3 {
+		t.Errorf("rewarded step: Q = %g, want 2 + Q(t) = 3", v)
+	}
+}
+
+// sparseSteps returns the steps of an episode along a line of n states,
+// rewarded only at its end.
+func sparseSteps(n int) ([]*StateAction, Rewarder) {
+	g := graph{}
+	for i := 0; i < n; i++ {
+		g["s"+strconv.Itoa(i)] = map[string]string{"a": "s" + strconv.Itoa(i+1)}
+	}
+
+	steps := make([]*StateAction, n)
+	for i := range steps {
+		steps[i] = g.step("s"+strconv.Itoa(i), "a")
+	}
+	last := steps[n-1].State.String()
+
+	return steps, rewardFunc(func(sa *StateAction) float32 {
+		if sa.State.String() == last {
+			return 1
+		}
+		return 0
+	})
+}
+
+func benchmarkSparseLearn(b *testing.B, skip bool) {
+	steps, reward := sparseSteps(100)
+	agent := NewSimpleAgent(0.5, 1)
+	agent.SetSkipZeroReward(skip)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agent.Learn(steps[i%len(steps)], reward)
+	}
+}
+
+func BenchmarkLearnSparse(b *testing.B) {
+	benchmarkSparseLearn(b, false)
+}
+
+func BenchmarkLearnSparseSkipZeroReward(b *testing.B) {
+	benchmarkSparseLearn(b, true)
+}
+
+// unenumerable is a state whose actions must never be asked for.
+type unenumerable struct {
+	graphState
+}
+
+func (s unenumerable) Next() []Action {
+	panic("Next called on the next state of an update")
+}
+
+func (s unenumerable) NextIter(fn func(Action) bool) {
+	panic("NextIter called on the next state of an update")
+}
+
+// toUnenumerable is an action leading to an unenumerable state.
+type toUnenumerable struct {
+	edge
+}
+
+func (a toUnenumerable) Apply(State) State {
+	return unenumerable{a.g.at(a.to)}
+}
+
+func TestLearnDoesNotEnumerateNext(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"b": "end", "c": "end"}}
+	agent := NewSimpleAgent(1, 1)
+	agent.Learn(g.step("t", "b"), fixedReward(2))
+
+	agent.Learn(NewStateAction(g.at("s"), toUnenumerable{edge{g, "a", "t"}}, 0), fixedReward(1))
+	if v := agent.Value(g.at("s"), edge{g, "a", "t"}); v != 3 {
+		t.Errorf("Q = %g, want 1 + the stored Q(t, b) 2", v)
+	}
+}
+
+func BenchmarkMaxNextStored(b *testing.B) {
+	state := newWide(10000)
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(NewStateAction(state, state.actions[0], 0), fixedReward(1))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agent.maxNext(state.String())
+	}
+}
+
+func BenchmarkMaxNextEnumerated(b *testing.B) {
+	state := wideIter{newWide(10000)}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(NewStateAction(state, state.actions[0], 0), fixedReward(1))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		max := agent.DefaultValue()
+		eachAction(state, func(action Action) bool {
+			if v := agent.Value(state, action); v > max {
+				max = v
+			}
+			return true
+		})
+	}
+}
+
+func TestChoiceIndependentOfNextOrder(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end", "d": "end"}}
+	choose := func(state State) []string {
+		agent := NewSimpleAgent(1, 0)
+		agent.SetSeed(1)
+		agent.SetExplorationSchedule(Constant(0.5))
+		agent.Learn(g.step("s", "c"), fixedReward(1))
+
+		var choices []string
+		for i := 0; i < 50; i++ {
+			choices = append(choices,
+				agent.Select(state).Action.String(),
+				NextEpsilon(agent, state, 0.5).Action.String(),
+				NextSoftmax(agent, state, 1).Action.String(),
+				Best(agent, state).Action.String())
+		}
+		return choices
+	}
+
+	inOrder, reversed := choose(g.at("s")), choose(shuffled{g.at("s")})
+	for i := range inOrder {
+		if inOrder[i] != reversed[i] {
+			t.Fatalf("choice %d was %q with actions in order and %q reversed", i, inOrder[i], reversed[i])
+		}
+	}
+}
+
+func TestMaxUpdateDelta(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0)
+	agent.SetMaxUpdateDelta(10)
+
+	prev := float32(0)
+	for _, r := range []float32{-1000, -1000, 50, -1000} {
+		agent.Learn(g.step("s", "a"), fixedReward(r))
+		v := agent.Value(g.at("s"), edge{g, "a", "end"})
+		if change := v - prev; change > 10 || change < -10 {
+			t.Errorf("reward %g moved Q from %g to %g, more than 10", r, prev, v)
+		}
+		prev = v
+	}
+	if prev != -20 {
+		t.Errorf("Q = %g, want -10 - 10 + 10 - 10", prev)
+	}
+
+	// A small update is not affected.
+	agent.Learn(g.step("s", "a"), fixedReward(-16))
+	if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != -18 {
+		t.Errorf("Q = %g after a change within the limit, want -18", v)
+	}
+}
+
+// twins offers the actions of a graph state with the first of them
+// offered twice.
+type twins struct {
+	graphState
+}
+
+func (s twins) Next() []Action {
+	actions := s.graphState.Next()
+	return append(actions, actions[0])
+}
+
+func TestDuplicateActionsCollapsed(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	agent.SetExplorationSchedule(Constant(1))
+
+	// Offered twice, a would be chosen two times in three if it were not
+	// collapsed into one action.
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		counts[agent.Select(twins{g.at("s")}).Action.String()]++
+	}
+	if counts["a"] < 900 || counts["a"] > 1100 {
+		t.Errorf("a chosen %d times in 2000, want about 1000", counts["a"])
+	}
+}
+
+func TestDuplicateActionsRejected(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetDuplicateActions(RejectDuplicateActions)
+
+	// Distinct actions are accepted.
+	if _, err := NextErr(agent, g.at("s")); err != nil {
+		t.Fatalf("NextErr on distinct actions returned %v", err)
+	}
+
+	if _, err := NextErr(agent, twins{g.at("s")}); !errors.Is(err, ErrDuplicateAction) {
+		t.Errorf("NextErr on a state offering a twice returned %v, want ErrDuplicateAction", err)
+	}
+	if sa := agent.Select(twins{g.at("s")}); sa != nil {
+		t.Errorf("Select on a state offering a twice chose %s, want nil", sa.Action)
+	}
+	if _, err := agent.SelectErr(g.at("end")); !errors.Is(err, ErrNoActions) {
+		t.Errorf("SelectErr on a state with no actions returned %v, want ErrNoActions", err)
+	}
+}
+
+func TestDuplicateActionsDistinct(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	agent.SetExplorationSchedule(Constant(1))
+	agent.SetDuplicateActions(DistinctDuplicateActions)
+
+	// Both copies of a are chosen, each as often as b.
+	counts := map[string]int{}
+	var second *StateAction
+	for i := 0; i < 3000; i++ {
+		sa := agent.Select(twins{g.at("s")})
+		counts[sa.Action.String()]++
+		if sa.Action.String() == "a#2" {
+			second = sa
+		}
+	}
+	for _, action := range []string{"a", "a#2", "b"} {
+		if counts[action] < 900 || counts[action] > 1100 {
+			t.Errorf("%s chosen %d times in 3000, want about 1000", action, counts[action])
+		}
+	}
+
+	// The second copy learns a Q-value of its own.
+	agent.Learn(second, fixedReward(1))
+	if a, a2 := agent.ValueKeyed("s", "a"), agent.Value(g.at("s"), second.Action); a != 0 || a2 != 1 {
+		t.Errorf("Q(a) = %g, Q(a#2) = %g after learning the second a, want 0 and 1", a, a2)
+	}
+}
+
+func TestExplorationReplay(t *testing.T) {
+	g := graph{
+		"s": {"a": "t", "b": "u", "c": "end"},
+		"t": {"a": "end", "b": "s"},
+		"u": {"a": "s", "b": "end"},
+	}
+	r := rewards{"a": 1, "c": 2}
+
+	// record trains a seeded agent for a few episodes, with ties among
+	// nearly equal values, and captures every decision.
+	record := func(seed int64) []string {
+		agent := NewSimpleAgent(0.5, 0.9)
+		agent.SetSeed(seed)
+		agent.SetExplorationSchedule(Constant(0.4))
+		agent.SetTieEpsilon(0.5)
+
+		var decisions []string
+		for episode := 0; episode < 20; episode++ {
+			state := State(g.at("s"))
+			for step := 0; step < 10 && state.String() != "end"; step++ {
+				e := agent.SelectExplained(state)
+				decisions = append(decisions, strconv.FormatBool(e.Explored)+" "+state.String()+" "+e.Choice.Action.String())
+				agent.Learn(e.Choice, r)
+				state = e.Choice.Action.Apply(state)
+			}
+		}
+		return decisions
+	}
+
+	captured, replayed := record(7), record(7)
+	if len(captured) != len(replayed) {
+		t.Fatalf("replay made %d decisions, want %d", len(replayed), len(captured))
+	}
+	for i := range captured {
+		if captured[i] != replayed[i] {
+			t.Fatalf("decision %d was %q and replayed as %q", i, captured[i], replayed[i])
+		}
+	}
+
+	other := record(8)
+	same := len(other) == len(captured)
+	for i := 0; same && i < len(other); i++ {
+		same = other[i] == captured[i]
+	}
+	if same {
+		t.Error("another seed made the same decisions")
+	}
+}
+
+func TestActionElimination(t *testing.T) {
+	g := graph{"s": {"good": "end", "bad1": "end", "bad2": "end", "bad3": "end", "rare": "end"}}
+	r := rewards{"good": 1, "bad1": -1, "bad2": -1, "bad3": -1, "rare": -1}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	agent.SetExplorationSchedule(Constant(1))
+	agent.SetActionElimination(0.5, 3)
+
+	// Exploring at random, each dominated action is tried until it has
+	// been learned from 3 times, and never after.
+	agent.Learn(g.step("s", "rare"), r)
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		sa := agent.Select(g.at("s"))
+		if sa.Action.String() == "rare" {
+			continue
+		}
+		counts[sa.Action.String()]++
+		agent.Learn(sa, r)
+	}
+	for _, bad := range []string{"bad1", "bad2", "bad3"} {
+		if counts[bad] != 3 {
+			t.Errorf("%s chosen %d times, want 3 before it is eliminated", bad, counts[bad])
+		}
+	}
+
+	// rare, learned from once, is dominated but not yet eliminated.
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		seen[agent.Select(g.at("s")).Action.String()] = true
+	}
+	if len(seen) != 2 || !seen["good"] || !seen["rare"] {
+		t.Errorf("Select chose %v, want only good and rare", seen)
+	}
+}
+
+func TestValueSquashing(t *testing.T) {
+	g := graph{"s": {}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetValueSquashing(2)
+
+	targets := []float32{-1000, -10, -1, -0.25, 0, 0.5, 3, 40, 1e6}
+	for i, target := range targets {
+		g["s"][strconv.Itoa(i)] = "end"
+		agent.Learn(g.step("s", strconv.Itoa(i)), fixedReward(target))
+	}
+
+	values := make([]float32, len(targets))
+	for i, target := range targets {
+		values[i] = agent.Value(g.at("s"), edge{g, strconv.Itoa(i), "end"})
+		if values[i] < -2 || values[i] > 2 {
+			t.Errorf("target %g stored as %g, outside [-2, 2]", target, values[i])
+		}
+	}
+
+	// Ordering is kept, strictly until values saturate at the scale.
+	for i := 1; i < len(values); i++ {
+		if values[i] < values[i-1] || values[i] == values[i-1] && values[i] != 2 {
+			t.Errorf("targets %g and %g stored as %g and %g", targets[i-1], targets[i], values[i-1], values[i])
+		}
+	}
+
+	if v := agent.Value(g.at("s"), edge{g, "2", "end"}); !near(v, -0.9242343, 1e-6) {
+		t.Errorf("target -1 stored as %g, want 2*tanh(-1/2)", v)
+	}
+}
+
+func TestMaxVisitsPerCell(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetMaxVisitsPerCell(2)
+
+	learned := 0
+	agent.OnLearn(func(*StateAction, float32, float32) { learned++ })
+	for _, r := range []float32{1, 2, 3, 4} {
+		agent.Learn(g.step("s", "a"), fixedReward(r))
+	}
+
+	a := edge{g, "a", "end"}
+	if v := agent.Value(g.at("s"), a); v != 2 {
+		t.Errorf("Q = %g, want 2 from the second and last counted update", v)
+	}
+	if n := agent.Visits(g.at("s"), a); n != 2 {
+		t.Errorf("Visits = %d, want 2", n)
+	}
+	if learned != 2 {
+		t.Errorf("OnLearn called %d times, want 2", learned)
+	}
+
+	// Other cells still have their own budget.
+	agent.Learn(g.step("s", "b"), fixedReward(5))
+	if v := agent.Value(g.at("s"), edge{g, "b", "end"}); v != 5 {
+		t.Errorf("Q of b = %g, want 5", v)
+	}
+}
+
+func TestStateAndActionCount(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "t", "c": "end"}, "t": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0.9)
+	if agent.StateCount() != 0 {
+		t.Errorf("new agent has %d states, want 0", agent.StateCount())
+	}
+
+	for _, step := range [][2]string{{"s", "a"}, {"s", "b"}, {"s", "a"}, {"t", "a"}} {
+		agent.Learn(g.step(step[0], step[1]), fixedReward(1))
+	}
+
+	if agent.StateCount() != 2 || agent.States() != 2 {
+		t.Errorf("StateCount = %d and States = %d, want s and t", agent.StateCount(), agent.States())
+	}
+	for state, want := range map[string]int{"s": 2, "t": 1, "end": 0} {
+		if got := agent.ActionCount(g.at(state)); got != want {
+			t.Errorf("ActionCount(%s) = %d, want %d", state, got, want)
+		}
+	}
+}
+
+func TestLearningRateDecay(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(0.8, 0)
+	agent.SetLearningRateDecay(0.5)
+
+	// rate returns the share of the way to its reward that an update of
+	// action moved its Q-value.
+	rate := func(action string, r float32) float32 {
+		old := agent.Value(g.at("s"), edge{g, action, "end"})
+		agent.Learn(g.step("s", action), fixedReward(r))
+		return (agent.Value(g.at("s"), edge{g, action, "end"}) - old) / (r - old)
+	}
+
+	prev := float32(1)
+	for n := 0; n < 6; n++ {
+		r := float32(10)
+		if n%2 == 1 {
+			r = -10
+		}
+		got := rate("a", r)
+		if want := 0.8 / (1 + 0.5*float32(n)); !near(got, want, 1e-5) {
+			t.Errorf("update %d of a: rate %g, want %g", n+1, got, want)
+		}
+		if got >= prev {
+			t.Errorf("update %d of a: rate %g, not below %g of the update before", n+1, got, prev)
+		}
+		prev = got
+	}
+
+	// b decays from its own first update.
+	if got := rate("b", 10); !near(got, 0.8, 1e-6) {
+		t.Errorf("first update of b: rate %g, want 0.8", got)
+	}
+}
+
+func TestMaxRecorded(t *testing.T) {
+	if got := maxRecorded(map[string]float32{"a": -1, "b": 3, "c": 2}); got != 3 {
+		t.Errorf("maxRecorded = %g, want 3", got)
+	}
+	if got := maxRecorded(map[string]float64{"a": -1, "b": -2}); got != 0 {
+		t.Errorf("maxRecorded of negative values = %g, want 0", got)
+	}
+	if got := maxRecorded(map[string]float32(nil)); got != 0 {
+		t.Errorf("maxRecorded of an unseen state = %g, want 0", got)
+	}
+}
+
+func TestOnLearnTDErrorsShrink(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+
+	var errs []float32
+	rewarded := 0
+	agent.OnLearn(func(sa *StateAction, tdError, reward float32) {
+		if tdError < 0 {
+			tdError = -tdError
+		}
+		errs[len(errs)-1] += tdError
+		if reward == 1 {
+			rewarded++
+		}
+	})
+
+	unhooked := NewSimpleAgent(0.5, 0.9)
+	for episode := 0; episode < 30; episode++ {
+		errs = append(errs, 0)
+		for _, step := range [][2]string{{"s0", "a"}, {"s1", "a"}, {"s2", "win"}} {
+			agent.Learn(corridor.step(step[0], step[1]), rewards{"win": 1})
+			unhooked.Learn(corridor.step(step[0], step[1]), rewards{"win": 1})
+		}
+	}
+
+	if rewarded != 30 {
+		t.Errorf("hook saw the reward of 1 %d times, want once an episode", rewarded)
+	}
+	if errs[0] != 1 {
+		t.Errorf("TD errors of the first episode add up to %g, want the 1 of its reward", errs[0])
+	}
+	last := errs[len(errs)-1]
+	if last > errs[0]/100 {
+		t.Errorf("TD errors of the last episode add up to %g, want them to shrink from %g: %v", last, errs[0], errs)
+	}
+	for i := 10; i < len(errs); i++ {
+		if errs[i] > errs[i-1] {
+			t.Errorf("TD errors grew from %g to %g in episode %d", errs[i-1], errs[i], i+1)
+		}
+	}
+
+	// The hook does not change what is learned.
+	a := edge{corridor, "a", "s1"}
+	if v, w := agent.Value(corridor.at("s0"), a), unhooked.Value(corridor.at("s0"), a); v != w {
+		t.Errorf("Q(s0, a) = %g with the hook, %g without", v, w)
+	}
+}
+
+// identifyArm plays a stationary 10-armed bandit, whose arm i pays
+// i/10 on average plus uniform noise in [-0.5, 0.5), choosing with
+// choose for 2000 pulls, and returns the number of pulls after which
+// Best always chose the best arm, 9.
+func identifyArm(agent *SimpleAgent, choose func(state State) *StateAction) int {
+	g := graph{"arms": {}}
+	for i := 0; i < 10; i++ {
+		g["arms"][strconv.Itoa(i)] = "arms"
+	}
+	rng := rand.New(rand.NewSource(1))
+	pay := rewardFunc(func(sa *StateAction) float32 {
+		i, _ := strconv.Atoi(sa.Action.String())
+		return float32(i)/10 + rng.Float32() - 0.5
+	})
+
+	identified := 0
+	for pull := 1; pull <= 2000; pull++ {
+		agent.Learn(choose(g.at("arms")), pay)
+		if Best(agent, g.at("arms")).Action.String() != "9" {
+			identified = pull
+		}
+	}
+
+	return identified
+}
+
+func TestNextUCBIdentifiesBestArmSooner(t *testing.T) {
+	ucb := NewSimpleAgent(1, 0)
+	ucb.SetSampleAverage(true)
+	ucb.SetSeed(1)
+	ucbPulls := identifyArm(ucb, func(state State) *StateAction { return NextUCB(ucb, state, 1) })
+
+	greedy := NewSimpleAgent(1, 0)
+	greedy.SetSampleAverage(true)
+	greedy.SetSeed(1)
+	greedyPulls := identifyArm(greedy, func(state State) *StateAction { return NextEpsilon(greedy, state, 0.1) })
+
+	if ucbPulls >= greedyPulls {
+		t.Errorf("UCB settled on the best arm after %d pulls, epsilon-greedy after %d; want UCB sooner", ucbPulls, greedyPulls)
+	}
+	if ucbPulls == 2000 {
+		t.Error("UCB never settled on the best arm")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	g := graph{
+		"s": {"a": "t", "b": "t", "c": "end"},
+		"t": {"x": "end", "y": "end"},
+		"u": {"a": "end"},
+	}
+	agent := NewSimpleAgent(1, 0)
+	for _, step := range []struct {
+		state, action string
+		reward        float32
+	}{
+		{"s", "a", 1}, {"s", "b", 3}, {"s", "c", 2},
+		{"t", "y", 1}, {"t", "x", 1}, // a tie, going to x
+		{"u", "a", -5},
+	} {
+		agent.Learn(g.step(step.state, step.action), fixedReward(step.reward))
+	}
+
+	steps, table := agent.Steps(), agent.String()
+	want := map[string]string{"s": "b", "t": "x", "u": "a"}
+	for i := 0; i < 3; i++ {
+		policy := agent.Policy()
+		if len(policy) != len(want) {
+			t.Fatalf("Policy = %v, want %v", policy, want)
+		}
+		for state, action := range want {
+			if policy[state] != action {
+				t.Errorf("Policy[%s] = %q, want %q", state, policy[state], action)
+			}
+		}
+	}
+
+	if agent.Steps() != steps || agent.String() != table {
+		t.Error("Policy changed the agent")
+	}
+}
+
+// counted is a state with 26 actions, one per letter, that counts the
+// calls of its String method in calls. Its actions lead to the state
+// named after them.
+type counted struct {
+	name  string
+	calls *int
+}
+
+func (c counted) String() string {
+	*c.calls++
+	return c.name
+}
+
+func (c counted) Next() []Action {
+	actions := make([]Action, 26)
+	for i := range actions {
+		actions[i] = letter{byte('a' + i)}
+	}
+
+	return actions
+}
+
+// letter is an action of counted.
+type letter struct {
+	b byte
+}
+
+func (l letter) String() string {
+	return string(l.b)
+}
+
+func (l letter) Apply(state State) State {
+	return counted{l.String(), state.(counted).calls}
+}
+
+func TestLearnKeyedMatchesLearn(t *testing.T) {
+	var calls int
+	plain, keyed := NewSimpleAgent(0.5, 0.9), NewSimpleAgent(0.5, 0.9)
+	reward := rewardFunc(func(sa *StateAction) float32 {
+		return float32(sa.Action.(letter).b-'a') / 26
+	})
+
+	state := counted{"a", &calls}
+	for i := 0; i < 200; i++ {
+		action := letter{byte('a' + i*7%26)}
+		plain.Learn(NewStateAction(state, action, 0), reward)
+		if err := keyed.LearnKeyed(state.name, NewStateAction(state, action, 0), reward); err != nil {
+			t.Fatal(err)
+		}
+		state = action.Apply(state).(counted)
+	}
+
+	plain.q.Range(func(s, a string, v float32) bool {
+		if k := keyed.ValueKeyed(s, a); k != v {
+			t.Errorf("%s %s: LearnKeyed %g, Learn %g", s, a, k, v)
+		}
+		if k := keyed.Value(counted{s, &calls}, letter{a[0]}); k != v {
+			t.Errorf("%s %s: Value %g, Learn %g", s, a, k, v)
+		}
+		return true
+	})
+}
+
+func TestLearnStepStringCalls(t *testing.T) {
+	var calls int
+	agent := NewSimpleAgent(0.5, 0.9)
+	state := counted{"a", &calls}
+
+	// A selection finds the string of the state once, not once per
+	// action, and so does Learn, besides that of the next state.
+	sa := Next(agent, state)
+	agent.Learn(sa, fixedReward(1))
+	if calls > 3 {
+		t.Errorf("%d String calls for a step of 26 actions, want at most 3", calls)
+	}
+
+	calls = 0
+	sa = Next(agent, state)
+	agent.LearnKeyed(state.name, sa, fixedReward(1))
+	if calls > 2 {
+		t.Errorf("%d String calls for a keyed step, want at most 2", calls)
+	}
+}
+
+// benchmarkLearnStep selects and learns from an action of a counted
+// state with learn, reporting the String calls per step.
+func benchmarkLearnStep(b *testing.B, learn func(agent *SimpleAgent, state counted, sa *StateAction)) {
+	var calls int
+	agent := NewSimpleAgent(0.5, 0.9)
+	state := counted{"a", &calls}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sa := Next(agent, state)
+		learn(agent, state, sa)
+		state = sa.Action.Apply(state).(counted)
+	}
+	b.ReportMetric(float64(calls)/float64(b.N), "strings/op")
+}
+
+func BenchmarkLearnStep(b *testing.B) {
+	benchmarkLearnStep(b, func(agent *SimpleAgent, state counted, sa *StateAction) {
+		agent.Learn(sa, fixedReward(1))
+	})
+}
+
+func BenchmarkLearnKeyedStep(b *testing.B) {
+	benchmarkLearnStep(b, func(agent *SimpleAgent, state counted, sa *StateAction) {
+		agent.LearnKeyed(state.name, sa, fixedReward(1))
+	})
+}
+
+func TestDiscountSchedule(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"b": "end"}}
+	agent := NewSimpleAgent(1, 0.9)
+	agent.q.Set("t", "b", 1)
+	schedule := func(episode int) float32 {
+		return 0.25 * float32(episode)
+	}
+	agent.SetDiscountSchedule(schedule)
+
+	// With a learning rate of 1 and no reward, the update sets Q to the
+	// discounted value of t, 1, and so to the discount itself.
+	for episode := 0; episode < 4; episode++ {
+		if d := agent.CurrentDiscount(); d != schedule(episode) {
+			t.Errorf("episode %d: discount %g, want %g", episode, d, schedule(episode))
+		}
+		agent.Learn(g.step("s", "a"), fixedReward(0))
+		if v := agent.ValueKeyed("s", "a"); v != schedule(episode) {
+			t.Errorf("episode %d: Q = %g, want the discount %g", episode, v, schedule(episode))
+		}
+		agent.NewEpisode()
+	}
+	if n := agent.Episodes(); n != 4 {
+		t.Errorf("%d episodes, want 4", n)
+	}
+
+	agent.SetDiscountSchedule(nil)
+	agent.Learn(g.step("s", "a"), fixedReward(0))
+	if v := agent.ValueKeyed("s", "a"); !near(v, 0.9, 1e-6) {
+		t.Errorf("Q = %g without a schedule, want the fixed discount 0.9", v)
+	}
+}
+
+func TestNonFiniteRewards(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"b": "end"}}
+	inf, nan := float32(math.Inf(1)), float32(math.NaN())
+
+	agent := NewSimpleAgent(0.5, 0.9)
+	agent.Learn(g.step("t", "b"), fixedReward(1))
+	for _, r := range []float32{inf, nan, -inf} {
+		if err := agent.LearnE(g.step("s", "a"), fixedReward(r)); !errors.Is(err, ErrNonFinite) {
+			t.Errorf("LearnE of a reward of %g returned %v, want ErrNonFinite", r, err)
+		}
+		agent.Learn(g.step("s", "a"), fixedReward(r))
+	}
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	agent.q.Range(func(state, action string, v float32) bool {
+		if isNaN(v) || isInf(v) {
+			t.Errorf("%s %s: Q = %g, want a finite value", state, action, v)
+		}
+		return true
+	})
+	if v := agent.ValueKeyed("s", "a"); !near(v, 0.5*(1+0.9*0.5), 1e-6) {
+		t.Errorf("Q = %g, want only the finite reward learned", v)
+	}
+	if n := agent.Steps(); n != 2 {
+		t.Errorf("%d updates made, want the 2 with finite rewards", n)
+	}
+}
+
+func TestNonFiniteRewardsClipped(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetRewardClip(-10, 10)
+
+	if err := agent.LearnE(g.step("s", "a"), fixedReward(float32(math.Inf(1)))); err != nil {
+		t.Fatal(err)
+	}
+	if v := agent.ValueKeyed("s", "a"); v != 10 {
+		t.Errorf("Q = %g after an infinite reward clipped to [-10, 10], want 10", v)
+	}
+	if err := agent.LearnE(g.step("s", "a"), fixedReward(float32(math.NaN()))); !errors.Is(err, ErrNonFinite) {
+		t.Errorf("LearnE of a NaN reward returned %v, want ErrNonFinite even with a clip", err)
+	}
+}
+
+func TestNonFiniteValueClampedByDivergenceLimit(t *testing.T) {
+	g := graph{"s": {"a": "t"}}
+	agent := NewSimpleAgent(1, 1)
+	agent.q.Set("t", "b", math.MaxFloat32)
+	agent.SetDivergenceLimit(1000)
+
+	if err := agent.LearnE(g.step("s", "a"), fixedReward(math.MaxFloat32)); !errors.Is(err, ErrDiverged) {
+		t.Errorf("LearnE of an overflowing update returned %v, want ErrDiverged", err)
+	}
+	if v := agent.ValueKeyed("s", "a"); v != 1000 {
+		t.Errorf("Q = %g after overflowing, want it clamped to 1000", v)
+	}
+}
+
+// weightedShares returns the share of n choices of NextWeighted that
+// went to each action of g's state s.
+func weightedShares(agent *SimpleAgent, g graph, n int) map[string]float64 {
+	shares := make(map[string]float64)
+	for i := 0; i < n; i++ {
+		shares[NextWeighted(agent, g.at("s")).Action.String()] += 1 / float64(n)
+	}
+
+	return shares
+}
+
+func TestNextWeighted(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	for action, r := range map[string]float32{"a": 3, "b": 1, "c": -1} {
+		agent.Learn(g.step("s", action), fixedReward(r))
+	}
+
+	// Shifted by the lowest value, -1, plus a third of the spread of 4,
+	// the weights are 16/3, 10/3 and 4/3 of a total of 10.
+	shares := weightedShares(agent, g, 10000)
+	for action, want := range map[string]float64{"a": 0.533, "b": 0.333, "c": 0.133} {
+		if got := shares[action]; math.Abs(got-want) > 0.02 {
+			t.Errorf("%s chosen %.3f of the time, want about %.3f", action, got, want)
+		}
+	}
+}
+
+func TestNextWeightedUniform(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	agent.Learn(g.step("s", "a"), fixedReward(-1))
+
+	// No value is positive, so every action is as likely.
+	shares := weightedShares(agent, g, 9000)
+	for _, action := range []string{"a", "b", "c"} {
+		if got := shares[action]; math.Abs(got-1.0/3) > 0.02 {
+			t.Errorf("%s chosen %.3f of the time, want about 1/3", action, got)
+		}
+	}
+
+	if sa := NextWeighted(agent, g.at("end")); sa != nil {
+		t.Errorf("chose %v in a state with no actions, want nil", sa)
+	}
+}
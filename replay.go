@@ -0,0 +1,233 @@
+package qlearning
+
+import (
+	"math"
+	"math/rand"
+)
+
+// MinPriority is added to the priority of every experience added with
+// AddPriority, so that one whose TD error was 0 can still be sampled.
+const MinPriority = 1e-3
+
+// ReplayBuffer keeps the most recent experiences of an agent, each an
+// action and the reward it was given, so that they can be learned from
+// again in random order, which breaks up the correlation between
+// consecutive steps of an episode. Once full, each experience added
+// evicts the oldest.
+//
+// Replaying an experience applies its action to its State again, so the
+// States added must be values that Apply does not change in place, but
+// returns a changed copy of. The hangman example's Game is changed in
+// place, and its Choice would have to copy it before replay could be
+// used there. With such States, a training loop adds each action after
+// learning from it, and learns from a batch of replays as it goes:
+//
+//	action := qlearning.Next(agent, state)
+//	agent.Learn(action, rewarder)
+//	buf.Add(action, rewarder.Reward(action))
+//	agent.LearnFromBuffer(buf, 32)
+//	state = action.Action.Apply(state)
+type ReplayBuffer struct {
+	rng *rand.Rand
+
+	buf  []experience
+	next int
+	full bool
+
+	// maxPriority is the highest priority added so far, given to the
+	// experiences added without one.
+	maxPriority float32
+}
+
+// experience is an action added to a ReplayBuffer, its reward, and its
+// priority for SamplePrioritized.
+type experience struct {
+	action   *StateAction
+	reward   float32
+	priority float32
+}
+
+// NewReplayBuffer creates an empty ReplayBuffer holding up to capacity
+// experiences, sampled from a source seeded with seed, so that replays
+// can be reproduced.
+//
+// NewReplayBuffer panics if capacity is not positive.
+func NewReplayBuffer(capacity int, seed int64) *ReplayBuffer {
+	if capacity <= 0 {
+		panic("qlearning: NewReplayBuffer needs a positive capacity")
+	}
+
+	return &ReplayBuffer{
+		rng:         rand.New(rand.NewSource(seed)),
+		buf:         make([]experience, capacity),
+		maxPriority: 1,
+	}
+}
+
+// Add records sa and its reward, evicting the oldest experience if the
+// buffer is full. For SamplePrioritized, it is given the highest
+// priority added so far, so that it is sampled at least as often as any
+// other until its TD error is known.
+func (b *ReplayBuffer) Add(sa *StateAction, reward float32) {
+	b.add(experience{sa, reward, b.maxPriority})
+}
+
+// AddPriority records sa and its reward as Add does, with a priority of
+// the magnitude of its temporal difference error plus MinPriority, so
+// that SamplePrioritized draws the experiences the agent predicted worst
+// more often. To sample with a priority exponent other than 1, pass the
+// error already raised to it.
+func (b *ReplayBuffer) AddPriority(sa *StateAction, reward, tdError float32) {
+	p := float32(math.Abs(float64(tdError))) + MinPriority
+	if p > b.maxPriority {
+		b.maxPriority = p
+	}
+
+	b.add(experience{sa, reward, p})
+}
+
+// add records e, evicting the oldest experience if the buffer is full.
+func (b *ReplayBuffer) add(e experience) {
+	b.buf[b.next] = e
+	b.next = (b.next + 1) % len(b.buf)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Len returns the number of experiences in the buffer.
+func (b *ReplayBuffer) Len() int {
+	if b.full {
+		return len(b.buf)
+	}
+
+	return b.next
+}
+
+// Sample returns n experiences drawn uniformly at random, with
+// replacement, or nil if the buffer is empty.
+func (b *ReplayBuffer) Sample(n int) []*StateAction {
+	sampled := b.sample(n)
+	if sampled == nil {
+		return nil
+	}
+
+	actions := make([]*StateAction, len(sampled))
+	for i, e := range sampled {
+		actions[i] = e.action
+	}
+
+	return actions
+}
+
+// sample is Sample, also returning the rewards.
+func (b *ReplayBuffer) sample(n int) []experience {
+	if b.Len() == 0 || n <= 0 {
+		return nil
+	}
+
+	sampled := make([]experience, n)
+	for i := range sampled {
+		sampled[i] = b.buf[b.rng.Intn(b.Len())]
+	}
+
+	return sampled
+}
+
+// SamplePrioritized returns n experiences drawn at random, with
+// replacement, each with probability proportional to its priority, or
+// nil if the buffer is empty. Drawing some experiences more often than
+// others biases what is learned from them toward those, so alongside
+// each it returns the importance-sampling weight
+//
+//	(N * P(i))^-beta / max_j (N * P(j))^-beta
+//
+// where N is the number of experiences in the buffer and P(i) the
+// probability of drawing experience i, by which to scale its update. A
+// beta of 0 makes every weight 1, and a beta of 1 corrects the bias
+// fully; beta is usually raised from around 0.4 to 1 over training. The
+// weights are divided by the highest weight in the buffer, so they are
+// at most 1 and only ever shrink an update.
+//
+// Each draw takes time linear in the size of the buffer.
+func (b *ReplayBuffer) SamplePrioritized(n int, beta float32) ([]*StateAction, []float32) {
+	sampled, weights := b.samplePrioritized(n, beta)
+	if sampled == nil {
+		return nil, nil
+	}
+
+	actions := make([]*StateAction, len(sampled))
+	for i, e := range sampled {
+		actions[i] = e.action
+	}
+
+	return actions, weights
+}
+
+// samplePrioritized is SamplePrioritized, also returning the rewards.
+func (b *ReplayBuffer) samplePrioritized(n int, beta float32) ([]experience, []float32) {
+	if b.Len() == 0 || n <= 0 {
+		return nil, nil
+	}
+
+	experiences := b.buf[:b.Len()]
+	total, min := 0.0, math.Inf(1)
+	for _, e := range experiences {
+		total += float64(e.priority)
+		min = math.Min(min, float64(e.priority))
+	}
+
+	// The highest weight is that of the least likely experience.
+	count := float64(len(experiences))
+	max := math.Pow(count*min/total, -float64(beta))
+
+	sampled := make([]experience, n)
+	weights := make([]float32, n)
+	for i := range sampled {
+		r := b.rng.Float64() * total
+		j := 0
+		for ; j < len(experiences)-1; j++ {
+			if r -= float64(experiences[j].priority); r < 0 {
+				break
+			}
+		}
+
+		p := float64(experiences[j].priority) / total
+		sampled[i] = experiences[j]
+		weights[i] = float32(math.Pow(count*p, -float64(beta)) / max)
+	}
+
+	return sampled, weights
+}
+
+// LearnFromBuffer learns from batchSize experiences sampled from buf, in
+// the order sampled, each with the reward it was added with. Each is
+// a full update, counted by Steps and Visits like any other.
+func (agent *SimpleAgent) LearnFromBuffer(buf *ReplayBuffer, batchSize int) {
+	for _, e := range buf.sample(batchSize) {
+		agent.LearnE(e.action, fixedReward(e.reward))
+	}
+}
+
+// fixedReward is a Rewarder giving the same reward to every action.
+type fixedReward float32
+
+func (r fixedReward) Reward(action *StateAction) float32 {
+	return float32(r)
+}
+
+// LearnFromPrioritized learns from batchSize experiences drawn from buf
+// by SamplePrioritized with the provided beta, in the order sampled,
+// each with the reward it was added with and its learning rate scaled by
+// its importance-sampling weight. The priorities in buf are left as they
+// were added; to update them, add the experiences again with AddPriority
+// and their new TD errors.
+func (agent *SimpleAgent) LearnFromPrioritized(buf *ReplayBuffer, batchSize int, beta float32) {
+	sampled, weights := buf.samplePrioritized(batchSize, beta)
+	defer func() { agent.weight = 0 }()
+
+	for i, e := range sampled {
+		agent.weight = weights[i]
+		agent.LearnE(e.action, fixedReward(e.reward))
+	}
+}
@@ -0,0 +1,184 @@
+package qlearning
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// numbered returns the StateAction of taking action i of a state s,
+// i.e. a distinct experience for every i.
+func numbered(i int) *StateAction {
+	name := strconv.Itoa(i)
+	g := graph{"s": {name: "end"}}
+	return g.step("s", name)
+}
+
+func TestReplayBufferEvictsOldest(t *testing.T) {
+	buf := NewReplayBuffer(3, 1)
+	if buf.Len() != 0 || buf.Sample(1) != nil {
+		t.Fatalf("new buffer has %d experiences and sampled %v", buf.Len(), buf.Sample(1))
+	}
+
+	for i := 0; i < 5; i++ {
+		buf.Add(numbered(i), float32(i))
+		want := i + 1
+		if want > 3 {
+			want = 3
+		}
+		if buf.Len() != want {
+			t.Errorf("Len = %d after %d adds, want %d", buf.Len(), i+1, want)
+		}
+	}
+
+	for _, sa := range buf.Sample(200) {
+		if a := sa.Action.String(); a == "0" || a == "1" {
+			t.Fatalf("sampled experience %s, which was evicted", a)
+		}
+	}
+}
+
+func TestReplayBufferSamplesUniformly(t *testing.T) {
+	buf := NewReplayBuffer(8, 1)
+	for i := 0; i < 4; i++ {
+		buf.Add(numbered(i), 0)
+	}
+
+	counts := map[string]int{}
+	for _, sa := range buf.Sample(8000) {
+		counts[sa.Action.String()]++
+	}
+	for i := 0; i < 4; i++ {
+		if n := counts[strconv.Itoa(i)]; n < 1800 || n > 2200 {
+			t.Errorf("experience %d sampled %d times in 8000, want about 2000", i, n)
+		}
+	}
+
+	// The same seed samples the same experiences.
+	a, b := NewReplayBuffer(8, 2), NewReplayBuffer(8, 2)
+	for i := 0; i < 4; i++ {
+		a.Add(numbered(i), 0)
+		b.Add(numbered(i), 0)
+	}
+	sampledA, sampledB := a.Sample(50), b.Sample(50)
+	for i := range sampledA {
+		if sampledA[i].Action.String() != sampledB[i].Action.String() {
+			t.Fatalf("sample %d was %v and %v from identically seeded buffers", i, sampledA[i].Action, sampledB[i].Action)
+		}
+	}
+}
+
+func TestLearnFromBuffer(t *testing.T) {
+	buf := NewReplayBuffer(4, 1)
+	buf.Add(numbered(7), 2)
+
+	agent := NewSimpleAgent(0.5, 0)
+	agent.LearnFromBuffer(buf, 3)
+
+	// Three replays of a reward of 2, each halfway: 1, 1.5, 1.75.
+	sa := numbered(7)
+	if v := agent.Value(sa.State, sa.Action); v != 1.75 {
+		t.Errorf("Q = %g after 3 replays, want 1.75", v)
+	}
+	if agent.Steps() != 3 || agent.Visits(sa.State, sa.Action) != 3 {
+		t.Errorf("Steps = %d and Visits = %d, want 3 each", agent.Steps(), agent.Visits(sa.State, sa.Action))
+	}
+}
+
+func TestNewReplayBufferCapacity(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewReplayBuffer(0, 1) did not panic")
+		}
+	}()
+	NewReplayBuffer(0, 1)
+}
+
+func TestSamplePrioritizedFrequency(t *testing.T) {
+	buf := NewReplayBuffer(3, 1)
+	for i, tdError := range []float32{1, 1, 8} {
+		buf.AddPriority(numbered(i), 0, tdError)
+	}
+
+	counts := make(map[string]int)
+	const n = 10000
+	sampled, _ := buf.SamplePrioritized(n, 0)
+	for _, sa := range sampled {
+		counts[sa.Action.String()]++
+	}
+
+	// The priorities are about 1, 1 and 8, of a total of 10.
+	for action, want := range map[string]float64{"0": 0.1, "1": 0.1, "2": 0.8} {
+		if got := float64(counts[action]) / n; math.Abs(got-want) > 0.02 {
+			t.Errorf("experience %s sampled %.3f of the time, want about %.1f", action, got, want)
+		}
+	}
+}
+
+func TestSamplePrioritizedWeights(t *testing.T) {
+	buf := NewReplayBuffer(4, 1)
+	for i, tdError := range []float32{1, 3} {
+		buf.AddPriority(numbered(i), 0, tdError-MinPriority)
+	}
+
+	// With beta 1, each weight is the lowest priority over the priority
+	// of the experience, and on average the sampled weights are N times
+	// the lowest probability, 2 * 1/4.
+	sampled, weights := buf.SamplePrioritized(10000, 1)
+	var sum float64
+	for i, sa := range sampled {
+		want := float32(1)
+		if sa.Action.String() == "1" {
+			want = 1.0 / 3
+		}
+		if !near(weights[i], want, 1e-4) {
+			t.Fatalf("weight of experience %s = %g, want %g", sa.Action, weights[i], want)
+		}
+		sum += float64(weights[i])
+	}
+	if mean := sum / float64(len(weights)); math.Abs(mean-0.5) > 0.01 {
+		t.Errorf("mean weight %.3f, want about 0.5", mean)
+	}
+
+	_, weights = buf.SamplePrioritized(100, 0)
+	for _, w := range weights {
+		if w != 1 {
+			t.Fatalf("weight %g with beta 0, want 1", w)
+		}
+	}
+}
+
+func TestReplayBufferAddMaxPriority(t *testing.T) {
+	buf := NewReplayBuffer(2, 1)
+	buf.AddPriority(numbered(0), 0, 5)
+	buf.Add(numbered(1), 0)
+
+	// The experience added without a priority has the highest so far,
+	// and so is sampled as often.
+	_, weights := buf.SamplePrioritized(100, 1)
+	for _, w := range weights {
+		if w != 1 {
+			t.Fatalf("weight %g, want 1 for two experiences of equal priority", w)
+		}
+	}
+}
+
+func TestLearnFromPrioritized(t *testing.T) {
+	buf := NewReplayBuffer(2, 1)
+	buf.AddPriority(numbered(0), 1, 1-MinPriority)
+	buf.AddPriority(numbered(1), 1, 3-MinPriority)
+
+	// Each update is scaled by its weight, 1 for experience 0 and 1/3
+	// for the three times likelier experience 1.
+	for i := 0; i < 20; i++ {
+		agent := NewSimpleAgent(0.9, 0)
+		agent.LearnFromPrioritized(buf, 1, 1)
+		v0, v1 := agent.ValueKeyed("s", "0"), agent.ValueKeyed("s", "1")
+		if !(near(v0, 0.9, 1e-5) && v1 == 0 || v0 == 0 && near(v1, 0.3, 1e-5)) {
+			t.Fatalf("Q-values %g and %g, want 0.9 for experience 0 or 0.3 for experience 1", v0, v1)
+		}
+		if agent.weight != 0 {
+			t.Fatalf("weight %g left after learning, want 0", agent.weight)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package qlearning
+
+// SoftReset sets every recorded Q-value back to the value it started
+// from, that of the initializer if one is set or else the default
+// value, while keeping the set of states and actions the agent has
+// seen, so that coverage carries over between stages of a curriculum
+// and the table does not have to grow again. If keepVisits is false, update counts are
+// zeroed as well; otherwise they are kept. Steps and reward statistics
+// are kept either way, target values are reset to the Q-values, and no
+// state is stable, nor the agent converged, after a reset.
+func (agent *SimpleAgent) SoftReset(keepVisits bool) {
+	for state, actions := range agent.table() {
+		for action := range actions {
+			agent.setValue(state, action, agent.seedValue(state, action))
+		}
+	}
+
+	for state, actions := range agent.smoothed {
+		for action := range actions {
+			actions[action] = agent.seedValue(state, action)
+		}
+	}
+
+	if !keepVisits {
+		for _, visits := range agent.n {
+			for action := range visits {
+				visits[action] = 0
+			}
+		}
+	}
+
+	if agent.targets != nil {
+		agent.targets = make(map[string]map[string]targetValue)
+	}
+
+	agent.policyChanged = false
+	agent.unchanged = make(map[string]int)
+	agent.resetConvergence()
+}
+
+// Reset forgets everything the agent has learned, leaving it as
+// NewSimpleAgent would create it: no Q-values, update counts, steps,
+// episodes, reward statistics, smoothed or target values, update
+// history, or convergence metric. Its learning rate and discount are kept, as is every option
+// set on it. The tables are emptied rather than replaced, so an agent
+// reset between stages of a curriculum reuses the memory they already
+// hold.
+func (agent *SimpleAgent) Reset() {
+	agent.q.Clear()
+	for state := range agent.n {
+		delete(agent.n, state)
+	}
+	for state := range agent.smoothed {
+		delete(agent.smoothed, state)
+	}
+	for state := range agent.targets {
+		delete(agent.targets, state)
+	}
+	for state := range agent.unchanged {
+		delete(agent.unchanged, state)
+	}
+
+	agent.steps = 0
+	agent.episode = 0
+	agent.SetDiscountSchedule(agent.discountSchedule)
+	agent.rewards = runningStat{}
+	agent.actionRewards = nil
+
+	agent.history.reset()
+	agent.policyChanged = false
+	agent.diverged = false
+	agent.resetConvergence()
+	agent.resetRecency()
+}
@@ -0,0 +1,57 @@
+package qlearning
+
+import "testing"
+
+func TestSoftReset(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	for _, keepVisits := range []bool{true, false} {
+		agent := NewSimpleAgent(1, 0)
+		agent.SetInitializer(func(state, action string) float32 {
+			if action == "a" {
+				return 2
+			}
+			return -1
+		})
+		agent.Learn(g.step("s", "a"), fixedReward(5))
+		agent.Learn(g.step("s", "b"), fixedReward(5))
+
+		agent.SoftReset(keepVisits)
+
+		if a, b := agent.Value(g.at("s"), edge{g, "a", "end"}), agent.Value(g.at("s"), edge{g, "b", "end"}); a != 2 || b != -1 {
+			t.Errorf("keepVisits %v: Q = %g, %g after SoftReset, want the initializer's 2, -1", keepVisits, a, b)
+		}
+		if agent.States() != 1 {
+			t.Errorf("keepVisits %v: %d states after SoftReset, want the 1 seen", keepVisits, agent.States())
+		}
+
+		want := 0
+		if keepVisits {
+			want = 1
+		}
+		if n := agent.Visits(g.at("s"), edge{g, "a", "end"}); n != want {
+			t.Errorf("keepVisits %v: %d visits after SoftReset, want %d", keepVisits, n, want)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"a": "end"}}
+	a := edge{g, "a", "t"}
+	agent := NewSimpleAgent(0.5, 1)
+	agent.SetDefaultValue(2)
+	agent.Learn(g.step("t", "a"), fixedReward(1))
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	agent.Reset()
+	if agent.States() != 0 || agent.Steps() != 0 || agent.Visits(g.at("s"), a) != 0 {
+		t.Errorf("%d states, %d steps and %d visits after Reset, want none",
+			agent.States(), agent.Steps(), agent.Visits(g.at("s"), a))
+	}
+
+	// The learning rate, discount and default value are kept: s moves
+	// halfway from 2 to 1 plus the default value of t.
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+	if v := agent.Value(g.at("s"), a); v != 2.5 {
+		t.Errorf("Q(s) = %g learned after Reset, want 2.5", v)
+	}
+}
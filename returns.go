@@ -0,0 +1,171 @@
+package qlearning
+
+import "math"
+
+// DiscountedReturns returns the discounted return following each step of
+// an episode, given the reward received at every step. The return at
+// step i is rewards[i] + discount*rewards[i+1] + discount^2*rewards[i+2]
+// and so on to the end of the episode, accumulated backward from the
+// final step.
+func DiscountedReturns(rewards []float32, discount float32) []float32 {
+	returns := make([]float32, len(rewards))
+
+	acc := float32(0.0)
+	for i := len(rewards) - 1; i >= 0; i-- {
+		acc = rewards[i] + discount*acc
+		returns[i] = acc
+	}
+
+	return returns
+}
+
+// Discounter weights rewards by how far in the future they are received.
+// Discount returns the weight of a reward received step steps after the
+// current one, so Discount(0) is normally 1.
+type Discounter interface {
+	Discount(step int) float32
+}
+
+// Geometric is the standard exponential Discounter, weighting a reward
+// step steps ahead by gamma^step, where gamma is the value of Geometric.
+type Geometric float32
+
+// Discount returns gamma^step.
+func (gamma Geometric) Discount(step int) float32 {
+	return float32(math.Pow(float64(gamma), float64(step)))
+}
+
+// Hyperbolic is a Discounter weighting a reward step steps ahead by
+// 1/(1+k*step), where k is the value of Hyperbolic. It falls off quickly
+// at first and then much more slowly than Geometric.
+type Hyperbolic float32
+
+// Discount returns 1/(1+k*step).
+func (k Hyperbolic) Discount(step int) float32 {
+	return 1 / (1 + float32(k)*float32(step))
+}
+
+// DiscountedReturnsWith returns the return following each step of an
+// episode, given the reward received at every step, with future rewards
+// weighted by discounter. The return at step i is the sum over j >= i
+// of discounter.Discount(j-i)*rewards[j].
+//
+// Unlike DiscountedReturns, which relies on geometric discounting to
+// accumulate backward in a single pass, this takes time proportional to
+// the square of the episode length.
+func DiscountedReturnsWith(rewards []float32, discounter Discounter) []float32 {
+	returns := make([]float32, len(rewards))
+
+	for i := range rewards {
+		for j := i; j < len(rewards); j++ {
+			returns[i] += discounter.Discount(j-i) * rewards[j]
+		}
+	}
+
+	return returns
+}
+
+// Episode records the steps of an episode of a SimpleAgent and learns
+// from their discounted returns once the episode is over, for tasks
+// whose reward only comes at the end, such as winning or losing a game
+// of hangman. Learn bootstraps every update from the value of the next
+// state, so a final reward takes many episodes to travel back to the
+// first steps; Finish credits every step with the return that followed
+// it at once, as a Monte Carlo update:
+//
+//	ep := qlearning.StartEpisode(agent)
+//	for !game.Done() {
+//		action := qlearning.Next(agent, game)
+//		ep.Add(action, 0)
+//		action.Action.Apply(game)
+//	}
+//	ep.Finish(finalReward)
+//
+// Finish uses the agent's learning rate and current discount, or the
+// Discounter set with SetDiscounter. Add does not apply the action, and
+// the steps are not learned from with Learn, so the States may be
+// changed in place as they are played.
+type Episode struct {
+	agent      *SimpleAgent
+	discounter Discounter
+	steps      []episodeStep
+}
+
+// episodeStep is a step recorded by Episode.Add.
+type episodeStep struct {
+	sa            *StateAction
+	state, action string
+	reward        float32
+}
+
+// StartEpisode starts recording an Episode for agent.
+func StartEpisode(agent *SimpleAgent) *Episode {
+	return &Episode{agent: agent}
+}
+
+// SetDiscounter makes Finish weight future rewards with discounter, as
+// DiscountedReturnsWith does, in place of the geometric discounting of
+// the agent's discount. A nil discounter, the default, goes back to the
+// agent's discount.
+func (ep *Episode) SetDiscounter(discounter Discounter) {
+	ep.discounter = discounter
+}
+
+// Add records that sa was taken, with the reward it was given at once,
+// which is 0 for most steps of a task rewarded only at its end. The
+// string representations of its State and Action are taken now, before
+// the action is applied.
+func (ep *Episode) Add(sa *StateAction, reward float32) {
+	ep.steps = append(ep.steps, episodeStep{sa, sa.State.String(), sa.Action.String(), reward})
+}
+
+// Len returns the number of steps recorded.
+func (ep *Episode) Len() int {
+	return len(ep.steps)
+}
+
+// Finish adds finalReward to the reward of the last step, computes the
+// discounted return following every step as DiscountedReturns does, or
+// DiscountedReturnsWith with a Discounter set, and updates the Q-value of each, from the last step back, toward its
+// return at the agent's learning rate. It returns the returns, one per
+// step, and empties the episode for the next.
+//
+// Each update is made as by Learn for an action ending an episode whose
+// reward is the return, so it counts toward Steps and Visits, is
+// recorded in History and reported to OnLearn, and the return goes
+// through whatever processing rewards do, such as SetRewardClip; with
+// reward normalization on, the statistics are of returns rather than
+// rewards.
+func (ep *Episode) Finish(finalReward float32) []float32 {
+	if len(ep.steps) == 0 {
+		return nil
+	}
+
+	rewards := make([]float32, len(ep.steps))
+	for i, step := range ep.steps {
+		rewards[i] = step.reward
+	}
+	rewards[len(rewards)-1] += finalReward
+
+	var returns []float32
+	if ep.discounter != nil {
+		returns = DiscountedReturnsWith(rewards, ep.discounter)
+	} else {
+		returns = DiscountedReturns(rewards, ep.agent.discount())
+	}
+	for i := len(ep.steps) - 1; i >= 0; i-- {
+		step := ep.steps[i]
+		t := transition{
+			sa:     step.sa,
+			state:  step.state,
+			action: step.action,
+			meta:   step.sa.Meta,
+			ended:  true,
+		}
+		ep.agent.commit(ep.agent.planReward(t, returns[i]))
+	}
+
+	ep.steps = ep.steps[:0]
+
+	return returns
+}
@@ -0,0 +1,82 @@
+package qlearning
+
+import "testing"
+
+func TestDiscountedReturns(t *testing.T) {
+	got := DiscountedReturns([]float32{1, 0, 2}, 0.5)
+	want := []float32{1.5, 1, 2}
+
+	if len(got) != len(want) {
+		t.Fatalf("DiscountedReturns = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("return %d = %g, want %g", i, got[i], want[i])
+		}
+	}
+
+	if got := DiscountedReturns(nil, 0.5); len(got) != 0 {
+		t.Errorf("DiscountedReturns(nil) = %v, want none", got)
+	}
+}
+
+func TestDiscountedReturnsWith(t *testing.T) {
+	rewards := []float32{0, 0, 1}
+
+	tests := []struct {
+		name       string
+		discounter Discounter
+		want       []float32
+	}{
+		{"geometric", Geometric(0.5), []float32{0.25, 0.5, 1}},
+		{"hyperbolic", Hyperbolic(1), []float32{1.0 / 3, 0.5, 1}},
+	}
+	for _, test := range tests {
+		got := DiscountedReturnsWith(rewards, test.discounter)
+		for i := range test.want {
+			if !near(got[i], test.want[i], 1e-6) {
+				t.Errorf("%s: return %d = %g, want %g", test.name, i, got[i], test.want[i])
+			}
+		}
+	}
+
+	geometric := DiscountedReturns(rewards, 0.5)
+	for i, r := range DiscountedReturnsWith(rewards, Geometric(0.5)) {
+		if !near(r, geometric[i], 1e-6) {
+			t.Errorf("Geometric return %d = %g, DiscountedReturns %g", i, r, geometric[i])
+		}
+	}
+}
+
+func TestEpisodeFinish(t *testing.T) {
+	g := graph{"s0": {"a": "s1"}, "s1": {"a": "s2"}, "s2": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0.5)
+
+	ep := StartEpisode(agent)
+	for _, state := range []string{"s0", "s1", "s2"} {
+		ep.Add(g.step(state, "a"), 0)
+	}
+	ep.Finish(1)
+
+	for state, want := range map[string]float32{"s0": 0.25, "s1": 0.5, "s2": 1} {
+		if v := agent.Value(g.at(state), edge{g, "a", ""}); v != want {
+			t.Errorf("Q(%s) = %g, want %g", state, v, want)
+		}
+	}
+}
+
+func TestEpisodeDiscounter(t *testing.T) {
+	g := graph{"s0": {"a": "s1"}, "s1": {"a": "s2"}, "s2": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0.5)
+
+	ep := StartEpisode(agent)
+	ep.SetDiscounter(Hyperbolic(1))
+	for _, state := range []string{"s0", "s1", "s2"} {
+		ep.Add(g.step(state, "a"), 0)
+	}
+	returns := ep.Finish(1)
+
+	if v := agent.Value(g.at("s0"), edge{g, "a", "s1"}); !near(v, 1.0/3, 1e-6) || !near(returns[0], 1.0/3, 1e-6) {
+		t.Errorf("Q(s0) = %g, return %g; want the hyperbolic 1/3", v, returns[0])
+	}
+}
@@ -0,0 +1,286 @@
+package qlearning
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SetRewardNormalization enables or disables reward normalization.
+//
+// When enabled, the agent keeps a running mean and variance of every
+// reward it observes and standardizes each reward against them before
+// updating a Q-value. This keeps heavily skewed rewards, such as rare
+// large wins among frequent large penalties, on a comparable scale.
+// Until the variance is nonzero, as for the first reward, rewards are
+// only centered on the mean, not divided by the standard deviation.
+// Disabling normalization keeps the statistics gathered so far, without
+// adding to them, for when it is enabled again.
+func (agent *SimpleAgent) SetRewardNormalization(enabled bool) {
+	agent.normalize = enabled
+}
+
+// SetRewardClip clamps every reward to [min, max] before it is used in
+// an update, so that rare extreme rewards, such as a large penalty for
+// losing, cannot swamp the others. Unlike SetMaxUpdateDelta, this changes
+// what Q-values converge to, as a clipped reward is all the agent ever
+// learns of it. The raw rewards are still what ActionRewardStats
+// reports.
+//
+// Rewards are clipped before they are scaled or normalized.
+func (agent *SimpleAgent) SetRewardClip(min, max float32) {
+	agent.rewardClip = true
+	agent.rewardMin = min
+	agent.rewardMax = max
+}
+
+// SetRewardScaleByMaxAbs enables or disables scaling rewards by the
+// largest absolute reward observed so far, a simpler alternative to
+// SetRewardNormalization that assumes nothing about the distribution of
+// rewards. Each reward is divided by the running maximum, including
+// itself, so every reward used lies in [-1, 1], and a new extreme is
+// scaled to exactly 1 or -1 while shrinking the scale of the rewards
+// after it. The running maximum is saved with the agent like the other
+// reward statistics, and only grows while scaling is enabled.
+//
+// If both are enabled, rewards are scaled before they are normalized.
+func (agent *SimpleAgent) SetRewardScaleByMaxAbs(enabled bool) {
+	agent.scaleByMaxAbs = enabled
+}
+
+// RewardTiming is when a SimpleAgent asks its Rewarder for the reward
+// of an action, relative to applying the action.
+type RewardTiming int
+
+const (
+	// RewardAfterApply asks for the reward after the action is applied,
+	// so a State changed in place by Apply is rewarded as it is after
+	// the action. This is the default, and what the hangman example's
+	// Game expects.
+	RewardAfterApply RewardTiming = iota
+
+	// RewardBeforeApply asks for the reward before the action is
+	// applied, so a State changed in place by Apply is rewarded as it
+	// was when the action was chosen. If the action turns out not to be
+	// applicable, the reward has been asked for but no update is made.
+	RewardBeforeApply
+)
+
+// SetRewardTiming sets when the agent asks its Rewarder for the reward
+// of an action. It only matters for States that Apply changes in place,
+// whose Rewarder sees a different State before and after the action. A
+// NextRewarder is always asked after the action is applied, as it is
+// given the State the action led to.
+func (agent *SimpleAgent) SetRewardTiming(timing RewardTiming) {
+	agent.rewardTiming = timing
+}
+
+// processReward returns a reward as it should be used in an update,
+// after any configured clipping and normalization, along with the reward statistics
+// updated to include it. The agent itself is not changed.
+func (agent *SimpleAgent) processReward(r float32) (float32, runningStat) {
+	stats := agent.rewards
+
+	if agent.rewardClip {
+		r = clamp(r, agent.rewardMin, agent.rewardMax)
+	}
+
+	if agent.scaleByMaxAbs {
+		r = float32(stats.ScaleByMaxAbs(float64(r)))
+	}
+
+	if agent.normalize {
+		stats.Add(float64(r))
+		r = float32(stats.Standardize(float64(r)))
+	}
+
+	return r, stats
+}
+
+// rewardOf returns the raw reward given by rewarder for action, which led
+// to next. It uses RewardNext if rewarder implements NextRewarder, or
+// else Rewards reduced by reduce, or SumRewards if reduce is nil, if
+// rewarder implements MultiRewarder.
+func rewardOf(rewarder Rewarder, action *StateAction, next State, reduce RewardReducer) float32 {
+	if nr, ok := rewarder.(NextRewarder); ok {
+		return nr.RewardNext(action, next)
+	}
+
+	if mr, ok := rewarder.(MultiRewarder); ok {
+		if reduce == nil {
+			reduce = SumRewards
+		}
+		return reduce(mr.Rewards(action))
+	}
+
+	return rewarder.Reward(action)
+}
+
+// MultiRewarder is an optional interface for Rewarders whose actions
+// naturally yield several rewards at once, such as one per letter
+// revealed by a hangman guess. When a Rewarder implements it, Rewards is
+// called instead of Reward and the rewards are reduced to one, by
+// summing them unless the agent is configured otherwise. NextRewarder
+// takes precedence over MultiRewarder.
+type MultiRewarder interface {
+	Rewards(sa *StateAction) []float32
+}
+
+// RewardReducer reduces the rewards of a MultiRewarder to one.
+type RewardReducer func(rewards []float32) float32
+
+// SumRewards is a RewardReducer returning the sum of rewards.
+func SumRewards(rewards []float32) float32 {
+	sum := float32(0.0)
+	for _, r := range rewards {
+		sum += r
+	}
+
+	return sum
+}
+
+// MeanRewards is a RewardReducer returning the mean of rewards, or 0 if
+// there are none.
+func MeanRewards(rewards []float32) float32 {
+	if len(rewards) == 0 {
+		return 0
+	}
+
+	return SumRewards(rewards) / float32(len(rewards))
+}
+
+// MaxRewards is a RewardReducer returning the largest of rewards, or 0
+// if there are none.
+func MaxRewards(rewards []float32) float32 {
+	if len(rewards) == 0 {
+		return 0
+	}
+
+	max := rewards[0]
+	for _, r := range rewards[1:] {
+		if r > max {
+			max = r
+		}
+	}
+
+	return max
+}
+
+// SetRewardReducer sets how the agent reduces the rewards of a
+// MultiRewarder to one. The default, or nil, is SumRewards.
+func (agent *SimpleAgent) SetRewardReducer(reduce RewardReducer) {
+	agent.reduce = reduce
+}
+
+// runningStat tracks the mean and variance of a stream of values using
+// Welford's online algorithm.
+//
+// MaxAbs is the largest absolute reward seen while scaling by it. It is
+// saved from version 2 of the snapshot on, and upgraded from earlier
+// versions as 0, meaning no reward seen yet.
+type runningStat struct {
+	N    int64
+	Mean float64
+	M2   float64
+
+	MaxAbs float64
+}
+
+// Add records a new value.
+func (s *runningStat) Add(x float64) {
+	s.N++
+	delta := x - s.Mean
+	s.Mean += delta / float64(s.N)
+	s.M2 += delta * (x - s.Mean)
+}
+
+// Variance returns the population variance of the values recorded so
+// far, or 0 if fewer than two values have been recorded.
+func (s *runningStat) Variance() float64 {
+	if s.N < 2 {
+		return 0
+	}
+
+	return s.M2 / float64(s.N)
+}
+
+// Standardize returns x as a number of standard deviations from the
+// mean. While the variance is still 0, such as after the first value,
+// x is only centered.
+func (s *runningStat) Standardize(x float64) float64 {
+	std := math.Sqrt(s.Variance())
+	if std == 0 {
+		return x - s.Mean
+	}
+
+	return (x - s.Mean) / std
+}
+
+// ScaleByMaxAbs records the absolute value of x in MaxAbs and returns x
+// divided by MaxAbs, or x itself while MaxAbs is 0.
+func (s *runningStat) ScaleByMaxAbs(x float64) float64 {
+	s.MaxAbs = math.Max(s.MaxAbs, math.Abs(x))
+	if s.MaxAbs == 0 {
+		return x
+	}
+
+	return x / s.MaxAbs
+}
+
+// RewardStat summarizes the rewards observed for an action.
+type RewardStat struct {
+	Count int
+	Mean  float32
+}
+
+// RewardStats maps the string representation of each action to a
+// summary of the rewards observed for it.
+type RewardStats map[string]RewardStat
+
+// String returns a report of the stats with one line per action, sorted
+// by action.
+func (stats RewardStats) String() string {
+	actions := make([]string, 0, len(stats))
+	for action := range stats {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	var b strings.Builder
+	for _, action := range actions {
+		fmt.Fprintf(&b, "%s: mean %g over %d\n", action, stats[action].Mean, stats[action].Count)
+	}
+
+	return b.String()
+}
+
+// ActionRewardStats returns the mean reward observed for each action
+// across every state, keyed by the string representation of the action.
+// These are the raw rewards given by the Rewarder, before any
+// normalization, and are unrelated to the Q-values learned. The result
+// is a copy that later updates do not change.
+func (agent *SimpleAgent) ActionRewardStats() RewardStats {
+	stats := make(RewardStats, len(agent.actionRewards))
+	for action, stat := range agent.actionRewards {
+		stats[action] = *stat
+	}
+
+	return stats
+}
+
+// recordActionReward adds a reward observed for action to its stats.
+func (agent *SimpleAgent) recordActionReward(action string, r float32) {
+	if agent.actionRewards == nil {
+		agent.actionRewards = make(map[string]*RewardStat)
+	}
+
+	stat, ok := agent.actionRewards[action]
+	if !ok {
+		stat = &RewardStat{}
+		agent.actionRewards[action] = stat
+	}
+
+	stat.Count++
+	stat.Mean += (r - stat.Mean) / float32(stat.Count)
+}
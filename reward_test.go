@@ -0,0 +1,214 @@
+package qlearning
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRunningStat(t *testing.T) {
+	var s runningStat
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.Add(x)
+	}
+
+	if s.N != 8 || s.Mean != 5 || s.Variance() != 4 {
+		t.Fatalf("got n %d, mean %g, variance %g; want 8, 5, 4", s.N, s.Mean, s.Variance())
+	}
+	if got := s.Standardize(9); got != 2 {
+		t.Errorf("Standardize(9) = %g, want 2", got)
+	}
+}
+
+func TestRunningStatFirstValue(t *testing.T) {
+	var s runningStat
+	s.Add(10)
+
+	if s.Variance() != 0 {
+		t.Errorf("variance of one value = %g, want 0", s.Variance())
+	}
+	if got := s.Standardize(10); got != 0 {
+		t.Errorf("Standardize of the only value = %g, want 0", got)
+	}
+}
+
+func TestRewardNormalization(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetRewardNormalization(true)
+
+	// The first reward is only centered, and the second is one standard
+	// deviation, 5, above the mean of both, 15.
+	agent.Learn(g.step("s", "a"), fixedReward(10))
+	if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != 0 {
+		t.Errorf("Q after first reward = %g, want 0", v)
+	}
+
+	agent.Learn(g.step("s", "a"), fixedReward(20))
+	if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != 1 {
+		t.Errorf("Q after second reward = %g, want 1", v)
+	}
+}
+
+func TestRewardNormalizationSaved(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetRewardNormalization(true)
+	for _, r := range []float32{-1000, 5, -1000, 24} {
+		agent.Learn(g.step("s", "a"), fixedReward(r))
+	}
+
+	var buf bytes.Buffer
+	if err := agent.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewSimpleAgent(1, 0)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.rewards != agent.rewards {
+		t.Errorf("loaded reward statistics %+v, want %+v", loaded.rewards, agent.rewards)
+	}
+}
+
+func TestRewardTiming(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	// arrived rewards a walk for being at the end, which it only is
+	// after the action is applied.
+	arrived := rewardFunc(func(sa *StateAction) float32 {
+		if sa.State.(*walk).at == "end" {
+			return 1
+		}
+		return 0
+	})
+
+	for _, tc := range []struct {
+		timing RewardTiming
+		want   float32
+	}{
+		{RewardAfterApply, 1},
+		{RewardBeforeApply, 0},
+	} {
+		agent := NewSimpleAgent(1, 0)
+		agent.SetRewardTiming(tc.timing)
+		w := &walk{g: g, at: "s"}
+		agent.Learn(NewStateAction(w, hop{"a", "end"}, 0), arrived)
+
+		if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != tc.want {
+			t.Errorf("timing %d: Q = %g, want %g", tc.timing, v, tc.want)
+		}
+	}
+}
+
+func TestRewardClip(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(g.step("s", "b"), fixedReward(-1000))
+	if v := agent.Value(g.at("s"), edge{g, "b", "end"}); v != -1000 {
+		t.Errorf("Q = %g without a clip, want the reward of -1000", v)
+	}
+
+	agent.SetRewardClip(-1, 1)
+	for _, tc := range []struct{ reward, want float32 }{
+		{-1000, -1},
+		{24, 1},
+		{0.5, 0.5},
+	} {
+		agent.Learn(g.step("s", "a"), fixedReward(tc.reward))
+		if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != tc.want {
+			t.Errorf("Q = %g after a reward of %g clipped to [-1, 1], want %g", v, tc.reward, tc.want)
+		}
+	}
+}
+
+func TestRewardNormalizationStabilizes(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	a := edge{g, "a", "end"}
+	// settle counts the updates, up to 1000, before ten in a row each
+	// move the Q-value by less than 1, on rewards alternating between 990
+	// and 1010.
+	settle := func(normalize bool) int {
+		agent := NewSimpleAgent(0.1, 0)
+		agent.SetRewardNormalization(normalize)
+		n, still := 0, 0
+		for ; n < 1000 && still < 10; n++ {
+			r := float32(990)
+			if n%2 == 0 {
+				r = 1010
+			}
+			before := agent.Value(g.at("s"), a)
+			agent.Learn(g.step("s", "a"), fixedReward(r))
+			if d := agent.Value(g.at("s"), a) - before; d > -1 && d < 1 {
+				still++
+			} else {
+				still = 0
+			}
+		}
+		return n
+	}
+
+	raw, normalized := settle(false), settle(true)
+	if normalized >= raw {
+		t.Errorf("settled after %d updates normalized, want fewer than the %d raw", normalized, raw)
+	}
+}
+
+func TestRewardNormalizationToggle(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetRewardNormalization(true)
+	agent.Learn(g.step("s", "a"), fixedReward(10))
+	agent.Learn(g.step("s", "a"), fixedReward(20))
+
+	agent.SetRewardNormalization(false)
+	agent.Learn(g.step("s", "a"), fixedReward(1000))
+	if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != 1000 {
+		t.Errorf("Q = %g with normalization disabled, want the raw reward of 1000", v)
+	}
+	if agent.rewards.N != 2 {
+		t.Errorf("%d rewards in the statistics, want the 2 seen while enabled", agent.rewards.N)
+	}
+
+	// Re-enabled, the statistics pick up where they left off, so 15 is
+	// the mean of 10, 20 and itself, not a first reward.
+	agent.SetRewardNormalization(true)
+	agent.Learn(g.step("s", "a"), fixedReward(15))
+	if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != 0 {
+		t.Errorf("Q = %g after re-enabling, want 0 for the mean reward", v)
+	}
+	if agent.rewards.N != 3 {
+		t.Errorf("%d rewards in the statistics, want 3", agent.rewards.N)
+	}
+}
+
+func TestRewardScaleByMaxAbs(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetRewardScaleByMaxAbs(true)
+
+	// Each new extreme is scaled to 1 or -1 and becomes the scale of the
+	// rewards after it.
+	for _, tc := range []struct {
+		reward, scaled float32
+		maxAbs         float64
+	}{
+		{2, 1, 2},
+		{-10, -1, 10},
+		{5, 0.5, 10},
+	} {
+		agent.Learn(g.step("s", "a"), fixedReward(tc.reward))
+		if v := agent.Value(g.at("s"), edge{g, "a", "end"}); v != tc.scaled {
+			t.Errorf("reward %g scaled to %g, want %g", tc.reward, v, tc.scaled)
+		}
+		if m := agent.rewards.MaxAbs; m != tc.maxAbs {
+			t.Errorf("MaxAbs = %g after a reward of %g, want %g", m, tc.reward, tc.maxAbs)
+		}
+	}
+
+	// Disabled, rewards are used as they are, and the maximum is kept.
+	agent.SetRewardScaleByMaxAbs(false)
+	agent.Learn(g.step("s", "a"), fixedReward(20))
+	if v, m := agent.Value(g.at("s"), edge{g, "a", "end"}), agent.rewards.MaxAbs; v != 20 || m != 10 {
+		t.Errorf("Q = %g and MaxAbs = %g with scaling disabled, want 20 and 10", v, m)
+	}
+}
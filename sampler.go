@@ -0,0 +1,51 @@
+package qlearning
+
+import "math/rand"
+
+// Sampler draws indexes into a collection of n items, such as a list of
+// words or initial states, from its own source seeded by the caller, so
+// that the order of episodes in a training run can be reproduced.
+type Sampler struct {
+	rng         *rand.Rand
+	n           int
+	replacement bool
+
+	perm []int
+	next int
+}
+
+// NewSampler creates a Sampler drawing indexes in [0, n) from a source
+// seeded with seed. With replacement, every draw is independent and
+// uniform. Without replacement, every index is drawn once, in a random
+// order, before any index is drawn again. Two Samplers created with the
+// same arguments draw the same indexes.
+//
+// NewSampler panics if n is not positive.
+func NewSampler(n int, seed int64, replacement bool) *Sampler {
+	if n <= 0 {
+		panic("qlearning: NewSampler needs at least one item")
+	}
+
+	return &Sampler{
+		rng:         rand.New(rand.NewSource(seed)),
+		n:           n,
+		replacement: replacement,
+	}
+}
+
+// Next returns the next index.
+func (s *Sampler) Next() int {
+	if s.replacement {
+		return s.rng.Intn(s.n)
+	}
+
+	if s.next >= len(s.perm) {
+		s.perm = s.rng.Perm(s.n)
+		s.next = 0
+	}
+
+	i := s.perm[s.next]
+	s.next++
+
+	return i
+}
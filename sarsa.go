@@ -0,0 +1,125 @@
+package qlearning
+
+import "fmt"
+
+// SarsaAgent is an Agent learning on-policy with SARSA. Where SimpleAgent
+// updates a Q-value toward the best value of the next state, whatever
+// the agent goes on to do there, SarsaAgent updates it toward the value
+// of the action it actually takes next, exploration included:
+//
+//	Q(s, a) += lr * (r + d*Q(s', a') - Q(s, a))
+//
+// So SimpleAgent learns the values of the greedy policy while exploring,
+// and SarsaAgent those of the epsilon-greedy policy it follows, which
+// are lower wherever exploring is costly. With an epsilon of 0 the two
+// learn the same values.
+//
+// Learn chooses the next action as soon as it reaches the next state,
+// to update toward it, and Select returns that same action when next
+// asked for that state, so that the action learned from is the action
+// taken. Update makes the same update for a transition the caller has
+// already chosen the next action of.
+type SarsaAgent struct {
+	randSource
+
+	q       map[string]map[string]float32
+	lr      float32
+	d       float32
+	epsilon float32
+
+	// next is the action chosen by the last Learn for the state it led
+	// to, for Select to return.
+	next *StateAction
+}
+
+// NewSarsaAgent creates a SarsaAgent with the provided learning rate and
+// discount factor, exploring uniformly at random with probability
+// epsilon.
+func NewSarsaAgent(lr, d, epsilon float32) *SarsaAgent {
+	return &SarsaAgent{
+		q:       make(map[string]map[string]float32),
+		lr:      lr,
+		d:       d,
+		epsilon: epsilon,
+	}
+}
+
+// Learn applies the action, chooses the action to take next in the
+// state it led to, and updates the Q-value of action toward its reward
+// and the value of that next action. If the action ends the episode, as
+// SimpleAgent.Learn describes, or the next state has no actions, the
+// update is toward the reward alone.
+func (agent *SarsaAgent) Learn(action *StateAction, reward Rewarder) {
+	s, a := action.State.String(), action.Action.String()
+
+	nextState, err := applyAction(action.Action, action.State)
+	if err != nil {
+		return
+	}
+
+	r := rewardOf(reward, action, nextState, nil)
+
+	var next *StateAction
+	if !ended(action, nextState) {
+		next = agent.choose(nextState)
+	}
+
+	agent.update(s, a, r, next)
+	agent.next = next
+}
+
+// Update moves the Q-value of action toward reward plus the discounted
+// value of next, the action taken after it, without applying either.
+// A nil next marks the end of an episode, and the update is toward
+// reward alone.
+func (agent *SarsaAgent) Update(action *StateAction, reward float32, next *StateAction) {
+	agent.update(action.State.String(), action.Action.String(), reward, next)
+}
+
+// update is Update for the state and action whose string
+// representations are s and a, which Learn captures before applying the
+// action, in case it changes the State in place.
+func (agent *SarsaAgent) update(s, a string, reward float32, next *StateAction) {
+	target := reward
+	if next != nil {
+		target += agent.d * agent.Value(next.State, next.Action)
+	}
+
+	if _, ok := agent.q[s]; !ok {
+		agent.q[s] = make(map[string]float32)
+	}
+
+	old := agent.q[s][a]
+	agent.q[s][a] = old + agent.lr*(target-old)
+}
+
+// Value returns the Q-value of state and action, or 0 if it has not been
+// learned.
+func (agent *SarsaAgent) Value(state State, action Action) float32 {
+	return agent.q[state.String()][action.String()]
+}
+
+// Select implements Selector. If the last Learn chose the next action
+// for state, it returns that action; otherwise it chooses
+// epsilon-greedily, breaking ties at random.
+func (agent *SarsaAgent) Select(state State) *StateAction {
+	next := agent.next
+	agent.next = nil
+
+	if next != nil && next.State.String() == state.String() {
+		return NewStateAction(state, next.Action, agent.Value(state, next.Action))
+	}
+
+	return agent.choose(state)
+}
+
+// choose returns an action of state chosen epsilon-greedily, or nil if
+// it has no actions.
+func (agent *SarsaAgent) choose(state State) *StateAction {
+	return epsilonGreedy(agent, state, agent.epsilon)
+}
+
+// String returns the name of the agent and its exploration rate.
+func (agent *SarsaAgent) String() string {
+	return fmt.Sprintf("SarsaAgent(epsilon %g)", agent.epsilon)
+}
@@ -0,0 +1,78 @@
+package qlearning
+
+import "testing"
+
+// detour is a deterministic problem where a pays off only if it is
+// followed by a good action: from s, a leads to t, where a pays 1 and b
+// nothing, and b ends the episode at once with 0.5.
+var detour = graph{"s": {"a": "t", "b": "end"}, "t": {"a": "end", "b": "end"}}
+
+// detourReward rewards a in t with 1, and b in s with 0.5.
+var detourReward = rewardFunc(func(sa *StateAction) float32 {
+	switch sa.State.String() + " " + sa.Action.String() {
+	case "t a":
+		return 1
+	case "s b":
+		return 0.5
+	}
+	return 0
+})
+
+// playDetour plays n episodes of detour with agent.
+func playDetour(agent Agent, n int) {
+	for i := 0; i < n; i++ {
+		state := State(detour.at("s"))
+		for state.String() != "end" {
+			sa := Next(agent, state)
+			agent.Learn(sa, detourReward)
+			state = sa.Action.Apply(state)
+		}
+	}
+}
+
+func TestSarsaAndQLearningValues(t *testing.T) {
+	sarsa := NewSarsaAgent(0.01, 0.9, 0.3)
+	sarsa.SetSeed(1)
+	playDetour(sarsa, 20000)
+
+	simple := NewSimpleAgent(0.01, 0.9)
+	simple.SetSeed(1)
+	simple.SetExplorationSchedule(Constant(0.3))
+	playDetour(simple, 20000)
+
+	// In t, the greedy a is taken with probability 0.7 + 0.3/2, so SARSA
+	// values going there at 0.9 * 0.85, and Q-learning at 0.9 * 1.
+	for _, tc := range []struct {
+		agent          Agent
+		state, action  string
+		want, accuracy float32
+	}{
+		{sarsa, "t", "a", 1, 0.01},
+		{sarsa, "s", "a", 0.765, 0.06},
+		{sarsa, "s", "b", 0.5, 0.01},
+		{simple, "t", "a", 1, 0.01},
+		{simple, "s", "a", 0.9, 0.01},
+		{simple, "s", "b", 0.5, 0.01},
+	} {
+		v := tc.agent.Value(detour.at(tc.state), edge{detour, tc.action, detour[tc.state][tc.action]})
+		if !near(v, tc.want, tc.accuracy) {
+			t.Errorf("%s: Q(%s, %s) = %g, want %g", tc.agent, tc.state, tc.action, v, tc.want)
+		}
+	}
+}
+
+func TestSarsaUpdate(t *testing.T) {
+	agent := NewSarsaAgent(1, 0.5, 0)
+	agent.Learn(detour.step("t", "a"), detourReward)
+
+	// The update is toward the value of the next action given, b, not of
+	// the best one, a.
+	agent.Update(detour.step("s", "a"), 0.25, detour.step("t", "b"))
+	if v := agent.Value(detour.at("s"), edge{detour, "a", "t"}); v != 0.25 {
+		t.Errorf("Q(s, a) = %g toward t, b, want 0.25", v)
+	}
+	agent.Update(detour.step("s", "a"), 0.25, detour.step("t", "a"))
+	if v := agent.Value(detour.at("s"), edge{detour, "a", "t"}); v != 0.75 {
+		t.Errorf("Q(s, a) = %g toward t, a, want 0.75", v)
+	}
+}
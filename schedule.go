@@ -0,0 +1,76 @@
+package qlearning
+
+import "math"
+
+// EpsilonSchedule gives the exploration rate of an agent as training
+// progresses: the probability, in [0, 1], that the agent chooses an
+// action uniformly at random instead of the best one. step is the number
+// of updates the agent has made so far.
+//
+// Schedules beyond the ones in this package need only implement
+// Epsilon.
+type EpsilonSchedule interface {
+	Epsilon(step int64) float32
+}
+
+// Constant is an EpsilonSchedule that always explores at the same rate.
+type Constant float32
+
+// Epsilon returns c.
+func (c Constant) Epsilon(step int64) float32 {
+	return float32(c)
+}
+
+// ExponentialDecay is an EpsilonSchedule starting at Start and
+// multiplied by Rate, which should be in (0, 1), at every step, but
+// never falling below Min.
+type ExponentialDecay struct {
+	Start float32
+	Min   float32
+	Rate  float32
+}
+
+// Epsilon returns Start * Rate^step, or Min if that is lower.
+func (e ExponentialDecay) Epsilon(step int64) float32 {
+	eps := e.Start * float32(math.Pow(float64(e.Rate), float64(step)))
+	if eps < e.Min {
+		return e.Min
+	}
+
+	return eps
+}
+
+// LinearDecay is an EpsilonSchedule moving in a straight line from Start
+// to End over Steps steps, and staying at End after.
+type LinearDecay struct {
+	Start float32
+	End   float32
+	Steps int64
+}
+
+// Epsilon returns the point step of the way from Start to End.
+func (l LinearDecay) Epsilon(step int64) float32 {
+	if step >= l.Steps {
+		return l.End
+	}
+
+	return l.Start + (l.End-l.Start)*float32(step)/float32(l.Steps)
+}
+
+// Cyclic is an EpsilonSchedule that restarts Schedule every Period steps,
+// so that exploration rises again periodically, for example when the
+// environment is expected to change. A Period below 1 never restarts.
+type Cyclic struct {
+	Schedule EpsilonSchedule
+	Period   int64
+}
+
+// Epsilon returns the epsilon of Schedule at step into the current
+// period.
+func (c Cyclic) Epsilon(step int64) float32 {
+	if c.Period < 1 {
+		return c.Schedule.Epsilon(step)
+	}
+
+	return c.Schedule.Epsilon(step % c.Period)
+}
@@ -0,0 +1,410 @@
+package qlearning
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// SelectGreedy returns the action in actions with the highest value in
+// values, which is keyed by the string representation of each action.
+// Actions missing from values are worth 0. Ties are broken uniformly at
+// random using rng, or the math/rand global source if rng is nil.
+// SelectGreedy returns nil if actions is empty.
+//
+// SelectGreedy depends on nothing but its arguments, so given an rng
+// with a fixed seed it always makes the same choices.
+func SelectGreedy(values map[string]float32, actions []Action, rng *rand.Rand) Action {
+	best := scoreBest(eachOf(actions), valueIn(values), 0)
+	if len(best) == 0 {
+		return nil
+	}
+
+	return best[randIntn(rng, len(best))].action
+}
+
+// SelectEpsilonGreedy returns a uniformly random action from actions with
+// probability epsilon, and otherwise the action SelectGreedy returns.
+// Random draws are made from rng, or the math/rand global source if rng
+// is nil. SelectEpsilonGreedy returns nil if actions is empty.
+func SelectEpsilonGreedy(values map[string]float32, actions []Action, epsilon float32, rng *rand.Rand) Action {
+	if len(actions) == 0 {
+		return nil
+	}
+
+	if randFloat32(rng) < epsilon {
+		sorted := append([]Action(nil), actions...)
+		sortActions(sorted)
+		return sorted[randIntn(rng, len(sorted))]
+	}
+
+	return SelectGreedy(values, actions, rng)
+}
+
+// BestActions returns every Action of state sharing the highest Q-value
+// of agent, sorted by their string representations, so that callers can
+// apply their own tie-breaking. If agent has a tie epsilon, such as one set
+// with SimpleAgent.SetTieEpsilon, actions within it of the highest value
+// are included. BestActions returns an empty slice if state has no
+// actions.
+func BestActions(agent Agent, state State) []Action {
+	eps := float32(0.0)
+	if a, ok := agent.(interface{ TieEpsilon() float32 }); ok {
+		eps = a.TieEpsilon()
+	}
+
+	best := bestActions(agent, state, eps)
+
+	actions := make([]Action, len(best))
+	for i, sa := range best {
+		actions[i] = sa.Action
+	}
+
+	return actions
+}
+
+// Best returns the Action of state with the highest Q-value of agent,
+// without exploring and without randomness: ties go to the Action whose
+// string representation sorts first. It is the choice of a frozen
+// greedy policy, for evaluating one reproducibly, where Next may explore
+// or break ties at random. Best returns nil if state has no actions.
+func Best(agent Agent, state State) *StateAction {
+	best := bestActions(agent, state, 0)
+	if len(best) == 0 {
+		return nil
+	}
+
+	return best[0]
+}
+
+// SampleAction returns an Action of state drawn at random from the
+// policy of agent, for evaluating a stochastic policy as it is, rather
+// than the greedy one. If agent reports the probability of each Action,
+// through an ActionProbabilities method like SimpleAgent's, the Action is
+// drawn with that probability; otherwise it is drawn uniformly among the
+// Actions with the highest Q-value. SampleAction returns nil if state has
+// no actions.
+//
+// Unlike Next, SampleAction never calls Select, so it does not change
+// agent, such as by seeding Q-values with SetInitializer.
+func SampleAction(agent Agent, state State) *StateAction {
+	a, ok := agent.(interface {
+		ActionProbabilities(State) map[string]float32
+	})
+	if !ok {
+		best := bestActions(agent, state, 0)
+		if len(best) == 0 {
+			return nil
+		}
+		return best[randIntn(agentRand(agent), len(best))]
+	}
+
+	probabilities := a.ActionProbabilities(state)
+
+	var actions []Action
+	eachAction(state, func(action Action) bool {
+		if probabilities[action.String()] > 0 {
+			actions = append(actions, action)
+		}
+		return true
+	})
+	if len(actions) == 0 {
+		return nil
+	}
+	sortActions(actions)
+
+	// The last action takes whatever rounding leaves of the draw.
+	r := randFloat32(agentRand(agent))
+	action := actions[len(actions)-1]
+	for _, candidate := range actions {
+		r -= probabilities[candidate.String()]
+		if r < 0 {
+			action = candidate
+			break
+		}
+	}
+
+	return chosen(agent, state, action, agent.Value(state, action))
+}
+
+// Policy chooses an action of a State for an Agent. Where a Selector is
+// an Agent choosing its own actions, a Policy is kept apart from the
+// agent, so that the same agent can be run with different policies, for
+// instance exploring while it is trained and greedy once it is
+// evaluated, and the policy swapped at any time. Next, NextEpsilon,
+// NextSoftmax, and NextUCB each have a Policy, and PolicyFunc makes any
+// other function one.
+type Policy interface {
+	// Select returns the chosen StateAction for state, or nil if state
+	// has no actions.
+	Select(agent Agent, state State) *StateAction
+}
+
+// PolicyFunc is a function used as a Policy.
+type PolicyFunc func(agent Agent, state State) *StateAction
+
+// Select returns f(agent, state).
+func (f PolicyFunc) Select(agent Agent, state State) *StateAction {
+	return f(agent, state)
+}
+
+// GreedyPolicy always chooses an action with the highest Q-value, with
+// ties broken at random, as NextEpsilon with an epsilon of 0. Unlike
+// Next, it ignores any Select method of the agent.
+type GreedyPolicy struct{}
+
+// Select implements Policy.
+func (GreedyPolicy) Select(agent Agent, state State) *StateAction {
+	return NextEpsilon(agent, state, 0)
+}
+
+// EpsilonGreedyPolicy chooses an action at random with probability
+// Epsilon, and otherwise greedily, as NextEpsilon.
+type EpsilonGreedyPolicy struct {
+	Epsilon float32
+}
+
+// Select implements Policy.
+func (p EpsilonGreedyPolicy) Select(agent Agent, state State) *StateAction {
+	return NextEpsilon(agent, state, p.Epsilon)
+}
+
+// RandomPolicy chooses uniformly at random among every action, whatever
+// their Q-values, as NextEpsilon with an epsilon of 1. The StateAction
+// chosen still carries the agent's Q-value.
+type RandomPolicy struct{}
+
+// Select implements Policy.
+func (RandomPolicy) Select(agent Agent, state State) *StateAction {
+	return NextEpsilon(agent, state, 1)
+}
+
+// SoftmaxPolicy chooses with Boltzmann exploration at Temperature, as
+// NextSoftmax.
+type SoftmaxPolicy struct {
+	Temperature float32
+}
+
+// Select implements Policy.
+func (p SoftmaxPolicy) Select(agent Agent, state State) *StateAction {
+	return NextSoftmax(agent, state, p.Temperature)
+}
+
+// WeightedPolicy chooses at random with higher Q-values more likely, as
+// NextWeighted.
+type WeightedPolicy struct{}
+
+// Select implements Policy.
+func (WeightedPolicy) Select(agent Agent, state State) *StateAction {
+	return NextWeighted(agent, state)
+}
+
+// UCBPolicy chooses by upper confidence bound with exploration constant
+// C, as NextUCB.
+type UCBPolicy struct {
+	C float32
+}
+
+// Select implements Policy.
+func (p UCBPolicy) Select(agent Agent, state State) *StateAction {
+	return NextUCB(agent, state, p.C)
+}
+
+// epsilonGreedy returns an Action of state chosen uniformly at random
+// with probability epsilon, and otherwise one with the highest Q-value
+// of agent, breaking ties at random, or nil if state has no actions. It
+// is the selection of the agents that explore at a fixed rate, and draws
+// from the source of randomness of agent, if it has one.
+func epsilonGreedy(agent Agent, state State, epsilon float32) *StateAction {
+	var actions []Action
+	eachAction(state, func(action Action) bool {
+		actions = append(actions, action)
+		return true
+	})
+	if len(actions) == 0 {
+		return nil
+	}
+	sortActions(actions)
+
+	rng := agentRand(agent)
+
+	var action Action
+	if randFloat32(rng) < epsilon {
+		action = actions[randIntn(rng, len(actions))]
+	} else {
+		score := func(action Action) float32 {
+			return agent.Value(state, action)
+		}
+		best := scoreBest(eachOf(actions), score, 0)
+		action = best[randIntn(rng, len(best))].action
+	}
+
+	return chosen(agent, state, action, agent.Value(state, action))
+}
+
+// chosen returns a StateAction for action of state with the given value,
+// marked Unknown if agent counts its updates and has never learned from
+// action in state.
+func chosen(agent Agent, state State, action Action, value float32) *StateAction {
+	sa := NewStateAction(state, action, value)
+	if counter, ok := agent.(interface{ Visits(State, Action) int }); ok {
+		sa.Unknown = counter.Visits(state, action) == 0
+	}
+
+	return sa
+}
+
+// bestActions returns a StateAction for every Action of state whose
+// Q-value is within eps of the highest Q-value.
+func bestActions(agent Agent, state State, eps float32) []*StateAction {
+	value := func(action Action) float32 {
+		return agent.Value(state, action)
+	}
+
+	best := scoreBest(func(fn func(Action) bool) { eachAction(state, fn) }, value, eps)
+
+	sas := make([]*StateAction, len(best))
+	for i, b := range best {
+		sas[i] = chosen(agent, state, b.action, b.value)
+	}
+
+	return sas
+}
+
+// scored is an action with its value.
+type scored struct {
+	action Action
+	value  float32
+}
+
+// scoreBest returns every action enumerated by each whose value is within
+// eps of the highest value, sorted by their string representations. It is the
+// greedy selection shared by SelectGreedy and the agents.
+//
+// Only the actions within eps of the highest value seen so far are kept
+// as each enumerates them, so that a State streaming many actions
+// through NextIter is never held in memory all at once.
+func scoreBest(each func(func(Action) bool), value func(Action) float32, eps float32) []scored {
+	best := make([]scored, 0)
+	bestVal := float32(0.0)
+
+	each(func(action Action) bool {
+		val := value(action)
+
+		// NaN values are kept only while no action has a number.
+		if len(best) > 0 && (val < bestVal-eps || val != val) {
+			return true
+		}
+
+		if len(best) == 0 || val > bestVal || bestVal != bestVal {
+			bestVal = val
+
+			kept := best[:0]
+			for _, candidate := range best {
+				if candidate.value >= bestVal-eps {
+					kept = append(kept, candidate)
+				}
+			}
+			best = kept
+		}
+		best = append(best, scored{action, val})
+
+		return true
+	})
+
+	sort.SliceStable(best, func(i, j int) bool {
+		return best[i].action.String() < best[j].action.String()
+	})
+
+	return best
+}
+
+// sortActions sorts actions by their string representations, so that a
+// choice among them does not depend on the order a State offers them.
+func sortActions(actions []Action) {
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].String() < actions[j].String()
+	})
+}
+
+// eachOf returns an enumeration of actions for scoreBest.
+func eachOf(actions []Action) func(func(Action) bool) {
+	return func(fn func(Action) bool) {
+		for _, action := range actions {
+			if !fn(action) {
+				return
+			}
+		}
+	}
+}
+
+// valueIn returns a function looking up the value of an action in
+// values, for scoreBest.
+func valueIn(values map[string]float32) func(Action) float32 {
+	return func(action Action) float32 {
+		return values[action.String()]
+	}
+}
+
+// agentRand returns the source of randomness of agent, if it has one,
+// such as one set with SimpleAgent.SetRand, or else nil, for the math/rand
+// global source.
+func agentRand(agent Agent) *rand.Rand {
+	if a, ok := agent.(interface{ Rand() *rand.Rand }); ok {
+		return a.Rand()
+	}
+
+	return nil
+}
+
+// randSource is a source of randomness that can be seeded, as
+// SimpleAgent's can. The other agents embed it for SetSeed, SetRand and
+// Rand, and draw every random decision of theirs from its rng.
+type randSource struct {
+	rng *rand.Rand
+}
+
+// SetSeed makes the agent draw from its own source of randomness, seeded
+// with seed, for every random decision it makes: whether to explore,
+// which action to explore, and how to break ties. Two agents with the
+// same seed and configuration, trained on the same sequence of states,
+// make the same choices and learn the same values. The source is not
+// saved, and without SetSeed the math/rand global source is used.
+func (r *randSource) SetSeed(seed int64) {
+	r.rng = rand.New(rand.NewSource(seed))
+}
+
+// SetRand is SetSeed with a source of randomness the caller provides,
+// which the agent then owns: as a *rand.Rand is not safe for concurrent
+// use, it must not be used elsewhere while the agent is. A nil rng goes
+// back to the math/rand global source.
+func (r *randSource) SetRand(rng *rand.Rand) {
+	r.rng = rng
+}
+
+// Rand returns the source of randomness set with SetSeed or SetRand, or
+// nil if the agent uses the math/rand global source. NextEpsilon and the
+// other package functions choosing actions for the agent draw from it
+// too.
+func (r *randSource) Rand() *rand.Rand {
+	return r.rng
+}
+
+// randIntn returns a random int in [0, n) from rng, or from the math/rand
+// global source if rng is nil.
+func randIntn(rng *rand.Rand, n int) int {
+	if rng == nil {
+		return rand.Intn(n)
+	}
+
+	return rng.Intn(n)
+}
+
+// randFloat32 returns a random float32 in [0, 1) from rng, or from the
+// math/rand global source if rng is nil.
+func randFloat32(rng *rand.Rand) float32 {
+	if rng == nil {
+		return rand.Float32()
+	}
+
+	return rng.Float32()
+}
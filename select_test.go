@@ -0,0 +1,232 @@
+package qlearning
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSampleActionFollowsProbabilities(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	agent.SetExplorationSchedule(Constant(0.3))
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	// a is greedy, chosen with probability 0.7 + 0.3/3, and the others
+	// with 0.3/3 each.
+	want := agent.ActionProbabilities(g.at("s"))
+	if !near(want["a"], 0.8, 1e-6) || !near(want["b"], 0.1, 1e-6) {
+		t.Fatalf("ActionProbabilities = %v, want a 0.8 and the others 0.1", want)
+	}
+
+	const draws = 10000
+	counts := map[string]int{}
+	for i := 0; i < draws; i++ {
+		counts[SampleAction(agent, g.at("s")).Action.String()]++
+	}
+	for action, p := range want {
+		if got := float32(counts[action]) / draws; !near(got, p, 0.02) {
+			t.Errorf("%s sampled %g of the time, want %g", action, got, p)
+		}
+	}
+}
+
+func TestSampleActionAmongBest(t *testing.T) {
+	g := graph{"arms": {"a": "arms", "b": "arms", "c": "arms"}}
+	agent := NewBanditAgent(0)
+	agent.Learn(g.step("arms", "a"), fixedReward(1))
+	agent.Learn(g.step("arms", "b"), fixedReward(1))
+
+	// BanditAgent reports no probabilities, so the best actions, a and
+	// b, are sampled uniformly.
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		counts[SampleAction(agent, g.at("arms")).Action.String()]++
+	}
+	if counts["c"] != 0 || counts["a"] < 900 || counts["b"] < 900 {
+		t.Errorf("sampled %v, want a and b about 1000 times each", counts)
+	}
+
+	if sa := SampleAction(agent, g.at("none")); sa != nil {
+		t.Errorf("SampleAction of a state without actions = %v, want nil", sa)
+	}
+}
+
+// seedable is an Agent with its own source of randomness.
+type seedable interface {
+	Agent
+	SetSeed(seed int64)
+}
+
+// oneHot is a Featurizer giving each action of g a feature of its own.
+func oneHot(g graph) Featurizer {
+	index := map[string]int{}
+	for _, state := range []string{"s", "t", "u"} {
+		for _, action := range g.at(state).Next() {
+			index[cellKey(state, action.String())] = len(index)
+		}
+	}
+
+	return FeaturizerFunc(func(state State, action Action) []float32 {
+		features := make([]float32, len(index))
+		features[index[cellKey(state.String(), action.String())]] = 1
+		return features
+	})
+}
+
+func TestSeededAgentsReplay(t *testing.T) {
+	g := graph{
+		"s": {"a": "t", "b": "u", "c": "end"},
+		"t": {"a": "end", "b": "s"},
+		"u": {"a": "s", "b": "end"},
+	}
+	r := rewards{"a": 1, "c": 0.5}
+
+	agents := map[string]func() seedable{
+		"BanditAgent":   func() seedable { return NewBanditAgent(0.3) },
+		"DoubleQAgent":  func() seedable { return NewDoubleQAgent(0.5, 0.9, 0.3) },
+		"EnsembleAgent": func() seedable { return NewEnsembleAgent(NewSimpleAgent(0.5, 0.9), NewSimpleAgent(0.2, 0.9)) },
+		"Float64Agent":  func() seedable { return NewFloat64Agent(0.5, 0.9, 0.3) },
+		"LambdaAgent":   func() seedable { return NewLambdaAgent(0.5, 0.9, 0.8, 0.3) },
+		"LinearAgent":   func() seedable { return NewLinearAgent(oneHot(g), 0.5, 0.9, 0.3) },
+		"NStepAgent":    func() seedable { return NewNStepAgent(0.5, 0.9, 2, 0.3) },
+		"RandomAgent":   func() seedable { return NewRandomAgent() },
+		"SarsaAgent":    func() seedable { return NewSarsaAgent(0.5, 0.9, 0.3) },
+	}
+
+	// train trains an agent seeded with seed for a few episodes, and
+	// returns its choices followed by the values it learned.
+	train := func(agent seedable, seed int64) []string {
+		agent.SetSeed(seed)
+
+		var record []string
+		for episode := 0; episode < 30; episode++ {
+			state := State(g.at("s"))
+			for step := 0; step < 10 && state.String() != "end"; step++ {
+				sa := Next(agent, state)
+				record = append(record, sa.Action.String())
+				agent.Learn(sa, r)
+				state = sa.Action.Apply(state)
+			}
+		}
+		for _, state := range []string{"s", "t", "u"} {
+			for _, action := range g.at(state).Next() {
+				v := agent.Value(g.at(state), action)
+				record = append(record, strconv.FormatFloat(float64(v), 'g', -1, 32))
+			}
+		}
+
+		return record
+	}
+
+	for name, newAgent := range agents {
+		captured, replayed := train(newAgent(), 3), train(newAgent(), 3)
+		if strings.Join(captured, " ") != strings.Join(replayed, " ") {
+			t.Errorf("%s trained with the same seed made different choices or learned different values:\n%v\n%v", name, captured, replayed)
+		}
+		if other := train(newAgent(), 4); strings.Join(other, " ") == strings.Join(captured, " ") {
+			t.Errorf("%s trained the same with another seed", name)
+		}
+	}
+}
+
+func TestGreedyTiesChosenFairly(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end", "d": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	for _, action := range []string{"a", "b", "c"} {
+		agent.Learn(g.step("s", action), fixedReward(1))
+	}
+
+	// a, b and c tie for the highest value, and d is worse.
+	for name, choose := range map[string]func() *StateAction{
+		"NextEpsilon":  func() *StateAction { return NextEpsilon(agent, g.at("s"), 0) },
+		"GreedyPolicy": func() *StateAction { return GreedyPolicy{}.Select(agent, g.at("s")) },
+	} {
+		counts := map[string]int{}
+		for i := 0; i < 3000; i++ {
+			counts[choose().Action.String()]++
+		}
+		for _, action := range []string{"a", "b", "c"} {
+			if counts[action] < 900 || counts[action] > 1100 {
+				t.Errorf("%s chose %s %d times in 3000, want about 1000: %v", name, action, counts[action], counts)
+			}
+		}
+		if counts["d"] != 0 {
+			t.Errorf("%s chose d, which is not tied, %d times", name, counts["d"])
+		}
+	}
+
+	// Best stays deterministic.
+	if got := Best(agent, g.at("s")).Action.String(); got != "a" {
+		t.Errorf("Best = %s, want a, the first tied action", got)
+	}
+}
+
+func TestTryUnseen(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end", "c": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.SetSeed(1)
+	agent.Learn(g.step("s", "a"), fixedReward(1))
+
+	if sa := agent.Select(g.at("s")); sa.Action.String() != "a" {
+		t.Fatalf("chose %s without SetTryUnseen, want the greedy a", sa.Action)
+	}
+
+	agent.SetTryUnseen(true)
+	probs := agent.ActionProbabilities(g.at("s"))
+	if probs["a"] != 0 || probs["b"] != 0.5 || probs["c"] != 0.5 {
+		t.Errorf("probabilities %v, want 0.5 for each unseen action", probs)
+	}
+
+	// Each unseen action is tried once, however poor it turns out, and
+	// then the greedy one is chosen again.
+	tried := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		explained := agent.SelectExplained(g.at("s"))
+		action := explained.Choice.Action.String()
+		if action == "a" || tried[action] {
+			t.Fatalf("chose %s with %v tried, want an unseen action", action, tried)
+		}
+		if !explained.Explored {
+			t.Errorf("choice of unseen %s not reported as explored", action)
+		}
+		tried[action] = true
+		agent.Learn(explained.Choice, fixedReward(-1))
+	}
+	if sa := agent.Select(g.at("s")); sa.Action.String() != "a" {
+		t.Errorf("chose %s once every action was tried, want the greedy a", sa.Action)
+	}
+}
+
+func TestTrainerPolicy(t *testing.T) {
+	g := graph{"s": {"a": "end", "b": "end"}}
+	newEnv := func() Environment { return &walk{g, rewards{"a": 1}, "s"} }
+	a, b := edge{g, "a", "end"}, edge{g, "b", "end"}
+	agent := NewSimpleAgent(0.5, 0)
+	agent.SetSeed(1)
+
+	// The agent never explores by itself, but a random policy tries both
+	// actions.
+	trainer := &Trainer{Policy: RandomPolicy{}}
+	trainer.TrainEpisodes(agent, newEnv, 20)
+	if agent.Visits(g.at("s"), a) == 0 || agent.Visits(g.at("s"), b) == 0 {
+		t.Fatalf("random policy visited a %d and b %d times, want both tried",
+			agent.Visits(g.at("s"), a), agent.Visits(g.at("s"), b))
+	}
+
+	// Swapped between episodes for a greedy one, training only takes the
+	// rewarded action, through any function made a Policy.
+	calls := 0
+	trainer.Policy = PolicyFunc(func(agent Agent, state State) *StateAction {
+		calls++
+		return GreedyPolicy{}.Select(agent, state)
+	})
+	visits := agent.Visits(g.at("s"), b)
+	trainer.TrainEpisodes(agent, newEnv, 10)
+	if calls != 10 || agent.Visits(g.at("s"), b) != visits {
+		t.Errorf("greedy policy called %d times, b visited %d more times; want 10 and 0",
+			calls, agent.Visits(g.at("s"), b)-visits)
+	}
+}
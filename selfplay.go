@@ -0,0 +1,109 @@
+package qlearning
+
+// SelfPlay plays a single game of a two-player, turn-based, zero-sum
+// game between first and second, who take turns starting with first,
+// choosing each of their moves with Next. Each agent learns only from
+// its own moves, and sees the game from its own side: a move leads not
+// to the state the opponent moves from, but to the state the same agent
+// moves from next, after the opponent's reply, and its reward is the
+// reward of the move less that of the reply, as whatever the opponent
+// gains is lost to the mover. A move that ends the game, or that the
+// opponent's reply ends it after, is learned from as the end of an
+// episode, toward its reward alone, or less that of the final reply.
+// Once the game is over, SelfPlay calls NewEpisode on each agent that is
+// an EpisodeResetter, once if they are the same, and returns the number
+// of moves made and the final state.
+//
+// game is the state the game starts from, and every state of the game
+// is an Environment: it rewards the moves made in it, from the point of
+// view of the player making them, and knows when the game is over.
+// Unlike the Environments of the other episode helpers, Apply must
+// leave the state it is given unchanged and return a new one, as the
+// state a move was made in is still needed after the reply; a game
+// whose Apply changes the state in place must copy it first. If an
+// Action returns a State that is not an Environment, SelfPlay panics.
+//
+// What an agent learns from is a stand-in for the move made, with the
+// same String, whose Apply returns the state after the opponent's reply
+// without applying anything, so agents learn as they would from any
+// other action. first and second may be the same agent, to learn a
+// single policy for both sides, as long as the states of the game tell
+// whose turn it is.
+func SelfPlay(first, second Agent, game Environment) (int, Environment) {
+	players := [2]Agent{first, second}
+	var pending [2]*move
+
+	moves := 0
+	state := game
+	for !state.Done() {
+		p := moves % 2
+
+		sa := Next(players[p], state)
+		if sa == nil {
+			break
+		}
+
+		applied, err := applyAction(sa.Action, state)
+		if err != nil {
+			break
+		}
+		next := applied.(Environment)
+		r := rewardOf(state, sa, next, nil)
+
+		// This move answers the opponent's last one, which can now be
+		// learned from.
+		if m := pending[1-p]; m != nil {
+			players[1-p].Learn(m.replay(next), fixedReward(m.reward-r))
+			pending[1-p] = nil
+		}
+
+		pending[p] = &move{sa, r}
+		state = next
+		moves++
+	}
+
+	// Whatever move is left unanswered ended the game, or was the last
+	// before it was stopped.
+	for p, m := range pending {
+		if m != nil {
+			sa := m.replay(state)
+			sa.Terminal = true
+			players[p].Learn(sa, fixedReward(m.reward))
+		}
+	}
+
+	newEpisode(first)
+	if second != first {
+		newEpisode(second)
+	}
+
+	return moves, state
+}
+
+// move is a move made in SelfPlay, and its reward to the player making
+// it.
+type move struct {
+	sa     *StateAction
+	reward float32
+}
+
+// replay returns a StateAction for m whose Action leads to next.
+func (m *move) replay(next State) *StateAction {
+	return &StateAction{
+		State:  m.sa.State,
+		Action: playedAction{m.sa.Action, next},
+		Value:  m.sa.Value,
+		Meta:   m.sa.Meta,
+	}
+}
+
+// playedAction is an Action already made, whose Apply returns the state
+// it led to without applying anything.
+type playedAction struct {
+	Action
+	next State
+}
+
+func (a playedAction) Apply(state State) State {
+	return a.next
+}
@@ -0,0 +1,112 @@
+package qlearning
+
+import (
+	"strconv"
+	"testing"
+)
+
+// nim is a game of Nim played with a single pile: each player in turn
+// takes one or two sticks, and whoever takes the last one wins. The
+// player to move from a pile that is a multiple of 3 loses against
+// best play.
+type nim int
+
+func (n nim) String() string {
+	return strconv.Itoa(int(n))
+}
+
+func (n nim) Next() []Action {
+	var actions []Action
+	for take := 1; take <= 2 && take <= int(n); take++ {
+		actions = append(actions, nimTake(take))
+	}
+
+	return actions
+}
+
+// Reward rewards taking the last stick with 1.
+func (n nim) Reward(sa *StateAction) float32 {
+	if int(n) == int(sa.Action.(nimTake)) {
+		return 1
+	}
+	return 0
+}
+
+func (n nim) Done() bool {
+	return n == 0
+}
+
+// nimTake is a move of nim, taking that many sticks.
+type nimTake int
+
+func (t nimTake) String() string {
+	return strconv.Itoa(int(t))
+}
+
+func (t nimTake) Apply(state State) State {
+	return state.(nim) - nim(t)
+}
+
+func TestSelfPlayNim(t *testing.T) {
+	// Learn bootstraps from no less than the default value, so it is the
+	// loss, -1, for the values of losing piles to fall that low.
+	first, second := NewSimpleAgentWithInit(0.5, 1, -1), NewSimpleAgentWithInit(0.5, 1, -1)
+	for _, agent := range []*SimpleAgent{first, second} {
+		agent.SetSeed(1)
+		agent.SetEpsilonSchedule(1, 0, 0.999)
+	}
+
+	for i := 0; i < 5000; i++ {
+		if moves, end := SelfPlay(first, second, nim(7)); !end.Done() || moves == 0 {
+			t.Fatalf("game %d stopped after %d moves at %v", i, moves, end)
+		}
+	}
+
+	// Each agent learns the piles it moves from. From a pile that is not
+	// a multiple of 3 the mover wins by leaving one that is, and from
+	// one that is the mover loses whatever it takes.
+	for name, agent := range map[string]*SimpleAgent{"first": first, "second": second} {
+		for pile := 1; pile <= 7; pile++ {
+			values := agent.ValuesFor(nim(pile))
+			if len(values) == 0 {
+				continue
+			}
+
+			want, best := float32(-1), ""
+			if pile%3 != 0 {
+				want, best = 1, strconv.Itoa(pile%3)
+			}
+			action, value := "", float32(-2)
+			for a, v := range values {
+				if v > value {
+					action, value = a, v
+				}
+			}
+			if !near(value, want, 0.1) {
+				t.Errorf("%s: best value of pile %d = %g, want %g", name, pile, value, want)
+			}
+			if best != "" && action != best {
+				t.Errorf("%s: best move from pile %d takes %s, want %s", name, pile, action, best)
+			}
+		}
+	}
+}
+
+func TestSelfPlayRewards(t *testing.T) {
+	// From 2 the first player takes one stick, and the second wins by
+	// taking the last: the first learns the loss as its own reward.
+	first, second := NewSimpleAgent(1, 1), NewSimpleAgent(1, 1)
+	first.q.Set("2", "1", 1)
+	first.q.Set("2", "2", -1)
+
+	moves, end := SelfPlay(first, second, nim(2))
+	if moves != 2 || end != nim(0) {
+		t.Fatalf("got %d moves ending at %v, want 2 ending at 0", moves, end)
+	}
+	if v := first.ValueKeyed("2", "1"); v != -1 {
+		t.Errorf("first player's value of its losing move = %g, want -1", v)
+	}
+	if v := second.ValueKeyed("1", "1"); v != 1 {
+		t.Errorf("second player's value of its winning move = %g, want 1", v)
+	}
+}
@@ -0,0 +1,218 @@
+package qlearning
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// shardPattern names the files written by SaveSharded, with the index of
+// the shard and the total number of shards.
+const shardPattern = "qtable-%05d-of-%05d.gob"
+
+// ShardOf returns the shard, in [0, shards), that SaveSharded places a
+// state key in: the 32-bit FNV-1a hash of the key modulo shards. The
+// scheme depends only on the key, so a state stays in the same shard
+// across runs and machines for a given number of shards.
+func ShardOf(state string, shards int) int {
+	return int(fnv32a(state) % uint32(shards))
+}
+
+// fnv32a returns the 32-bit FNV-1a hash of s, as hash/fnv computes it,
+// without converting s to a byte slice.
+func fnv32a(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+
+	return h
+}
+
+// SaveSharded writes the agent to shards files in dir, partitioning its
+// Q-values and update counts by ShardOf the state key. Shard i of n is
+// named qtable-0000i-of-0000n.gob and holds a snapshot in the format
+// written by Save, containing only the states of that shard; every shard
+// also carries the learning rate, discount, step count and reward
+// statistics. The shards are written concurrently.
+//
+// dir must exist. Shard files in dir from an earlier save with a
+// different number of shards are removed once every new shard has been
+// written, so that LoadSharded sees only one set.
+func (agent *SimpleAgent) SaveSharded(dir string, shards int) error {
+	if shards < 1 {
+		return fmt.Errorf("qlearning: shard count must be at least 1, not %d", shards)
+	}
+
+	bodies := make([]snapshotV2, shards)
+	for i := range bodies {
+		body := agent.snapshot()
+		body.Q = make(map[string]map[string]float32)
+		body.Visits = make(map[string]map[string]int)
+		bodies[i] = body
+	}
+
+	for state, actions := range agent.table() {
+		bodies[ShardOf(state, shards)].Q[state] = actions
+	}
+	for state, visits := range agent.n {
+		bodies[ShardOf(state, shards)].Visits[state] = visits
+	}
+
+	errs := make([]error, shards)
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = writeShard(filepath.Join(dir, fmt.Sprintf(shardPattern, i, shards)), bodies[i])
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	stale, err := shardFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, shard := range stale {
+		if shard.of != shards {
+			if err := os.Remove(shard.path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadSharded replaces the agent's learned state with that of the shards
+// written to dir by SaveSharded, as Load does for a single snapshot. The
+// shards are read concurrently.
+//
+// LoadSharded returns an error if dir holds no shards, if shards are
+// missing, or if they are from saves with different numbers of shards.
+// The agent is unchanged if LoadSharded returns an error.
+func (agent *SimpleAgent) LoadSharded(dir string) error {
+	files, err := shardFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("qlearning: no shards in %s", dir)
+	}
+
+	shards := files[0].of
+	paths := make([]string, shards)
+	for _, f := range files {
+		if f.of != shards {
+			return fmt.Errorf("qlearning: %s holds shards from saves of %d and %d shards", dir, shards, f.of)
+		}
+		paths[f.index] = f.path
+	}
+	for i, path := range paths {
+		if path == "" {
+			return fmt.Errorf("qlearning: shard %d of %d is missing from %s", i, shards, dir)
+		}
+	}
+
+	bodies := make([]snapshotV2, shards)
+	errs := make([]error, shards)
+	var wg sync.WaitGroup
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i], errs[i] = readShard(paths[i], agent.lr, agent.d)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	merged := bodies[0]
+	merged.Q = make(map[string]map[string]float32)
+	merged.Visits = make(map[string]map[string]int)
+	for _, body := range bodies {
+		for state, actions := range body.Q {
+			merged.Q[state] = actions
+		}
+		for state, visits := range body.Visits {
+			merged.Visits[state] = visits
+		}
+	}
+
+	agent.restore(merged)
+
+	return nil
+}
+
+// shardFile is a file written by SaveSharded.
+type shardFile struct {
+	path  string
+	index int
+	of    int
+}
+
+// shardFiles returns the shard files in dir.
+func shardFiles(dir string) ([]shardFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "qtable-*-of-*.gob"))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []shardFile
+	for _, path := range paths {
+		var f shardFile
+		if _, err := fmt.Sscanf(filepath.Base(path), shardPattern, &f.index, &f.of); err != nil {
+			continue
+		}
+		if f.of < 1 || f.index < 0 || f.index >= f.of {
+			continue
+		}
+		f.path = path
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// writeShard writes a snapshot body to the file at path.
+func writeShard(path string, body snapshotV2) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := encodeSnapshot(f, body); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// readShard reads a snapshot body from the file at path, as
+// decodeSnapshot does.
+func readShard(path string, lr, d float32) (snapshotV2, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return snapshotV2{}, err
+	}
+	defer f.Close()
+
+	body, err := decodeSnapshot(f, lr, d)
+	if err != nil {
+		return snapshotV2{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return body, nil
+}
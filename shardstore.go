@@ -0,0 +1,202 @@
+package qlearning
+
+import "sync"
+
+// DefaultShards is the number of shards of the ShardedStore a SimpleAgent
+// keeps its Q-values in unless given another Store.
+const DefaultShards = 16
+
+// ShardedStore is a Store safe for concurrent use, partitioning its
+// Q-values into shards by ShardOf the state, each with its own lock, so
+// that goroutines working on states of different shards do not wait for
+// each other. It is what NewSimpleAgent uses, with DefaultShards shards.
+//
+// A SimpleAgent is not safe for concurrent use whatever its Store, as it
+// keeps update counts and statistics of its own; SyncAgent serializes
+// every update of one agent behind a single lock. Sharing a ShardedStore
+// lets several agents train at once instead, one per goroutine, each
+// calling SetStore with the same store:
+//
+//	store := qlearning.NewShardedStore(64)
+//	for i := 0; i < workers; i++ {
+//		agent := qlearning.NewSimpleAgent(0.7, 1.0)
+//		agent.SetStore(store)
+//		go train(agent)
+//	}
+//
+// Each agent then learns from the Q-values all of them have learned,
+// while keeping its own update counts. A ShardedStore is an Updater, so
+// each update is recorded under the lock of its shard, as the change it
+// makes to the Q-value as it is by then: of two agents updating the same
+// State and Action at once, neither update is lost, although each is
+// computed from the Q-value before the other.
+type ShardedStore struct {
+	shards []memoryShard
+}
+
+// memoryShard is a shard of a ShardedStore.
+type memoryShard struct {
+	mu sync.RWMutex
+	q  map[string]map[string]float32
+}
+
+// NewShardedStore creates an empty ShardedStore with the given number of
+// shards, or 1 if shards is less. A few times the number of goroutines
+// sharing it keeps them from often wanting the same shard.
+func NewShardedStore(shards int) *ShardedStore {
+	if shards < 1 {
+		shards = 1
+	}
+
+	s := &ShardedStore{shards: make([]memoryShard, shards)}
+	for i := range s.shards {
+		s.shards[i].q = make(map[string]map[string]float32)
+	}
+
+	return s
+}
+
+// shard returns the shard of state.
+func (s *ShardedStore) shard(state string) *memoryShard {
+	return &s.shards[ShardOf(state, len(s.shards))]
+}
+
+// Get implements Store.
+func (s *ShardedStore) Get(state, action string) (float32, bool) {
+	sh := s.shard(state)
+	sh.mu.RLock()
+	v, ok := sh.q[state][action]
+	sh.mu.RUnlock()
+
+	return v, ok
+}
+
+// Set implements Store.
+func (s *ShardedStore) Set(state, action string, v float32) {
+	sh := s.shard(state)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	actions, ok := sh.q[state]
+	if !ok {
+		actions = make(map[string]float32)
+		sh.q[state] = actions
+	}
+
+	actions[action] = v
+}
+
+// Update implements Updater, holding the lock of the shard of state
+// while fn runs, so fn must not call any method of the store.
+func (s *ShardedStore) Update(state, action string, fn func(v float32, ok bool) float32) float32 {
+	sh := s.shard(state)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	actions, ok := sh.q[state]
+	if !ok {
+		actions = make(map[string]float32)
+		sh.q[state] = actions
+	}
+
+	v, ok := actions[action]
+	v = fn(v, ok)
+	actions[action] = v
+
+	return v
+}
+
+// Delete implements Deleter.
+func (s *ShardedStore) Delete(state, action string) {
+	sh := s.shard(state)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	actions, ok := sh.q[state]
+	if !ok {
+		return
+	}
+
+	delete(actions, action)
+	if len(actions) == 0 {
+		delete(sh.q, state)
+	}
+}
+
+// States implements Store.
+func (s *ShardedStore) States() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		n += len(sh.q)
+		sh.mu.RUnlock()
+	}
+
+	return n
+}
+
+// Actions implements Store. fn is called with the Q-values of state as
+// they were when Actions was called, without holding a lock, so it may
+// call any method of the store. They are copied into a buffer on the
+// stack for states with up to actionBuffer actions, so that Learn, which
+// reads the actions of every state it reaches, does not allocate for
+// them.
+func (s *ShardedStore) Actions(state string, fn func(action string, v float32) bool) {
+	var buf [actionBuffer]actionValue
+	values := buf[:0]
+
+	sh := s.shard(state)
+	sh.mu.RLock()
+	for action, v := range sh.q[state] {
+		values = append(values, actionValue{action, v})
+	}
+	sh.mu.RUnlock()
+
+	for _, value := range values {
+		if !fn(value.action, value.v) {
+			return
+		}
+	}
+}
+
+// actionBuffer is the number of Q-values of a state ShardedStore.Actions
+// copies without allocating.
+const actionBuffer = 32
+
+// actionValue is a Q-value of an action, as ShardedStore.Actions copies
+// them.
+type actionValue struct {
+	action string
+	v      float32
+}
+
+// Range implements Store. Each shard is copied in turn and fn called for
+// its Q-values without holding a lock, so Range sees each shard as it
+// was when reached, and fn may call any method of the store.
+func (s *ShardedStore) Range(fn func(state, action string, v float32) bool) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		q := copyTable(sh.q)
+		sh.mu.RUnlock()
+
+		for state, actions := range q {
+			for action, v := range actions {
+				if !fn(state, action, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Clear implements Store.
+func (s *ShardedStore) Clear() {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		sh.q = make(map[string]map[string]float32)
+		sh.mu.Unlock()
+	}
+}
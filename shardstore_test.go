@@ -0,0 +1,220 @@
+package qlearning
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestShardedStoreMatchesMemoryStore(t *testing.T) {
+	sharded, memory := NewSimpleAgent(0.5, 0.9), NewSimpleAgent(0.5, 0.9)
+	sharded.SetStore(NewShardedStore(8))
+	memory.SetStore(NewMemoryStore())
+	for _, step := range gridSteps(2000) {
+		sharded.Learn(step, gridReward)
+		memory.Learn(step, gridReward)
+	}
+
+	if s, m := sharded.q.States(), memory.q.States(); s != m {
+		t.Fatalf("%d states in the ShardedStore, %d in the MemoryStore", s, m)
+	}
+	memory.q.Range(func(state, action string, v float32) bool {
+		if got, ok := sharded.q.Get(state, action); !ok || got != v {
+			t.Errorf("%s %s: ShardedStore %g (%t), MemoryStore %g", state, action, got, ok, v)
+		}
+		return true
+	})
+
+	sharded.q.(Deleter).Delete("s", "a")
+	sharded.q.Clear()
+	if n := sharded.q.States(); n != 0 {
+		t.Errorf("%d states after Clear, want 0", n)
+	}
+}
+
+func TestSimpleAgentDefaultStore(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	if _, ok := agent.q.(*ShardedStore); !ok {
+		t.Fatalf("NewSimpleAgent stores Q-values in a %T, want a *ShardedStore", agent.q)
+	}
+
+	zero := new(SimpleAgent)
+	zero.restore(snapshotV2{})
+	if _, ok := zero.q.(*ShardedStore); !ok {
+		t.Errorf("a zero SimpleAgent is restored with a %T, want a *ShardedStore", zero.q)
+	}
+}
+
+func TestShardedStoreActionsCallingStore(t *testing.T) {
+	store := NewShardedStore(1)
+	for _, action := range []string{"a", "b", "c"} {
+		store.Set("s", action, 1)
+	}
+
+	// fn may write the state being read, as the Q-values were copied
+	// before it was called.
+	seen := 0
+	store.Actions("s", func(action string, v float32) bool {
+		store.Set("s", action, v+1)
+		seen++
+		return true
+	})
+	if seen != 3 {
+		t.Errorf("Actions called fn for %d Q-values, want 3", seen)
+	}
+	for _, action := range []string{"a", "b", "c"} {
+		if v, _ := store.Get("s", action); v != 2 {
+			t.Errorf("Q(s, %s) = %g, want 2", action, v)
+		}
+	}
+}
+
+func TestShardedStoreUpdateAtomic(t *testing.T) {
+	store := NewShardedStore(4)
+	const workers, updates = 8, 1000
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				store.Update("s", "a", func(v float32, ok bool) float32 {
+					return v + 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := store.Get("s", "a"); v != workers*updates {
+		t.Errorf("Q-value %g after %d concurrent increments, want all of them", v, workers*updates)
+	}
+}
+
+func TestShardedStoreAgentsBuildOnEachOther(t *testing.T) {
+	g := graph{"s": {"a": "end"}}
+	store := NewShardedStore(4)
+	first, second := NewSimpleAgent(0.5, 0), NewSimpleAgent(0.5, 0)
+	first.SetStore(store)
+	second.SetStore(store)
+
+	// Both plan their update from a Q-value of 0, as if at once, and the
+	// second is recorded on top of the first instead of replacing it.
+	u1, _ := first.plan("s", g.step("s", "a"), fixedReward(1))
+	u2, _ := second.plan("s", g.step("s", "a"), fixedReward(1))
+	first.commit(u1)
+	second.commit(u2)
+
+	if v := first.Value(g.at("s"), edge{g, "a", "end"}); v != 1 {
+		t.Errorf("Q = %g after two concurrent updates of 0.5, want 1", v)
+	}
+}
+
+// learnStores are the kinds of Store for the benchmarks to compare.
+var learnStores = []struct {
+	name  string
+	store func() Store
+}{
+	{"MemoryStore", func() Store { return NewMemoryStore() }},
+	{"ShardedStore", func() Store { return NewShardedStore(64) }},
+}
+
+func BenchmarkLearn(b *testing.B) {
+	steps := gridSteps(1024)
+	for _, s := range learnStores {
+		b.Run(s.name, func(b *testing.B) {
+			agent := NewSimpleAgent(0.5, 0.9)
+			agent.SetStore(s.store())
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				agent.Learn(steps[i%len(steps)], gridReward)
+			}
+		})
+	}
+
+	// Concurrently, an agent on a MemoryStore must be shared behind a
+	// SyncAgent, while agents on a ShardedStore can each have their own.
+	b.Run("MemoryStoreParallel", func(b *testing.B) {
+		simple := NewSimpleAgent(0.5, 0.9)
+		simple.SetStore(NewMemoryStore())
+		agent := NewSyncAgent(simple)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				agent.Learn(steps[i%len(steps)], gridReward)
+			}
+		})
+	})
+	b.Run("ShardedStoreParallel", func(b *testing.B) {
+		store := NewShardedStore(64)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			agent := NewSimpleAgent(0.5, 0.9)
+			agent.SetStore(store)
+			for i := 0; pb.Next(); i++ {
+				agent.Learn(steps[i%len(steps)], gridReward)
+			}
+		})
+	})
+}
+
+func BenchmarkValue(b *testing.B) {
+	steps := gridSteps(1024)
+	for _, s := range learnStores {
+		b.Run(s.name, func(b *testing.B) {
+			agent := NewSimpleAgent(0.5, 0.9)
+			agent.SetStore(s.store())
+			for _, step := range steps {
+				agent.Learn(step, gridReward)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				step := steps[i%len(steps)]
+				agent.Value(step.State, step.Action)
+			}
+		})
+	}
+
+	b.Run("MemoryStoreParallel", func(b *testing.B) {
+		simple := NewSimpleAgent(0.5, 0.9)
+		simple.SetStore(NewMemoryStore())
+		agent := NewSyncAgent(simple)
+		agent.LearnBatch(steps, gridReward)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				step := steps[i%len(steps)]
+				agent.Value(step.State, step.Action)
+			}
+		})
+	})
+	b.Run("ShardedStoreParallel", func(b *testing.B) {
+		store := NewShardedStore(64)
+		trained := NewSimpleAgent(0.5, 0.9)
+		trained.SetStore(store)
+		for _, step := range steps {
+			trained.Learn(step, gridReward)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			agent := NewSimpleAgent(0.5, 0.9)
+			agent.SetStore(store)
+			for i := 0; pb.Next(); i++ {
+				step := steps[i%len(steps)]
+				agent.Value(step.State, step.Action)
+			}
+		})
+	})
+}
@@ -0,0 +1,243 @@
+package qlearning
+
+import "math"
+
+// ValueHistogram divides the range of recorded Q-values into the given
+// number of equal-width buckets and returns the count of values in each,
+// along with the smallest and largest value recorded.
+//
+// A table that is diverging shows up as values piling into the first or
+// last bucket while min and max grow. If no values are recorded, or
+// buckets is less than 1, the counts are all zero.
+func (agent *SimpleAgent) ValueHistogram(buckets int) (counts []int, min, max float32) {
+	if buckets < 1 {
+		return nil, 0, 0
+	}
+
+	counts = make([]int, buckets)
+
+	first := true
+	agent.Range(func(_, _ string, value float32) bool {
+		if first || value < min {
+			min = value
+		}
+		if first || value > max {
+			max = value
+		}
+		first = false
+		return true
+	})
+
+	if first {
+		return counts, 0, 0
+	}
+
+	width := (max - min) / float32(buckets)
+	agent.Range(func(_, _ string, value float32) bool {
+		i := 0
+		if width > 0 {
+			i = int((value - min) / width)
+		}
+		// max itself belongs to the last bucket.
+		if i >= buckets {
+			i = buckets - 1
+		}
+		counts[i]++
+		return true
+	})
+
+	return counts, min, max
+}
+
+// SetValueSmoothing enables an exponential moving average of every
+// Q-value, maintained alongside the Q-values themselves and read with
+// SmoothedValue. Each update moves the average toward the new Q-value by
+// the fraction alpha, which should be in (0, 1]; smaller values smooth
+// more. An alpha of 0 disables smoothing and discards the averages.
+//
+// The averages are for display only, such as live plots of training, and
+// never affect learning or action selection.
+func (agent *SimpleAgent) SetValueSmoothing(alpha float32) {
+	agent.smoothing = alpha
+
+	if alpha == 0 {
+		agent.smoothed = nil
+	} else if agent.smoothed == nil {
+		agent.smoothed = make(map[string]map[string]float32)
+	}
+}
+
+// SmoothedValue returns the moving average of the Q-value for a State
+// and Action, or the Q-value itself if smoothing is disabled or the
+// Q-value has not been updated since smoothing was enabled.
+func (agent *SimpleAgent) SmoothedValue(state State, action Action) float32 {
+	if v, ok := agent.smoothed[state.String()][action.String()]; ok {
+		return v
+	}
+
+	return agent.Value(state, action)
+}
+
+// smooth updates the moving average of a Q-value that was just set to
+// value.
+func (agent *SimpleAgent) smooth(state, action string, value float32) {
+	if agent.smoothed == nil {
+		return
+	}
+
+	if _, ok := agent.smoothed[state]; !ok {
+		agent.smoothed[state] = make(map[string]float32)
+	}
+
+	avg, ok := agent.smoothed[state][action]
+	if !ok {
+		avg = value
+	}
+
+	agent.smoothed[state][action] = avg + agent.smoothing*(value-avg)
+}
+
+// Sizes used by EstimatedBytes, for a 64-bit platform.
+const (
+	// stringBytes is the size of a string header, excluding its data.
+	stringBytes = 16
+
+	// mapBytes is the fixed size of a map.
+	mapBytes = 48
+
+	// mapEntryBytes is the overhead of a map entry beyond its key and
+	// value: its share of the bucket metadata and of the unused slots
+	// a map keeps to stay below its load factor.
+	mapEntryBytes = 8
+)
+
+// EstimatedBytes returns a rough estimate of the memory used by the
+// agent's tables of Q-values, update counts, smoothed values, and target
+// values, for deciding when to prune or shard a table that keeps
+// growing.
+//
+// The Q-values are estimated as a MemoryStore would hold them, whatever
+// the agent's Store. The estimate assumes a 64-bit platform. Each table is a map from
+// states to maps from actions to values. Every map counts a fixed size,
+// plus, for every entry, the length of its key, the size of a string
+// header and of its value, which for the outer map is a pointer, and a
+// fixed overhead for the map's buckets. The real figure depends on the Go runtime's map layout
+// and on whether key strings share memory, so it may be off by a
+// sizable factor in either direction, but it grows in proportion to the
+// table.
+func (agent *SimpleAgent) EstimatedBytes() int {
+	q := agent.table()
+	bytes := tableBytes(len(q), 8)
+	for state, actions := range q {
+		bytes += len(state) + tableBytes(len(actions), 4)
+		for action := range actions {
+			bytes += len(action)
+		}
+	}
+
+	bytes += tableBytes(len(agent.n), 8)
+	for state, visits := range agent.n {
+		bytes += len(state) + tableBytes(len(visits), 8)
+		for action := range visits {
+			bytes += len(action)
+		}
+	}
+
+	if agent.smoothed != nil {
+		bytes += tableBytes(len(agent.smoothed), 8)
+		for state, actions := range agent.smoothed {
+			bytes += len(state) + tableBytes(len(actions), 4)
+			for action := range actions {
+				bytes += len(action)
+			}
+		}
+	}
+
+	if agent.targets != nil {
+		bytes += tableBytes(len(agent.targets), 8)
+		for state, actions := range agent.targets {
+			bytes += len(state) + tableBytes(len(actions), 16)
+			for action := range actions {
+				bytes += len(action)
+			}
+		}
+	}
+
+	return bytes
+}
+
+// tableBytes estimates the size of a map with entries string keys and
+// values of valueBytes each, excluding the data of the keys.
+func tableBytes(entries, valueBytes int) int {
+	return mapBytes + entries*(stringBytes+valueBytes+mapEntryBytes)
+}
+
+// PolicyEntropy returns the mean entropy, in nats, of the agent's
+// selection distribution over every state it has recorded, as a single
+// number to log: a high entropy means the agent is still uncertain or
+// exploring, and a falling one that it is converging.
+//
+// The distribution of a state is the one ActionProbabilities reports,
+// and SelectExplained draws from, with the current exploration rate,
+// tie epsilon and tie-breaker, but over the actions recorded for the
+// state, since the table does not hold the others. PolicyEntropy
+// returns 0 if no states are recorded.
+func (agent *SimpleAgent) PolicyEntropy() float32 {
+	var total float64
+	states := 0
+
+	for state, actions := range agent.table() {
+		if len(actions) == 0 {
+			continue
+		}
+
+		entropy := 0.0
+		for _, p := range agent.ActionProbabilities(recordedState{state, actions}) {
+			if p > 0 {
+				entropy -= float64(p) * math.Log(float64(p))
+			}
+		}
+
+		total += entropy
+		states++
+	}
+
+	if states == 0 {
+		return 0
+	}
+
+	return float32(total / float64(states))
+}
+
+// recordedState is a State standing in for a state recorded in the
+// table, offering the actions recorded for it, so that its selection
+// distribution can be found without the State itself.
+type recordedState struct {
+	key     string
+	actions map[string]float32
+}
+
+func (s recordedState) String() string {
+	return s.key
+}
+
+func (s recordedState) Next() []Action {
+	actions := make([]Action, 0, len(s.actions))
+	for action := range s.actions {
+		actions = append(actions, recordedAction(action))
+	}
+
+	return actions
+}
+
+// recordedAction is an Action of a recordedState, which is only ever
+// scored, never applied.
+type recordedAction string
+
+func (a recordedAction) String() string {
+	return string(a)
+}
+
+func (a recordedAction) Apply(state State) State {
+	return state
+}
@@ -0,0 +1,55 @@
+package qlearning
+
+import (
+	"math"
+	"testing"
+)
+
+// entropy returns the entropy, in nats, of the distribution ps.
+func entropy(ps ...float64) float64 {
+	h := 0.0
+	for _, p := range ps {
+		h -= p * math.Log(p)
+	}
+
+	return h
+}
+
+func TestPolicyEntropy(t *testing.T) {
+	agent := NewSimpleAgent(1, 0)
+	if h := agent.PolicyEntropy(); h != 0 {
+		t.Errorf("entropy %g with no states, want 0", h)
+	}
+
+	// Exploring half the time, the tied state draws each of its actions
+	// 1/6 + 1/6 of the time, as the greedy choice is shared among them
+	// too. The untied state draws its best a 1/4 + 1/2 of the time.
+	agent.SetEpsilonSchedule(0.5, 0.5, 1)
+	for _, action := range []string{"a", "b", "c"} {
+		agent.q.Set("tied", action, 1)
+	}
+	agent.q.Set("untied", "a", 2)
+	agent.q.Set("untied", "b", 1)
+
+	tied, untied := entropy(1.0/3, 1.0/3, 1.0/3), entropy(0.75, 0.25)
+	want := (tied + untied) / 2
+	if h := agent.PolicyEntropy(); !near(h, float32(want), 1e-6) {
+		t.Errorf("entropy %g, want %g, the mean of %g tied and %g untied", h, want, tied, untied)
+	}
+
+	// The distribution is the one ActionProbabilities reports.
+	probs := agent.ActionProbabilities(recordedState{"tied", agent.table()["tied"]})
+	if !near(probs["a"], 1.0/3, 1e-6) || !near(probs["b"], 1.0/3, 1e-6) {
+		t.Errorf("probabilities of the tied state %v, want 1/3 for each", probs)
+	}
+
+	// A tie-breaker settles the greedy choice of the tied state on a,
+	// drawn 1/6 + 1/2 of the time, leaving b and c the 1/6 of
+	// exploration each.
+	agent.SetTieBreaker(func(a, b Action) bool { return a.String() < b.String() })
+	tied = entropy(2.0/3, 1.0/6, 1.0/6)
+	want = (tied + untied) / 2
+	if h := agent.PolicyEntropy(); !near(h, float32(want), 1e-6) {
+		t.Errorf("entropy %g with a tie-breaker, want %g, the mean of %g tied and %g untied", h, want, tied, untied)
+	}
+}
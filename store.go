@@ -0,0 +1,188 @@
+package qlearning
+
+// Store holds the Q-values of a SimpleAgent, keyed by the string
+// representations of each State and Action. The agent keeps nothing but
+// its Store about which Q-values it has recorded, so a Store backed by a
+// database, for tables too large to keep in memory, changes nothing
+// about how the agent learns. ShardedStore, the default, and MemoryStore
+// keep them in memory.
+//
+// A Store need not be safe for concurrent use, as a SimpleAgent is not
+// either. Range and Actions may be called with a function that calls Get,
+// but never Set.
+type Store interface {
+	// Get returns the Q-value of state and action, and whether one has
+	// been recorded.
+	Get(state, action string) (float32, bool)
+
+	// Set records the Q-value of state and action.
+	Set(state, action string, v float32)
+
+	// States returns the number of states with a recorded Q-value.
+	States() int
+
+	// Actions calls fn for each recorded Q-value of state, until fn
+	// returns false, in no particular order.
+	Actions(state string, fn func(action string, v float32) bool)
+
+	// Range calls fn for each recorded Q-value, until fn returns false,
+	// in no particular order.
+	Range(fn func(state, action string, v float32) bool)
+
+	// Clear removes every recorded Q-value.
+	Clear()
+}
+
+// Deleter is an optional interface for Stores that can delete a single
+// Q-value, used by Prune and SetMaxStates. Deleting the last Q-value of a
+// state removes the state from the Store. A Store that does not
+// implement Deleter is cleared and refilled without the deleted Q-values
+// instead, which takes time in proportion to its size.
+type Deleter interface {
+	Delete(state, action string)
+}
+
+// Updater is an optional interface for Stores shared between agents
+// that can read and write a single Q-value atomically. Update calls fn
+// with the Q-value of state and action, and whether one has been
+// recorded, and records and returns what fn returns, without letting
+// any other call change the Q-value in between. A SimpleAgent whose
+// Store is an Updater makes each update through it, so that the updates
+// of agents sharing the store build on each other instead of
+// overwriting them.
+type Updater interface {
+	Update(state, action string, fn func(v float32, ok bool) float32) float32
+}
+
+// MemoryStore is a Store keeping Q-values in nested maps, from states to
+// actions to values. It is not safe for concurrent use, and without the
+// locks of a ShardedStore is a little faster for an agent that does not
+// share its Store.
+type MemoryStore struct {
+	q map[string]map[string]float32
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{q: make(map[string]map[string]float32)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(state, action string) (float32, bool) {
+	v, ok := s.q[state][action]
+	return v, ok
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(state, action string, v float32) {
+	actions, ok := s.q[state]
+	if !ok {
+		actions = make(map[string]float32)
+		s.q[state] = actions
+	}
+
+	actions[action] = v
+}
+
+// States implements Store.
+func (s *MemoryStore) States() int {
+	return len(s.q)
+}
+
+// Actions implements Store.
+func (s *MemoryStore) Actions(state string, fn func(action string, v float32) bool) {
+	for action, v := range s.q[state] {
+		if !fn(action, v) {
+			return
+		}
+	}
+}
+
+// Range implements Store.
+func (s *MemoryStore) Range(fn func(state, action string, v float32) bool) {
+	for state, actions := range s.q {
+		for action, v := range actions {
+			if !fn(state, action, v) {
+				return
+			}
+		}
+	}
+}
+
+// Delete implements Deleter.
+func (s *MemoryStore) Delete(state, action string) {
+	actions, ok := s.q[state]
+	if !ok {
+		return
+	}
+
+	delete(actions, action)
+	if len(actions) == 0 {
+		delete(s.q, state)
+	}
+}
+
+// Clear implements Store, keeping the memory of the maps for reuse.
+func (s *MemoryStore) Clear() {
+	for state := range s.q {
+		delete(s.q, state)
+	}
+}
+
+// SetStore makes the agent keep its Q-values in store from now on. The
+// Q-values already recorded are copied into store, which should be
+// empty, so a Store can be set at any time.
+func (agent *SimpleAgent) SetStore(store Store) {
+	agent.q.Range(func(state, action string, v float32) bool {
+		store.Set(state, action, v)
+		return true
+	})
+
+	agent.q = store
+}
+
+// hasState reports whether the agent has recorded a Q-value for state.
+func (agent *SimpleAgent) hasState(state string) bool {
+	found := false
+	agent.q.Actions(state, func(string, float32) bool {
+		found = true
+		return false
+	})
+
+	return found
+}
+
+// setTable replaces the Q-values of the agent with those of q, which the
+// agent may keep.
+func (agent *SimpleAgent) setTable(q map[string]map[string]float32) {
+	if m, ok := agent.q.(*MemoryStore); ok {
+		m.q = q
+		return
+	}
+
+	agent.q.Clear()
+	for state, actions := range q {
+		for action, v := range actions {
+			agent.q.Set(state, action, v)
+		}
+	}
+}
+
+// table returns the Q-values of the agent as nested maps, which are
+// those of a MemoryStore itself, and must not be changed.
+func (agent *SimpleAgent) table() map[string]map[string]float32 {
+	if m, ok := agent.q.(*MemoryStore); ok {
+		return m.q
+	}
+
+	q := make(map[string]map[string]float32, agent.q.States())
+	agent.q.Range(func(state, action string, v float32) bool {
+		if _, ok := q[state]; !ok {
+			q[state] = make(map[string]float32)
+		}
+		q[state][action] = v
+		return true
+	})
+
+	return q
+}
@@ -0,0 +1,41 @@
+package qlearning
+
+import "testing"
+
+// countingStore is a Store counting the Q-values set through it.
+type countingStore struct {
+	*MemoryStore
+	sets int
+}
+
+func (s *countingStore) Set(state, action string, v float32) {
+	s.sets++
+	s.MemoryStore.Set(state, action, v)
+}
+
+func TestSetStore(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "end"}, "t": {"a": "end"}}
+	agent := NewSimpleAgent(0.5, 0.9)
+	agent.Learn(g.step("t", "a"), rewards{"a": 1})
+
+	// The value already recorded is copied into the new store, which
+	// then takes every update.
+	store := &countingStore{MemoryStore: NewMemoryStore()}
+	agent.SetStore(store)
+	if v, ok := store.Get("t", "a"); !ok || v != 0.5 {
+		t.Errorf("copied Q(t, a) = %g, %v; want 0.5, true", v, ok)
+	}
+
+	agent.Learn(g.step("s", "a"), rewards{"a": 1})
+	if store.sets != 2 || store.States() != 2 {
+		t.Errorf("store took %d sets for %d states, want 2 and 2", store.sets, store.States())
+	}
+	if got := agent.Value(g.at("s"), g.step("s", "a").Action); got != store.q["s"]["a"] {
+		t.Errorf("Value = %g, store holds %g", got, store.q["s"]["a"])
+	}
+
+	store.Clear()
+	if agent.States() != 0 {
+		t.Errorf("agent has %d states after its store was cleared, want 0", agent.States())
+	}
+}
@@ -0,0 +1,111 @@
+package qlearning
+
+import "sync"
+
+// SyncAgent wraps a SimpleAgent so that it can be trained on one
+// goroutine while being read from others, such as a monitoring server.
+// SimpleAgent itself does no locking, so that training without
+// concurrent readers pays nothing for it.
+//
+// Every use of the wrapped agent must go through the SyncAgent once it
+// is wrapped.
+type SyncAgent struct {
+	mu    sync.RWMutex
+	agent *SimpleAgent
+}
+
+// NewSyncAgent wraps agent in a SyncAgent.
+func NewSyncAgent(agent *SimpleAgent) *SyncAgent {
+	return &SyncAgent{agent: agent}
+}
+
+// Learn calls Learn on the wrapped agent, holding the write lock.
+func (s *SyncAgent) Learn(action *StateAction, reward Rewarder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.agent.Learn(action, reward)
+}
+
+// LearnBatch calls LearnBatch on the wrapped agent, holding the write
+// lock once for the whole batch rather than once per experience, so
+// readers wait for the batch to finish but see none of it half done.
+func (s *SyncAgent) LearnBatch(experiences []*StateAction, reward Rewarder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.agent.LearnBatch(experiences, reward)
+}
+
+// Value calls Value on the wrapped agent, holding the read lock.
+func (s *SyncAgent) Value(state State, action Action) float32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.agent.Value(state, action)
+}
+
+// Select implements Selector by calling Select on the wrapped agent,
+// holding the write lock, as selection may update the agent.
+func (s *SyncAgent) Select(state State) *StateAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.agent.Select(state)
+}
+
+// SelectErr implements FallibleSelector by calling SelectErr on the
+// wrapped agent, holding the write lock.
+func (s *SyncAgent) SelectErr(state State) (*StateAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.agent.SelectErr(state)
+}
+
+// SelectExplained implements ExplainingSelector by calling
+// SelectExplained on the wrapped agent, holding the write lock.
+func (s *SyncAgent) SelectExplained(state State) *Explanation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.agent.SelectExplained(state)
+}
+
+// NewEpisode implements EpisodeResetter, calling NewEpisode on the
+// wrapped agent, holding the write lock.
+func (s *SyncAgent) NewEpisode() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.agent.NewEpisode()
+}
+
+// String calls String on the wrapped agent, holding the read lock.
+func (s *SyncAgent) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.agent.String()
+}
+
+// Read calls fn with the wrapped agent while holding the read lock, so
+// that fn can call any number of its read-only methods, such as Range or
+// Steps, and see a consistent agent. fn must not modify the agent, and
+// must not call methods of the SyncAgent.
+func (s *SyncAgent) Read(fn func(agent *SimpleAgent)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fn(s.agent)
+}
+
+// Write calls fn with the wrapped agent while holding the write lock, so
+// that fn can modify it, for instance to change its options or Load a
+// snapshot. fn must not call methods of the SyncAgent.
+func (s *SyncAgent) Write(fn func(agent *SimpleAgent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(s.agent)
+}
@@ -0,0 +1,75 @@
+package qlearning
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncAgentLearnBatch(t *testing.T) {
+	steps := gridSteps(500)
+	batched := NewSyncAgent(NewSimpleAgent(0.5, 0.9))
+	unwrapped := NewSimpleAgent(0.5, 0.9)
+	batched.LearnBatch(steps, gridReward)
+	unwrapped.LearnBatch(steps, gridReward)
+
+	for _, step := range steps {
+		if b, u := batched.Value(step.State, step.Action), unwrapped.Value(step.State, step.Action); b != u {
+			t.Fatalf("%v %v: %g learned through a SyncAgent, %g without", step.State, step.Action, b, u)
+		}
+	}
+}
+
+// benchmarkSyncAgent trains a SyncAgent on batches of 256 grid steps
+// with learn, while another goroutine keeps reading it, and reports the
+// time per step.
+func benchmarkSyncAgent(b *testing.B, learn func(agent *SyncAgent, steps []*StateAction)) {
+	agent := NewSyncAgent(NewSimpleAgent(0.5, 0.9))
+	steps := gridSteps(256)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				agent.Value(steps[0].State, steps[0].Action)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(steps) {
+		learn(agent, steps)
+	}
+	b.StopTimer()
+
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkSyncAgentLearn(b *testing.B) {
+	benchmarkSyncAgent(b, func(agent *SyncAgent, steps []*StateAction) {
+		for _, step := range steps {
+			agent.Learn(step, gridReward)
+		}
+	})
+}
+
+func BenchmarkSyncAgentLearnBatch(b *testing.B) {
+	benchmarkSyncAgent(b, func(agent *SyncAgent, steps []*StateAction) {
+		agent.LearnBatch(steps, gridReward)
+	})
+}
+
+func TestSyncAgentNewEpisode(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	(&Trainer{}).TrainEpisodes(NewSyncAgent(agent), newCorridorWalk, 3)
+
+	if n := agent.Episodes(); n != 3 {
+		t.Errorf("wrapped agent counted %d episodes, want 3", n)
+	}
+}
@@ -0,0 +1,87 @@
+package qlearning
+
+import "math"
+
+// targetValue is the value of a state and action in the target table as
+// of the end of a step.
+type targetValue struct {
+	value float32
+	step  int64
+}
+
+// SetTargetTau enables soft target updates, also known as Polyak
+// averaging. Updates then bootstrap off a separate target table instead
+// of the Q-values themselves, and after every step the target value of
+// each state and action moves the fraction tau of the way toward its
+// Q-value:
+//
+//	target = tau*q + (1-tau)*target
+//
+// This smooths the target each update chases, which damps the
+// oscillations of values that bootstrap off each other, at the cost of
+// propagating values more slowly. Smaller values of tau smooth more.
+//
+// Only the values of the next state are taken from the target table;
+// the Q-value being updated, and every value used to select actions,
+// is still the Q-value itself. A tau of 0 or less, or of 1 or more,
+// disables the target table and discards it, so that updates bootstrap
+// off the Q-values again.
+func (agent *SimpleAgent) SetTargetTau(tau float32) {
+	if tau <= 0 || tau >= 1 {
+		agent.targetTau = 0
+		agent.targets = nil
+		return
+	}
+
+	agent.targetTau = tau
+	if agent.targets == nil {
+		agent.targets = make(map[string]map[string]targetValue)
+	}
+}
+
+// target returns the target value of a state and action whose Q-value
+// is online, as of the end of step. A target that has not been recorded
+// is equal to the Q-value.
+//
+// Between updates of its Q-value, a target value decays geometrically
+// toward it, so it is only recorded when the Q-value changes, and
+// brought up to date when it is read, rather than every target value
+// being moved on every step.
+func (agent *SimpleAgent) target(state, action string, online float32, step int64) float32 {
+	t, ok := agent.targets[state][action]
+	if !ok {
+		return online
+	}
+
+	k := step - t.step
+	if k <= 0 {
+		return t.value
+	}
+	decay := float32(math.Pow(float64(1-agent.targetTau), float64(k)))
+
+	return online + (t.value-online)*decay
+}
+
+// updateTarget makes the soft update of the target value of a state and
+// action for the current step, in which its Q-value is about to be set
+// to new.
+func (agent *SimpleAgent) updateTarget(state, action string, new float32) {
+	if agent.targets == nil {
+		return
+	}
+
+	old, ok := agent.q.Get(state, action)
+	if !ok {
+		old = agent.seedValue(state, action)
+	}
+
+	t := agent.target(state, action, old, agent.steps-1)
+
+	if _, ok := agent.targets[state]; !ok {
+		agent.targets[state] = make(map[string]targetValue)
+	}
+	agent.targets[state][action] = targetValue{
+		value: agent.targetTau*new + (1-agent.targetTau)*t,
+		step:  agent.steps,
+	}
+}
@@ -0,0 +1,52 @@
+package qlearning
+
+import "testing"
+
+// oscillate alternates the actions of a two-state cycle for steps
+// rounds, with t's action paying 30 and 10 in turn, and returns the
+// mean and variance of the Q-value of s's action, which only changes
+// through bootstrapping off t, over the second half of the rounds.
+func oscillate(tau float32, steps int) (mean, variance float32) {
+	g := graph{"s": {"a": "t"}, "t": {"b": "s"}}
+	agent := NewSimpleAgent(0.9, 0.5)
+	agent.SetTargetTau(tau)
+
+	var values []float32
+	for i := 0; i < steps; i++ {
+		r := float32(10)
+		if i%2 == 0 {
+			r = 30
+		}
+		agent.Learn(g.step("t", "b"), fixedReward(r))
+		agent.Learn(g.step("s", "a"), fixedReward(0))
+		if i >= steps/2 {
+			values = append(values, agent.Value(g.at("s"), edge{g, "a", "t"}))
+		}
+	}
+
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float32(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float32(len(values))
+
+	return mean, variance
+}
+
+func TestTargetTauSmoothsValues(t *testing.T) {
+	hardMean, hardVariance := oscillate(0, 400)
+	softMean, softVariance := oscillate(0.1, 400)
+
+	if hardVariance < 1 {
+		t.Fatalf("variance %g bootstrapping off the Q-values, want the value to oscillate", hardVariance)
+	}
+	if softVariance > hardVariance/10 {
+		t.Errorf("variance %g with soft target updates, want well below %g without", softVariance, hardVariance)
+	}
+	if !near(softMean, hardMean, 1) {
+		t.Errorf("mean %g with soft target updates, want about %g as without", softMean, hardMean)
+	}
+}
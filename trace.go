@@ -0,0 +1,62 @@
+package qlearning
+
+// Step is a single step of an episode played by TraceEpisode.
+type Step struct {
+	// State is the string representation of the State before the
+	// action was applied.
+	State string
+
+	// Action is the string representation of the chosen Action.
+	Action string
+
+	// Value is the agent's Q-value for State and Action when it was
+	// chosen.
+	Value float32
+
+	// Reward is the reward given for the action.
+	Reward float32
+
+	// Next is the string representation of the resulting State.
+	Next string
+}
+
+// TraceEpisode plays a single episode in env, always choosing the action
+// with the highest Q-value, and returns every step taken. Nothing is
+// learned. Actions are chosen with Best, so that the same agent and
+// environment always produce the same trace.
+//
+// The episode ends when env is Done, when it offers no actions, or after
+// maxSteps steps, so that the trace of an agent that has learned to
+// loop still ends; a maxSteps of 0 or less sets no limit. If an action
+// implementing FallibleAction fails to apply, TraceEpisode returns the
+// steps taken before it and the error.
+//
+// As in SimpleAgent.Learn, the reward for each step is evaluated after
+// its action is applied.
+func TraceEpisode(agent Agent, env Environment, maxSteps int) ([]Step, error) {
+	steps := make([]Step, 0)
+
+	for !env.Done() && (maxSteps <= 0 || len(steps) < maxSteps) {
+		choice := Best(agent, env)
+		if choice == nil {
+			break
+		}
+
+		step := Step{
+			State:  env.String(),
+			Action: choice.Action.String(),
+			Value:  choice.Value,
+		}
+
+		next, err := applyAction(choice.Action, env)
+		if err != nil {
+			return steps, err
+		}
+		step.Reward = rewardOf(env, choice, next, nil)
+		step.Next = next.String()
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
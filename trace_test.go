@@ -0,0 +1,52 @@
+package qlearning
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTraceEpisode(t *testing.T) {
+	g := graph{"s": {"a": "t", "b": "end"}, "t": {"c": "end"}}
+	agent := NewSimpleAgent(1, 0)
+	agent.Learn(g.step("s", "a"), fixedReward(2))
+
+	steps, err := TraceEpisode(agent, &walk{g, rewards{"a": 1, "c": 3}, "s"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Step{
+		{State: "s", Action: "a", Value: 2, Reward: 1, Next: "t"},
+		{State: "t", Action: "c", Value: 0, Reward: 3, Next: "end"},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("TraceEpisode = %+v, want %+v", steps, want)
+	}
+}
+
+func TestTraceEpisodeMaxSteps(t *testing.T) {
+	g := graph{"s": {"loop": "s"}}
+
+	steps, err := TraceEpisode(NewSimpleAgent(1, 0), &walk{g, nil, "s"}, 5)
+	if err != nil || len(steps) != 5 {
+		t.Errorf("TraceEpisode of a loop took %d steps, error %v; want 5, nil", len(steps), err)
+	}
+}
+
+func TestTraceEpisodeNoActions(t *testing.T) {
+	g := graph{"s": {"a": "stuck"}}
+
+	steps, err := TraceEpisode(NewSimpleAgent(1, 0), &walk{g, nil, "s"}, 0)
+	if err != nil || len(steps) != 1 {
+		t.Errorf("TraceEpisode into a state without actions took %d steps, error %v; want 1, nil", len(steps), err)
+	}
+}
+
+func TestTraceEpisodeFallible(t *testing.T) {
+	g := graph{"s": {"a": "t"}, "t": {"b": "fail"}}
+
+	steps, err := TraceEpisode(NewSimpleAgent(1, 0), &walk{g, nil, "s"}, 0)
+	if err == nil || len(steps) != 1 {
+		t.Errorf("TraceEpisode with a failing action took %d steps, error %v; want 1 and an error", len(steps), err)
+	}
+}
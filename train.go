@@ -0,0 +1,311 @@
+package qlearning
+
+import (
+	"context"
+	"fmt"
+)
+
+// Trainer plays and learns from episodes of an Environment. The zero
+// Trainer places no limits on episodes and is what RunEpisode, Train,
+// and TrainSteps use.
+type Trainer struct {
+	// MaxStepsPerEpisode, if positive, ends any episode that has not
+	// finished after that many steps, recording it as timed out. This
+	// guards against Environments that never finish, whether through a
+	// bug or a pathological policy.
+	//
+	// A step is one action chosen and learned from, so this is also a
+	// hard budget of actions per episode, independent of anything the
+	// Environment itself limits, such as hangman's lives. Comparing
+	// agents under a fixed action budget needs only the same
+	// MaxStepsPerEpisode for each.
+	//
+	// Ending an episode this way does not make its last state terminal:
+	// the last update still bootstraps from the value of the state it
+	// reached, unlike that of an episode the Environment ends, since
+	// the episode could have continued from there.
+	MaxStepsPerEpisode int
+
+	// Policy, if set, chooses every action in place of Next, so that
+	// how the agent explores can be changed between episodes, or by
+	// OnEpisodeEnd, without changing the agent.
+	Policy Policy
+
+	// ConvergenceThreshold, if positive, stops TrainEpisodes, Train and
+	// TrainSteps after the first episode at whose end the agent reports
+	// that it has converged through a method
+	//
+	//	HasConverged(threshold float32) bool
+	//
+	// such as SimpleAgent's, given this threshold. Agents without one
+	// are trained for as long as asked.
+	ConvergenceThreshold float32
+
+	// CurriculumWindow, if positive, is the number of most recent
+	// episodes of a stage that TrainCurriculum judges promotion on.
+	// Otherwise every episode of the stage so far is counted.
+	CurriculumWindow int
+
+	// OnEpisodeEnd, if set, is called with the result of every episode
+	// as soon as it ends, by every method that plays episodes, for
+	// progress reporting or for adjusting hyperparameters as training
+	// goes. An episode TrainSteps cuts short is not reported, as it is
+	// not counted either.
+	OnEpisodeEnd func(result EpisodeResult)
+
+	// Logger, if set, is told of every episode a limit ends early or
+	// that reaches a State with no actions, and of Train stopping once
+	// its context is done.
+	Logger Logger
+}
+
+// EpisodeResult describes an episode played by a Trainer.
+type EpisodeResult struct {
+	// Steps is the number of actions taken.
+	Steps int
+
+	// Outcome is the Outcome of the final state, following the Outcomer
+	// convention, or 0 if the Environment does not implement Outcomer.
+	Outcome int
+
+	// TimedOut reports whether the episode was ended by a limit before
+	// the Environment was done.
+	TimedOut bool
+
+	// State is the string representation of the final state.
+	State string
+
+	// Reward is the sum of the rewards given by the Environment during
+	// the episode, as given, before any processing by the agent such
+	// as normalization. Every reward of a MultiRewarder is included.
+	// Only rewards the agent asks for are counted, so it is 0 for
+	// agents that learn nothing, such as RandomAgent.
+	Reward float32
+
+	// Err, if not nil, is why the episode ended before the Environment
+	// was done, other than a limit: an error wrapping ErrNoActions if
+	// it offered no action to take. The episode is still counted by its
+	// Outcome.
+	Err error
+}
+
+// Add records an episode in m. A timed out episode is counted as a
+// timeout regardless of its outcome.
+func (m *Metrics) Add(result EpisodeResult) {
+	m.Reward += result.Reward
+
+	if result.TimedOut {
+		m.Episodes++
+		m.Timeouts++
+		m.Steps += result.Steps
+		return
+	}
+
+	m.Record(result.Outcome, result.Steps)
+}
+
+// RunEpisode plays env until it is done or a limit ends it, choosing
+// each action with Policy or Next and learning from it with agent.Learn,
+// then calls NewEpisode on agent if it is an EpisodeResetter. An
+// Environment that offers no action before it is done ends the episode
+// there, with the error in the result's Err.
+func (t *Trainer) RunEpisode(agent Agent, env Environment) EpisodeResult {
+	result := t.runEpisode(agent, env, -1)
+	t.episodeEnd(result)
+
+	return result
+}
+
+// episodeEnd reports the end of an episode to OnEpisodeEnd.
+func (t *Trainer) episodeEnd(result EpisodeResult) {
+	if t.OnEpisodeEnd != nil {
+		t.OnEpisodeEnd(result)
+	}
+}
+
+// runEpisode is RunEpisode, also stopping after budget steps if budget
+// is not negative. An episode stopped by the budget is not timed out.
+func (t *Trainer) runEpisode(agent Agent, env Environment, budget int) EpisodeResult {
+	var result EpisodeResult
+	rewarder := recordRewards(env, &result.Reward)
+
+	for !env.Done() && result.Steps != budget {
+		if t.MaxStepsPerEpisode > 0 && result.Steps >= t.MaxStepsPerEpisode {
+			result.TimedOut = true
+			t.logf("qlearning: episode timed out after %d steps in %q", result.Steps, env.String())
+			break
+		}
+
+		sa, err := t.next(agent, env)
+		if err != nil {
+			result.Err = err
+			t.logf("qlearning: episode ended after %d steps: %v", result.Steps, err)
+			break
+		}
+
+		agent.Learn(sa, rewarder)
+		result.Steps++
+	}
+
+	result.Outcome = outcome(env)
+	result.State = env.String()
+	newEpisode(agent)
+
+	return result
+}
+
+// converged reports whether training of agent can stop, as set by
+// ConvergenceThreshold.
+func (t *Trainer) converged(agent Agent) bool {
+	c, ok := agent.(interface{ HasConverged(float32) bool })
+	return ok && t.ConvergenceThreshold > 0 && c.HasConverged(t.ConvergenceThreshold)
+}
+
+// next chooses an action of state with Policy, or Next if it is not set,
+// returning an error wrapping ErrNoActions if there is none, as NextErr
+// does.
+func (t *Trainer) next(agent Agent, state State) (*StateAction, error) {
+	if t.Policy == nil {
+		return NextErr(agent, state)
+	}
+
+	sa := t.Policy.Select(agent, state)
+	if sa == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNoActions, state.String())
+	}
+
+	return sa, nil
+}
+
+// TrainEpisodes plays and learns from the given number of episodes, each
+// in a new Environment from newEnv, and returns their Metrics. It stops
+// early once the agent has converged, as set by ConvergenceThreshold.
+func (t *Trainer) TrainEpisodes(agent Agent, newEnv func() Environment, episodes int) Metrics {
+	var m Metrics
+
+	for i := 0; i < episodes && !t.converged(agent); i++ {
+		m.Add(t.RunEpisode(agent, newEnv()))
+	}
+
+	return m
+}
+
+// Train is TrainEpisodes, also stopping early once ctx is done. ctx is
+// checked before every episode, never during one, so the agent is always
+// left between episodes. If ctx ends training early, Train returns the
+// Metrics of the episodes played so far, and ctx.Err(). Stopping because
+// the agent has converged is not an error.
+func (t *Trainer) Train(ctx context.Context, agent Agent, newEnv func() Environment, episodes int) (Metrics, error) {
+	var m Metrics
+
+	for i := 0; i < episodes && !t.converged(agent); i++ {
+		if err := ctx.Err(); err != nil {
+			t.logf("qlearning: training stopped after %d of %d episodes: %v", i, episodes, err)
+			return m, err
+		}
+
+		m.Add(t.RunEpisode(agent, newEnv()))
+	}
+
+	return m, nil
+}
+
+// TrainSteps plays and learns from episodes back to back until a total
+// of maxSteps actions have been taken, starting a new Environment from
+// newEnv whenever one is done or times out, and returns the Metrics of
+// the episodes that finished. An episode cut short by the total budget
+// is not counted. TrainSteps also stops if newEnv returns an Environment
+// that is already done, as no further steps could be taken, or once the
+// agent has converged, as set by ConvergenceThreshold.
+func (t *Trainer) TrainSteps(agent Agent, newEnv func() Environment, maxSteps int) Metrics {
+	var m Metrics
+
+	for total := 0; total < maxSteps && !t.converged(agent); {
+		env := newEnv()
+
+		result := t.runEpisode(agent, env, maxSteps-total)
+		total += result.Steps
+
+		if env.Done() || result.TimedOut || result.Err != nil {
+			m.Add(result)
+			t.episodeEnd(result)
+		}
+		if result.Steps == 0 {
+			break
+		}
+	}
+
+	return m
+}
+
+// recordRewards returns a Rewarder giving the same rewards as rewarder,
+// and adding each to total. It implements NextRewarder or MultiRewarder
+// exactly when rewarder does, so agents treat it as they would
+// rewarder.
+func recordRewards(rewarder Rewarder, total *float32) Rewarder {
+	r := recordingRewarder{rewarder, total}
+
+	switch rewarder.(type) {
+	case NextRewarder:
+		return recordingNextRewarder{r}
+	case MultiRewarder:
+		return recordingMultiRewarder{r}
+	}
+
+	return r
+}
+
+// recordingRewarder is the Rewarder returned by recordRewards.
+type recordingRewarder struct {
+	rewarder Rewarder
+	total    *float32
+}
+
+func (r recordingRewarder) Reward(action *StateAction) float32 {
+	reward := r.rewarder.Reward(action)
+	*r.total += reward
+	return reward
+}
+
+// recordingNextRewarder is recordingRewarder for a NextRewarder.
+type recordingNextRewarder struct {
+	recordingRewarder
+}
+
+func (r recordingNextRewarder) RewardNext(action *StateAction, next State) float32 {
+	reward := r.rewarder.(NextRewarder).RewardNext(action, next)
+	*r.total += reward
+	return reward
+}
+
+// recordingMultiRewarder is recordingRewarder for a MultiRewarder.
+type recordingMultiRewarder struct {
+	recordingRewarder
+}
+
+func (r recordingMultiRewarder) Rewards(action *StateAction) []float32 {
+	rewards := r.rewarder.(MultiRewarder).Rewards(action)
+	for _, reward := range rewards {
+		*r.total += reward
+	}
+	return rewards
+}
+
+// RunEpisode plays env to the end, choosing each action with Next and
+// learning from it with agent.Learn, and returns the number of steps
+// taken.
+func RunEpisode(agent Agent, env Environment) int {
+	return (&Trainer{}).RunEpisode(agent, env).Steps
+}
+
+// Train plays and learns from the given number of episodes, each in a
+// new Environment from newEnv, and returns their Metrics.
+func Train(agent Agent, newEnv func() Environment, episodes int) Metrics {
+	return (&Trainer{}).TrainEpisodes(agent, newEnv, episodes)
+}
+
+// TrainSteps plays and learns from episodes back to back until a total
+// of maxSteps actions have been taken. See Trainer.TrainSteps.
+func TrainSteps(agent Agent, newEnv func() Environment, maxSteps int) Metrics {
+	return (&Trainer{}).TrainSteps(agent, newEnv, maxSteps)
+}
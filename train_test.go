@@ -0,0 +1,119 @@
+package qlearning
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// corridor is a graph of a line of states, s0 to end, each with a
+// single action forward, rewarded 0 but the last.
+var corridor = graph{"s0": {"a": "s1"}, "s1": {"a": "s2"}, "s2": {"win": "end"}}
+
+func newCorridorWalk() Environment {
+	return &walk{corridor, rewards{"win": 1}, "s0"}
+}
+
+func TestTrainSteps(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	m := TrainSteps(agent, newCorridorWalk, 10)
+
+	// Three whole episodes of three steps, and a fourth cut short.
+	if m.Episodes != 3 || m.Steps != 9 || m.Reward != 3 {
+		t.Errorf("got %d episodes of %d steps with reward %g, want 3 of 9 with 3", m.Episodes, m.Steps, m.Reward)
+	}
+	if agent.Steps() != 10 {
+		t.Errorf("agent learned from %d steps, want the whole budget of 10", agent.Steps())
+	}
+}
+
+func TestTrainContext(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancelled as the second episode ends, Train stops before a third.
+	episodes := 0
+	trainer := &Trainer{OnEpisodeEnd: func(EpisodeResult) {
+		if episodes++; episodes == 2 {
+			cancel()
+		}
+	}}
+	m, err := trainer.Train(ctx, agent, newCorridorWalk, 10)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Train returned %v, want context.Canceled", err)
+	}
+	if m.Episodes != 2 || m.Reward != 2 || agent.Steps() != 6 {
+		t.Errorf("got %d episodes with reward %g, %d steps learned; want 2 with 2, and 6", m.Episodes, m.Reward, agent.Steps())
+	}
+
+	m, err = (&Trainer{}).Train(context.Background(), agent, newCorridorWalk, 3)
+	if err != nil || m.Episodes != 3 {
+		t.Errorf("Train = %d episodes, %v; want 3 and no error", m.Episodes, err)
+	}
+}
+
+func TestTrainStepsDoneEnvironment(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	done := func() Environment { return &walk{corridor, nil, "end"} }
+
+	// The empty episode is counted, and training stops rather than
+	// starting environments forever.
+	if m := TrainSteps(agent, done, 10); m.Episodes != 1 || m.Steps != 0 || agent.Steps() != 0 {
+		t.Errorf("got %d episodes, %d steps from an environment already done, want 1 episode of none", m.Episodes, agent.Steps())
+	}
+}
+
+func BenchmarkTrainSteps(b *testing.B) {
+	agent := NewSimpleAgent(0.5, 0.9)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	TrainSteps(agent, newCorridorWalk, b.N)
+}
+
+// stuck is a graph whose episodes get stuck in s1, which is not the end
+// but has no actions.
+var stuck = graph{"s0": {"a": "s1"}}
+
+func newStuckWalk() Environment {
+	return &walk{stuck, rewards{"a": 1}, "s0"}
+}
+
+func TestRunEpisodeNoActions(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	for name, trainer := range map[string]*Trainer{
+		"Next":   {},
+		"Policy": {Policy: GreedyPolicy{}},
+	} {
+		result := trainer.RunEpisode(agent, newStuckWalk())
+		if !errors.Is(result.Err, ErrNoActions) {
+			t.Errorf("%s: Err = %v, want ErrNoActions", name, result.Err)
+		}
+		if result.Steps != 1 || result.State != "s1" || result.TimedOut {
+			t.Errorf("%s: got %+v, want one step ending in s1", name, result)
+		}
+	}
+
+	if result := (&Trainer{}).RunEpisode(agent, newCorridorWalk()); result.Err != nil {
+		t.Errorf("Err = %v for an episode that ended, want nil", result.Err)
+	}
+}
+
+func TestTrainStepsNoActions(t *testing.T) {
+	agent := NewSimpleAgent(0.5, 0.9)
+	m := TrainSteps(agent, newStuckWalk, 5)
+
+	// Every stuck episode takes one step and is counted, but the last,
+	// which the budget ends before it is found to be stuck.
+	if m.Episodes != 4 || m.Steps != 4 || agent.Steps() != 5 {
+		t.Errorf("got %d episodes of %d steps, %d learned, want 4 of 4, and 5 learned", m.Episodes, m.Steps, agent.Steps())
+	}
+}
+
+func TestEvaluateNoActions(t *testing.T) {
+	m := Evaluate(NewSimpleAgent(0.5, 0.9), newStuckWalk, 3)
+	if m.Episodes != 3 || m.Steps != 3 || m.Draws != 3 {
+		t.Errorf("got %+v, want 3 drawn episodes of one step", m)
+	}
+}